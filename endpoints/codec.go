@@ -0,0 +1,214 @@
+package endpoints
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Keys names the attributes of an endpoint as stored by a backend,
+// i.e. the keys of a Secret data section, the file names of a
+// mounted volume or the suffixes of the environment variables.
+//
+// Empty fields fall back to the ones returned by DefaultKeys.
+type Keys struct {
+	ServerURL                string
+	ProxyURL                 string
+	CertificateAuthorityData string
+	ClientCertificateData    string
+	ClientKeyData            string
+	Token                    string
+	Username                 string
+	Password                 string
+	Debug                    string
+	Insecure                 string
+	AwsAccessKey             string
+	AwsSecretKey             string
+	AwsRegion                string
+	AwsService               string
+	AwsSessionToken          string
+	OAuth2TokenURL           string
+	OAuth2ClientID           string
+	OAuth2ClientSecret       string
+	OAuth2Scopes             string
+	OAuth2Audience           string
+	OAuth2RefreshToken       string
+}
+
+// DefaultKeys returns the keys of the "-clientconfig" Secrets.
+func DefaultKeys() Keys {
+	return Keys{
+		ServerURL:                "server-url",
+		ProxyURL:                 "proxy-url",
+		CertificateAuthorityData: "certificate-authority-data",
+		ClientCertificateData:    "client-certificate-data",
+		ClientKeyData:            "client-key-data",
+		Token:                    "token",
+		Username:                 "username",
+		Password:                 "password",
+		Debug:                    "debug",
+		Insecure:                 "insecure",
+		AwsAccessKey:             "aws-access-key",
+		AwsSecretKey:             "aws-secret-key",
+		AwsRegion:                "aws-region",
+		AwsService:               "aws-service",
+		AwsSessionToken:          "aws-session-token",
+		OAuth2TokenURL:           "oauth2-token-url",
+		OAuth2ClientID:           "oauth2-client-id",
+		OAuth2ClientSecret:       "oauth2-client-secret",
+		OAuth2Scopes:             "oauth2-scopes",
+		OAuth2Audience:           "oauth2-audience",
+		OAuth2RefreshToken:       "oauth2-refresh-token",
+	}
+}
+
+// Option configures a Source or a Sink.
+type Option func(*options)
+
+type options struct {
+	keys Keys
+}
+
+// WithKeys overrides the attribute keys (default: DefaultKeys).
+func WithKeys(k Keys) Option {
+	return func(o *options) {
+		o.keys = k.withDefaults()
+	}
+}
+
+func newOptions(opts []Option) options {
+	res := options{keys: DefaultKeys()}
+	for _, fn := range opts {
+		fn(&res)
+	}
+	return res
+}
+
+// withDefaults returns a copy of k with the empty fields
+// set to the default keys.
+func (k Keys) withDefaults() Keys {
+	def := DefaultKeys()
+	for _, el := range []struct{ dst, src *string }{
+		{&k.ServerURL, &def.ServerURL},
+		{&k.ProxyURL, &def.ProxyURL},
+		{&k.CertificateAuthorityData, &def.CertificateAuthorityData},
+		{&k.ClientCertificateData, &def.ClientCertificateData},
+		{&k.ClientKeyData, &def.ClientKeyData},
+		{&k.Token, &def.Token},
+		{&k.Username, &def.Username},
+		{&k.Password, &def.Password},
+		{&k.Debug, &def.Debug},
+		{&k.Insecure, &def.Insecure},
+		{&k.AwsAccessKey, &def.AwsAccessKey},
+		{&k.AwsSecretKey, &def.AwsSecretKey},
+		{&k.AwsRegion, &def.AwsRegion},
+		{&k.AwsService, &def.AwsService},
+		{&k.AwsSessionToken, &def.AwsSessionToken},
+		{&k.OAuth2TokenURL, &def.OAuth2TokenURL},
+		{&k.OAuth2ClientID, &def.OAuth2ClientID},
+		{&k.OAuth2ClientSecret, &def.OAuth2ClientSecret},
+		{&k.OAuth2Scopes, &def.OAuth2Scopes},
+		{&k.OAuth2Audience, &def.OAuth2Audience},
+		{&k.OAuth2RefreshToken, &def.OAuth2RefreshToken},
+	} {
+		if len(*el.dst) == 0 {
+			*el.dst = *el.src
+		}
+	}
+	return k
+}
+
+// all lists every key understood by decode and produced by encode.
+func (k Keys) all() []string {
+	return []string{
+		k.ClientCertificateData, k.ClientKeyData, k.CertificateAuthorityData,
+		k.ProxyURL, k.ServerURL,
+		k.Debug, k.Insecure,
+		k.Password, k.Username, k.Token,
+		k.AwsAccessKey, k.AwsSecretKey, k.AwsRegion, k.AwsService, k.AwsSessionToken,
+		k.OAuth2TokenURL, k.OAuth2ClientID, k.OAuth2ClientSecret,
+		k.OAuth2Scopes, k.OAuth2Audience, k.OAuth2RefreshToken,
+	}
+}
+
+// decode builds an Endpoint from a key/value set laid out
+// like the data section of a "-clientconfig" Secret.
+func (k Keys) decode(data map[string][]byte) (Endpoint, error) {
+	res := Endpoint{}
+	if v, ok := data[k.ServerURL]; ok {
+		res.ServerURL = string(v)
+	} else {
+		return res, fmt.Errorf("missed required attribute for endpoint: %s", k.ServerURL)
+	}
+
+	str := func(key string, dst *string) {
+		if v, ok := data[key]; ok {
+			*dst = string(v)
+		}
+	}
+
+	str(k.ProxyURL, &res.ProxyURL)
+	str(k.Token, &res.Token)
+	str(k.Username, &res.Username)
+	str(k.Password, &res.Password)
+	str(k.CertificateAuthorityData, &res.CertificateAuthorityData)
+	str(k.ClientKeyData, &res.ClientKeyData)
+	str(k.ClientCertificateData, &res.ClientCertificateData)
+	str(k.AwsAccessKey, &res.AwsAccessKey)
+	str(k.AwsSecretKey, &res.AwsSecretKey)
+	str(k.AwsRegion, &res.AwsRegion)
+	str(k.AwsService, &res.AwsService)
+	str(k.AwsSessionToken, &res.AwsSessionToken)
+	str(k.OAuth2TokenURL, &res.OAuth2TokenURL)
+	str(k.OAuth2ClientID, &res.OAuth2ClientID)
+	str(k.OAuth2ClientSecret, &res.OAuth2ClientSecret)
+	str(k.OAuth2Scopes, &res.OAuth2Scopes)
+	str(k.OAuth2Audience, &res.OAuth2Audience)
+	str(k.OAuth2RefreshToken, &res.OAuth2RefreshToken)
+
+	if v, ok := data[k.Debug]; ok {
+		res.Debug, _ = strconv.ParseBool(string(v))
+	}
+
+	if v, ok := data[k.Insecure]; ok {
+		res.Insecure, _ = strconv.ParseBool(string(v))
+	}
+
+	return res, nil
+}
+
+// encode is the inverse of decode.
+// Optional attributes are omitted when empty.
+func (k Keys) encode(ep Endpoint) map[string]string {
+	res := map[string]string{
+		k.Username:                 ep.Username,
+		k.Password:                 ep.Password,
+		k.Token:                    ep.Token,
+		k.CertificateAuthorityData: ep.CertificateAuthorityData,
+		k.ClientCertificateData:    ep.ClientCertificateData,
+		k.ClientKeyData:            ep.ClientKeyData,
+		k.ServerURL:                ep.ServerURL,
+		k.ProxyURL:                 ep.ProxyURL,
+		k.Debug:                    strconv.FormatBool(ep.Debug),
+		k.Insecure:                 strconv.FormatBool(ep.Insecure),
+	}
+
+	opt := func(key, val string) {
+		if len(val) > 0 {
+			res[key] = val
+		}
+	}
+
+	opt(k.AwsAccessKey, ep.AwsAccessKey)
+	opt(k.AwsSecretKey, ep.AwsSecretKey)
+	opt(k.AwsRegion, ep.AwsRegion)
+	opt(k.AwsService, ep.AwsService)
+	opt(k.AwsSessionToken, ep.AwsSessionToken)
+	opt(k.OAuth2TokenURL, ep.OAuth2TokenURL)
+	opt(k.OAuth2ClientID, ep.OAuth2ClientID)
+	opt(k.OAuth2ClientSecret, ep.OAuth2ClientSecret)
+	opt(k.OAuth2Scopes, ep.OAuth2Scopes)
+	opt(k.OAuth2Audience, ep.OAuth2Audience)
+	opt(k.OAuth2RefreshToken, ep.OAuth2RefreshToken)
+
+	return res
+}
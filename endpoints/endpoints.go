@@ -2,17 +2,48 @@ package endpoints
 
 import (
 	"context"
-	"fmt"
-	"strconv"
 
-	"github.com/krateoplatformops/plumbing/kubeutil"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/rest"
 )
 
+var (
+	_ Source = (*SecretStore)(nil)
+	_ Sink   = (*SecretStore)(nil)
+)
+
 func FromSecret(ctx context.Context, rc *rest.Config, name, namespace string) (Endpoint, error) {
-	cli, err := newSecretsRESTClient(rc)
+	return NewSecretStore(rc, namespace).Get(ctx, name)
+}
+
+func Store(ctx context.Context, rc *rest.Config, ns string, ep Endpoint) error {
+	return NewSecretStore(rc, ns).Put(ctx, ClientConfigName(ep.Username), ep)
+}
+
+// ClientConfigLabel is set to "true" on the Secrets written by
+// SecretStore, so that they can be selected (see InformerStore).
+const ClientConfigLabel = "krateo.io/clientconfig"
+
+// SecretStore reads and writes endpoints as Kubernetes Secrets
+// living in a single namespace.
+type SecretStore struct {
+	rc        *rest.Config
+	namespace string
+	keys      Keys
+}
+
+// NewSecretStore returns a store backed by the Secrets of the given namespace.
+func NewSecretStore(rc *rest.Config, namespace string, opts ...Option) *SecretStore {
+	return &SecretStore{
+		rc:        rc,
+		namespace: namespace,
+		keys:      newOptions(opts).keys,
+	}
+}
+
+func (s *SecretStore) Get(ctx context.Context, name string) (Endpoint, error) {
+	cli, err := newSecretsRESTClient(s.rc)
 	if err != nil {
 		return Endpoint{}, err
 	}
@@ -20,92 +51,23 @@ func FromSecret(ctx context.Context, rc *rest.Config, name, namespace string) (E
 	sec, err := getSecret(ctx, clientOptions{
 		cli:       cli,
 		name:      name,
-		namespace: namespace,
+		namespace: s.namespace,
 	})
 	if err != nil {
 		return Endpoint{}, err
 	}
 
-	res := Endpoint{}
-	if v, ok := sec.Data[serverUrlLabel]; ok {
-		res.ServerURL = string(v)
-	} else {
-		return res, fmt.Errorf("missed required attribute for endpoint: server-url")
-	}
-
-	if v, ok := sec.Data[proxyUrlLabel]; ok {
-		res.ProxyURL = string(v)
-	}
-
-	if v, ok := sec.Data[tokenLabel]; ok {
-		res.Token = string(v)
-	}
-
-	if v, ok := sec.Data[usernameLabel]; ok {
-		res.Username = string(v)
-	}
-
-	if v, ok := sec.Data[passwordLabel]; ok {
-		res.Password = string(v)
-	}
-
-	if v, ok := sec.Data[caLabel]; ok {
-		res.CertificateAuthorityData = string(v)
-	}
-
-	if v, ok := sec.Data[clientKeyLabel]; ok {
-		res.ClientKeyData = string(v)
-	}
-
-	if v, ok := sec.Data[clientCertLabel]; ok {
-		res.ClientCertificateData = string(v)
-	}
-
-	if v, ok := sec.Data[debugLabel]; ok {
-		res.Debug, _ = strconv.ParseBool(string(v))
-	}
-
-	if v, ok := sec.Data[insecureLabel]; ok {
-		res.Insecure, _ = strconv.ParseBool(string(v))
-	}
-
-	if v, ok := sec.Data[awsAccessKeyLabel]; ok {
-		res.AwsAccessKey = string(v)
-	}
-
-	if v, ok := sec.Data[awsSecretKeyLabel]; ok {
-		res.AwsSecretKey = string(v)
-	}
-
-	if v, ok := sec.Data[awsRegionLabel]; ok {
-		res.AwsRegion = string(v)
-	}
-
-	if v, ok := sec.Data[awsServiceLabel]; ok {
-		res.AwsService = string(v)
-	}
-
-	return res, nil
+	return s.keys.decode(sec.Data)
 }
 
-func Store(ctx context.Context, rc *rest.Config, ns string, ep Endpoint) error {
+func (s *SecretStore) Put(ctx context.Context, name string, ep Endpoint) error {
 	sec := corev1.Secret{}
-	sec.SetName(fmt.Sprintf("%s-clientconfig", kubeutil.MakeDNS1123Compatible(ep.Username)))
-	sec.SetNamespace(ns)
-	sec.StringData = map[string]string{
-		usernameLabel:   ep.Username,
-		passwordLabel:   ep.Password,
-		tokenLabel:      ep.Token,
-		caLabel:         ep.CertificateAuthorityData,
-		clientCertLabel: ep.ClientCertificateData,
-		clientKeyLabel:  ep.ClientKeyData,
-		serverUrlLabel:  ep.ServerURL,
-		proxyUrlLabel:   ep.ProxyURL,
-		debugLabel:      strconv.FormatBool(ep.Debug),
-		insecureLabel:   strconv.FormatBool(ep.Insecure),
-	}
+	sec.SetName(name)
+	sec.SetNamespace(s.namespace)
+	sec.SetLabels(map[string]string{ClientConfigLabel: "true"})
+	sec.StringData = s.keys.encode(ep)
 
-	cli, err := newSecretsRESTClient(rc)
+	cli, err := newSecretsRESTClient(s.rc)
 	if err != nil {
 		return err
 	}
@@ -120,20 +82,3 @@ func Store(ctx context.Context, rc *rest.Config, ns string, ep Endpoint) error {
 
 	return updateSecret(ctx, cli, &sec)
 }
-
-const (
-	clientCertLabel   = "client-certificate-data"
-	clientKeyLabel    = "client-key-data"
-	caLabel           = "certificate-authority-data"
-	proxyUrlLabel     = "proxy-url"
-	serverUrlLabel    = "server-url"
-	debugLabel        = "debug"
-	passwordLabel     = "password"
-	usernameLabel     = "username"
-	tokenLabel        = "token"
-	insecureLabel     = "insecure"
-	awsAccessKeyLabel = "aws-access-key"
-	awsSecretKeyLabel = "aws-secret-key"
-	awsRegionLabel    = "aws-region"
-	awsServiceLabel   = "aws-service"
-)
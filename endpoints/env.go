@@ -0,0 +1,76 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var _ Source = (*EnvSource)(nil)
+
+// EnvSource reads endpoints from environment variables.
+//
+// Every attribute is looked up in a variable named
+// <PREFIX>_<NAME>_<KEY>, where NAME and KEY are upper-cased
+// and any character other than letters and digits is replaced
+// by an underscore. As an example, with prefix "KRATEO_ENDPOINT"
+// the server url of the endpoint "cyberjoker-clientconfig" is
+// read from KRATEO_ENDPOINT_CYBERJOKER_CLIENTCONFIG_SERVER_URL.
+//
+// When the name is empty the NAME segment is omitted.
+type EnvSource struct {
+	prefix string
+	keys   Keys
+	lookup func(string) (string, bool)
+}
+
+// NewEnvSource returns a source reading variables with the given prefix.
+func NewEnvSource(prefix string, opts ...Option) *EnvSource {
+	return &EnvSource{
+		prefix: prefix,
+		keys:   newOptions(opts).keys,
+		lookup: os.LookupEnv,
+	}
+}
+
+func (s *EnvSource) Get(_ context.Context, name string) (Endpoint, error) {
+	data := map[string][]byte{}
+	for _, key := range s.keys.all() {
+		if val, ok := s.lookup(s.VarName(name, key)); ok {
+			data[key] = []byte(val)
+		}
+	}
+
+	if _, ok := data[s.keys.ServerURL]; !ok {
+		return Endpoint{}, fmt.Errorf("%w: %s is not set",
+			ErrNotFound, s.VarName(name, s.keys.ServerURL))
+	}
+
+	return s.keys.decode(data)
+}
+
+// VarName returns the environment variable holding
+// the attribute key of the named endpoint.
+func (s *EnvSource) VarName(name, key string) string {
+	parts := make([]string, 0, 3)
+	for _, el := range []string{s.prefix, name, key} {
+		if len(el) > 0 {
+			parts = append(parts, envSanitize(el))
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+func envSanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -0,0 +1,62 @@
+package endpoints
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvSourceVarName(t *testing.T) {
+	keys := DefaultKeys()
+	tests := []struct {
+		prefix string
+		name   string
+		key    string
+		want   string
+	}{
+		{"KRATEO_ENDPOINT", "cyberjoker-clientconfig", keys.ServerURL, "KRATEO_ENDPOINT_CYBERJOKER_CLIENTCONFIG_SERVER_URL"},
+		{"KRATEO_ENDPOINT", "", keys.Token, "KRATEO_ENDPOINT_TOKEN"},
+		{"", "pinoc.pallo@email.com", keys.CertificateAuthorityData, "PINOC_PALLO_EMAIL_COM_CERTIFICATE_AUTHORITY_DATA"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.want, func(t *testing.T) {
+			got := NewEnvSource(tc.prefix).VarName(tc.name, tc.key)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestEnvSource(t *testing.T) {
+	t.Setenv("TEST_EP_DEMO_SERVER_URL", "https://example.org")
+	t.Setenv("TEST_EP_DEMO_USERNAME", "admin")
+	t.Setenv("TEST_EP_DEMO_PASSWORD", "s3cr3t")
+	t.Setenv("TEST_EP_DEMO_DEBUG", "true")
+
+	src := NewEnvSource("TEST_EP")
+
+	got, err := src.Get(context.Background(), "demo")
+	require.NoError(t, err)
+	assert.Equal(t, Endpoint{
+		ServerURL: "https://example.org",
+		Username:  "admin",
+		Password:  "s3cr3t",
+		Debug:     true,
+	}, got)
+
+	_, err = src.Get(context.Background(), "other")
+	assert.True(t, IsNotFound(err))
+}
+
+func TestEnvSourceWithKeys(t *testing.T) {
+	t.Setenv("TEST_EP_DEMO_URL", "https://example.org")
+	t.Setenv("TEST_EP_DEMO_BEARER", "XYZ")
+
+	src := NewEnvSource("TEST_EP", WithKeys(Keys{ServerURL: "url", Token: "bearer"}))
+
+	got, err := src.Get(context.Background(), "demo")
+	require.NoError(t, err)
+	assert.Equal(t, Endpoint{ServerURL: "https://example.org", Token: "XYZ"}, got)
+}
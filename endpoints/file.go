@@ -0,0 +1,103 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	_ Source = (*FileStore)(nil)
+	_ Sink   = (*FileStore)(nil)
+)
+
+// FileStore reads and writes endpoints as directories of files,
+// one file per attribute, the same layout Kubernetes uses
+// when a Secret is mounted as a (projected) volume.
+//
+//	<dir>/<name>/server-url
+//	<dir>/<name>/token
+//	...
+type FileStore struct {
+	dir  string
+	keys Keys
+}
+
+// NewFileStore returns a store rooted at dir.
+func NewFileStore(dir string, opts ...Option) *FileStore {
+	return &FileStore{dir: dir, keys: newOptions(opts).keys}
+}
+
+func (s *FileStore) Get(_ context.Context, name string) (Endpoint, error) {
+	base, err := s.path(name)
+	if err != nil {
+		return Endpoint{}, err
+	}
+
+	fi, err := os.Stat(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Endpoint{}, fmt.Errorf("%w: %s", ErrNotFound, base)
+		}
+		return Endpoint{}, err
+	}
+	if !fi.IsDir() {
+		return Endpoint{}, fmt.Errorf("endpoint path %q is not a directory", base)
+	}
+
+	data := map[string][]byte{}
+	for _, key := range s.keys.all() {
+		// Mounted volumes expose keys through symlinks,
+		// os.ReadFile follows them transparently.
+		dat, err := os.ReadFile(filepath.Join(base, key))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return Endpoint{}, err
+		}
+		data[key] = dat
+	}
+
+	return s.keys.decode(data)
+}
+
+func (s *FileStore) Put(_ context.Context, name string, ep Endpoint) error {
+	base, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(base, 0o700); err != nil {
+		return err
+	}
+
+	data := s.keys.encode(ep)
+	for key, val := range data {
+		if err := os.WriteFile(filepath.Join(base, key), []byte(val), 0o600); err != nil {
+			return err
+		}
+	}
+
+	// Optional attributes cleared since the previous Put
+	// must not be read back by Get.
+	for _, key := range s.keys.all() {
+		if _, ok := data[key]; ok {
+			continue
+		}
+		if err := os.Remove(filepath.Join(base, key)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *FileStore) path(name string) (string, error) {
+	if len(name) == 0 || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid endpoint name %q", name)
+	}
+	return filepath.Join(s.dir, name), nil
+}
@@ -0,0 +1,86 @@
+package endpoints
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	want := Endpoint{
+		Username:                 "pinoc.pallo@email.com",
+		ServerURL:                "https://example.org",
+		CertificateAuthorityData: "CA_BLAH_BLAH_BLAH",
+		ClientKeyData:            "LORE_IPSUM",
+		ClientCertificateData:    "CA_LOREM_IPSUM",
+		Token:                    "DONT_TELL!",
+		Insecure:                 true,
+	}
+
+	store := NewFileStore(t.TempDir())
+	name := ClientConfigName(want.Username)
+
+	err := store.Put(context.Background(), name, want)
+	require.NoError(t, err)
+
+	got, err := store.Get(context.Background(), name)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestFileStorePutClearsAttributes(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	name := ClientConfigName("cyberjoker")
+
+	ep := Endpoint{
+		ServerURL:    "https://example.org",
+		AwsAccessKey: "AKIA",
+		AwsSecretKey: "SECRET",
+		AwsRegion:    "eu-west-1",
+	}
+	require.NoError(t, store.Put(context.Background(), name, ep))
+
+	ep.AwsAccessKey, ep.AwsSecretKey, ep.AwsRegion = "", "", ""
+	ep.Token = "XYZ"
+	require.NoError(t, store.Put(context.Background(), name, ep))
+
+	got, err := store.Get(context.Background(), name)
+	require.NoError(t, err)
+	assert.Equal(t, ep, got)
+}
+
+func TestFileStoreProjectedVolume(t *testing.T) {
+	dir := t.TempDir()
+
+	keys := DefaultKeys()
+
+	// Kubernetes mounts each key as a symlink to a hidden data directory.
+	data := filepath.Join(dir, "demo", "..data")
+	require.NoError(t, os.MkdirAll(data, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(data, keys.ServerURL), []byte("https://example.org"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(data, keys.Token), []byte("XYZ"), 0o600))
+	require.NoError(t, os.Symlink(filepath.Join("..data", keys.ServerURL), filepath.Join(dir, "demo", keys.ServerURL)))
+	require.NoError(t, os.Symlink(filepath.Join("..data", keys.Token), filepath.Join(dir, "demo", keys.Token)))
+
+	got, err := NewFileStore(dir).Get(context.Background(), "demo")
+	require.NoError(t, err)
+	assert.Equal(t, Endpoint{ServerURL: "https://example.org", Token: "XYZ"}, got)
+}
+
+func TestFileStoreErrors(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+
+	_, err := store.Get(context.Background(), "missing")
+	assert.True(t, IsNotFound(err))
+
+	_, err = store.Get(context.Background(), "../escape")
+	assert.Error(t, err)
+	assert.False(t, IsNotFound(err))
+
+	err = store.Put(context.Background(), "", Endpoint{})
+	assert.Error(t, err)
+}
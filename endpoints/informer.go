@@ -0,0 +1,182 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/cache"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+var _ Source = (*InformerStore)(nil)
+
+// InformerStoreOptions configures an InformerStore.
+type InformerStoreOptions struct {
+	// Client is used to watch and read the Secrets.
+	Client kubernetes.Interface
+	// Namespace holding the "-clientconfig" Secrets.
+	Namespace string
+	// LabelSelector restricts the Secrets watched by the informer
+	// (default: ClientConfigLabel=true); the other ones are still
+	// read from the API server when requested.
+	LabelSelector string
+	// Resync is the informer resync period (default: 10m).
+	Resync time.Duration
+	// CacheTTL is how long the endpoints read straight from the API
+	// server are reused, until the informer has synced (default: 30s).
+	CacheTTL time.Duration
+	// NotFoundTTL is how long missing Secrets are remembered (default: 10s).
+	NotFoundTTL time.Duration
+	// MaxEntries caps the fallback cache size (default: 4096).
+	MaxEntries int
+	// Keys names the Secret data keys (default: DefaultKeys).
+	Keys Keys
+}
+
+// InformerStore reads the endpoints from a Secrets informer scoped to a
+// single namespace, so that serving a request requires no API calls.
+//
+// Until the informer has synced, and for the Secrets not seen by it
+// (i.e. created a moment ago or not matching the label selector),
+// endpoints are read from the API server and kept in a TTL cache;
+// NotFound results are cached as well.
+//
+// The informer needs the "list" and "watch" verbs on Secrets; when they
+// are forbidden it is stopped, and all lookups hit the API server, only
+// needing "get".
+type InformerStore struct {
+	opts     InformerStoreOptions
+	factory  informers.SharedInformerFactory
+	informer toolscache.SharedIndexInformer
+	lister   listersv1.SecretLister
+	items    *cache.TTLCache[string, lookup]
+
+	// stop stops the informer, set by Start.
+	stop context.CancelFunc
+	// forbidden is set once the informer has been stopped
+	// since the Secrets cannot be listed or watched.
+	forbidden atomic.Bool
+}
+
+// lookup is a cached Get result, either an endpoint or a NotFound.
+type lookup struct {
+	ep    Endpoint
+	found bool
+}
+
+// NewInformerStore returns a store backed by a Secrets informer;
+// call Start to run it, until then all lookups hit the API server.
+func NewInformerStore(opts InformerStoreOptions) *InformerStore {
+	if opts.Resync <= 0 {
+		opts.Resync = 10 * time.Minute
+	}
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = 30 * time.Second
+	}
+	if opts.NotFoundTTL <= 0 {
+		opts.NotFoundTTL = 10 * time.Second
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 4096
+	}
+	opts.Keys = opts.Keys.withDefaults()
+	if opts.LabelSelector == "" {
+		opts.LabelSelector = ClientConfigLabel + "=true"
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(opts.Client, opts.Resync,
+		informers.WithNamespace(opts.Namespace),
+		informers.WithTweakListOptions(func(lo *metav1.ListOptions) {
+			lo.LabelSelector = opts.LabelSelector
+		}))
+	secrets := factory.Core().V1().Secrets()
+
+	s := &InformerStore{
+		opts:     opts,
+		factory:  factory,
+		informer: secrets.Informer(),
+		lister:   secrets.Lister(),
+		items: cache.NewTTL[string, lookup](
+			cache.WithCleanupInterval(0),
+			cache.WithMaxEntries(opts.MaxEntries),
+		),
+	}
+
+	s.informer.SetWatchErrorHandlerWithContext(func(ctx context.Context, r *toolscache.Reflector, err error) {
+		if apierrors.IsForbidden(err) {
+			s.forbidden.Store(true)
+			s.stop()
+			return
+		}
+		toolscache.DefaultWatchErrorHandler(ctx, r, err)
+	})
+
+	return s
+}
+
+// Start runs the informer until the context is canceled;
+// it does not wait for the informer to sync (see WaitForSync).
+func (s *InformerStore) Start(ctx context.Context) {
+	ctx, s.stop = context.WithCancel(ctx)
+	s.factory.Start(ctx.Done())
+
+	go func() {
+		<-ctx.Done()
+		s.factory.Shutdown()
+	}()
+}
+
+// WaitForSync blocks until the informer has synced, or has been
+// stopped since listing the Secrets is forbidden, or the context
+// is canceled.
+func (s *InformerStore) WaitForSync(ctx context.Context) error {
+	ready := func() bool {
+		return s.forbidden.Load() || s.informer.HasSynced()
+	}
+	if !toolscache.WaitForCacheSync(ctx.Done(), ready) {
+		return fmt.Errorf("endpoints informer: %w", ctx.Err())
+	}
+	return nil
+}
+
+func (s *InformerStore) Get(ctx context.Context, name string) (Endpoint, error) {
+	if !s.forbidden.Load() && s.informer.HasSynced() {
+		sec, err := s.lister.Secrets(s.opts.Namespace).Get(name)
+		if err == nil {
+			return s.opts.Keys.decode(sec.Data)
+		}
+		if !apierrors.IsNotFound(err) {
+			return Endpoint{}, err
+		}
+	}
+
+	if res, ok := s.items.Get(name); ok {
+		if !res.found {
+			return Endpoint{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return res.ep, nil
+	}
+
+	sec, err := s.opts.Client.CoreV1().Secrets(s.opts.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			s.items.Set(name, lookup{}, s.opts.NotFoundTTL)
+		}
+		return Endpoint{}, err
+	}
+
+	ep, err := s.opts.Keys.decode(sec.Data)
+	if err != nil {
+		return Endpoint{}, err
+	}
+
+	s.items.Set(name, lookup{ep: ep, found: true}, s.opts.CacheTTL)
+	return ep, nil
+}
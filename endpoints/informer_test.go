@@ -0,0 +1,182 @@
+package endpoints
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func clientConfigSecret(username string, ep Endpoint) *corev1.Secret {
+	data := map[string][]byte{}
+	for k, v := range DefaultKeys().encode(ep) {
+		data[k] = []byte(v)
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ClientConfigName(username),
+			Namespace: "demo-system",
+			Labels:    map[string]string{ClientConfigLabel: "true"},
+		},
+		Data: data,
+	}
+}
+
+// countGets returns the number of Secret GETs sent to the API server.
+func countGets(cli *fake.Clientset) int {
+	res := 0
+	for _, act := range cli.Actions() {
+		if act.GetVerb() == "get" && act.GetResource().Resource == "secrets" {
+			res++
+		}
+	}
+	return res
+}
+
+func TestInformerStoreBeforeSync(t *testing.T) {
+	want := Endpoint{ServerURL: "https://example.org", Token: "XYZ"}
+	cli := fake.NewClientset(clientConfigSecret("cyberjoker", want))
+
+	store := NewInformerStore(InformerStoreOptions{
+		Client:    cli,
+		Namespace: "demo-system",
+	})
+
+	ctx := context.Background()
+	for range 3 {
+		got, err := store.Get(ctx, ClientConfigName("cyberjoker"))
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	assert.Equal(t, 1, countGets(cli), "found endpoints should be cached")
+
+	for range 3 {
+		_, err := store.Get(ctx, ClientConfigName("stranger"))
+		assert.True(t, IsNotFound(err))
+	}
+	assert.Equal(t, 2, countGets(cli), "not found endpoints should be cached")
+}
+
+func TestInformerStoreSynced(t *testing.T) {
+	want := Endpoint{ServerURL: "https://example.org", Token: "XYZ"}
+	cli := fake.NewClientset(clientConfigSecret("cyberjoker", want))
+
+	store := NewInformerStore(InformerStoreOptions{
+		Client:      cli,
+		Namespace:   "demo-system",
+		NotFoundTTL: time.Hour,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store.Start(ctx)
+	require.NoError(t, store.WaitForSync(ctx))
+
+	for range 5 {
+		got, err := store.Get(ctx, ClientConfigName("cyberjoker"))
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	assert.Equal(t, 0, countGets(cli), "synced endpoints should be read from the informer")
+
+	for range 3 {
+		_, err := store.Get(ctx, ClientConfigName("stranger"))
+		assert.True(t, IsNotFound(err))
+	}
+	assert.Equal(t, 1, countGets(cli), "informer misses should be negatively cached")
+
+	// updates are seen through the informer
+	updated := Endpoint{ServerURL: "https://example.org", Token: "ROTATED"}
+	_, err := cli.CoreV1().Secrets("demo-system").Update(ctx,
+		clientConfigSecret("cyberjoker", updated), metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		got, err := store.Get(ctx, ClientConfigName("cyberjoker"))
+		return err == nil && got.Token == "ROTATED"
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, countGets(cli))
+}
+
+func TestInformerStoreAPIErrors(t *testing.T) {
+	cli := fake.NewClientset()
+	cli.PrependReactor("get", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, assert.AnError
+	})
+
+	store := NewInformerStore(InformerStoreOptions{
+		Client:    cli,
+		Namespace: "demo-system",
+	})
+
+	for range 2 {
+		_, err := store.Get(context.Background(), ClientConfigName("cyberjoker"))
+		assert.ErrorIs(t, err, assert.AnError)
+	}
+	assert.Equal(t, 2, countGets(cli), "errors other than NotFound should not be cached")
+}
+
+func TestInformerStoreLabelSelector(t *testing.T) {
+	want := Endpoint{ServerURL: "https://example.org", Token: "XYZ"}
+	unlabeled := clientConfigSecret("stranger", want)
+	unlabeled.Labels = nil
+	other := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "demo-system"}}
+
+	cli := fake.NewClientset(clientConfigSecret("cyberjoker", want), unlabeled, other)
+
+	store := NewInformerStore(InformerStoreOptions{
+		Client:    cli,
+		Namespace: "demo-system",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store.Start(ctx)
+	require.NoError(t, store.WaitForSync(ctx))
+
+	assert.Len(t, store.informer.GetStore().List(), 1, "only labeled Secrets should be watched")
+
+	_, err := store.Get(ctx, ClientConfigName("cyberjoker"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, countGets(cli))
+
+	got, err := store.Get(ctx, ClientConfigName("stranger"))
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+	assert.Equal(t, 1, countGets(cli), "unlabeled Secrets should be read from the API server")
+}
+
+func TestInformerStoreListForbidden(t *testing.T) {
+	want := Endpoint{ServerURL: "https://example.org", Token: "XYZ"}
+	cli := fake.NewClientset(clientConfigSecret("cyberjoker", want))
+	cli.PrependReactor("list", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(corev1.Resource("secrets"), "", assert.AnError)
+	})
+
+	store := NewInformerStore(InformerStoreOptions{
+		Client:    cli,
+		Namespace: "demo-system",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	store.Start(ctx)
+	require.NoError(t, store.WaitForSync(ctx))
+
+	got, err := store.Get(ctx, ClientConfigName("cyberjoker"))
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+	assert.Equal(t, 1, countGets(cli))
+}
@@ -0,0 +1,43 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/krateoplatformops/plumbing/kubeutil"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ErrNotFound is returned by a Source when no endpoint
+// is stored under the requested name.
+var ErrNotFound = errors.New("endpoint not found")
+
+// Source loads endpoints by name.
+type Source interface {
+	Get(ctx context.Context, name string) (Endpoint, error)
+}
+
+// Sink persists endpoints by name.
+type Sink interface {
+	Put(ctx context.Context, name string, ep Endpoint) error
+}
+
+// SourceFunc adapts an ordinary function to the Source interface.
+type SourceFunc func(ctx context.Context, name string) (Endpoint, error)
+
+func (fn SourceFunc) Get(ctx context.Context, name string) (Endpoint, error) {
+	return fn(ctx, name)
+}
+
+// ClientConfigName returns the name under which
+// the endpoint of the specified user is stored.
+func ClientConfigName(username string) string {
+	return fmt.Sprintf("%s-clientconfig", kubeutil.MakeDNS1123Compatible(username))
+}
+
+// IsNotFound reports whether err means that the endpoint does not exist,
+// regardless of the backend that produced it.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound) || apierrors.IsNotFound(err)
+}
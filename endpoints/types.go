@@ -16,8 +16,17 @@ type Endpoint struct {
 	AwsSecretKey string `json:"awsSecretKey"`
 	AwsRegion    string `json:"awsRegion"`
 	AwsService   string `json:"awsService"`
+	// AWS temporary (STS) credentials session token
+	AwsSessionToken string `json:"awsSessionToken,omitempty"`
 	// AWS time field, testing only
 	AwsTime string `json:"awsTime"`
+	// OAuth2 client credentials (or refresh token) flow
+	OAuth2TokenURL     string `json:"oauth2TokenURL,omitempty"`
+	OAuth2ClientID     string `json:"oauth2ClientID,omitempty"`
+	OAuth2ClientSecret string `json:"oauth2ClientSecret,omitempty"`
+	OAuth2Scopes       string `json:"oauth2Scopes,omitempty"` // space delimited
+	OAuth2Audience     string `json:"oauth2Audience,omitempty"`
+	OAuth2RefreshToken string `json:"oauth2RefreshToken,omitempty"`
 }
 
 // HasCA returns whether the configuration has a certificate authority or not.
@@ -45,5 +54,11 @@ func (ep *Endpoint) HasAwsAuth() bool {
 	return len(ep.AwsAccessKey) != 0 &&
 		len(ep.AwsSecretKey) != 0 &&
 		len(ep.AwsRegion) != 0 &&
-		len(ep.AwsSecretKey) != 0
+		len(ep.AwsService) != 0
+}
+
+// HasOAuth2 returns whether the configuration has OAuth2 authentication or not.
+func (ep *Endpoint) HasOAuth2() bool {
+	return len(ep.OAuth2TokenURL) != 0 &&
+		len(ep.OAuth2ClientID) != 0
 }
@@ -0,0 +1,132 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWatchInterval = 30 * time.Second
+)
+
+type WatcherOptions struct {
+	// Source is polled for the endpoint.
+	Source Source
+	// Name of the endpoint to watch (i.e. "<user>-clientconfig").
+	Name string
+	// Interval between two consecutive polls (default: 30s).
+	Interval time.Duration
+	Logger   *slog.Logger
+}
+
+// Watcher keeps an up-to-date copy of a single endpoint,
+// periodically polling its Source and notifying registered
+// listeners whenever the endpoint changes (i.e. a token
+// or a client certificate has been rotated).
+type Watcher struct {
+	src      Source
+	name     string
+	interval time.Duration
+	log      *slog.Logger
+
+	mu        sync.RWMutex
+	current   Endpoint
+	loaded    bool
+	listeners []func(Endpoint)
+}
+
+func NewWatcher(opts WatcherOptions) *Watcher {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultWatchInterval
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+
+	return &Watcher{
+		src:      opts.Source,
+		name:     opts.Name,
+		interval: opts.Interval,
+		log:      opts.Logger,
+	}
+}
+
+// Start loads the endpoint and then keeps polling it in a background
+// goroutine until the context is canceled.
+// An error is returned only if the initial load fails.
+func (w *Watcher) Start(ctx context.Context) error {
+	if w.src == nil {
+		return fmt.Errorf("endpoints watcher: nil source")
+	}
+
+	if err := w.Refresh(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := w.Refresh(ctx); err != nil {
+					w.log.Warn("unable to refresh endpoint",
+						slog.String("name", w.name), slog.Any("err", err))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Refresh reads the endpoint from the source right away,
+// notifying listeners if it differs from the current one.
+func (w *Watcher) Refresh(ctx context.Context) error {
+	ep, err := w.src.Get(ctx, w.name)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	changed := !w.loaded || w.current != ep
+	w.current, w.loaded = ep, true
+	listeners := make([]func(Endpoint), len(w.listeners))
+	copy(listeners, w.listeners)
+	w.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	w.log.Debug("endpoint changed", slog.String("name", w.name))
+	for _, fn := range listeners {
+		fn(ep)
+	}
+
+	return nil
+}
+
+// Current returns the last known endpoint.
+func (w *Watcher) Current() Endpoint {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// OnChange registers a listener invoked, synchronously and in
+// registration order, every time the endpoint changes.
+func (w *Watcher) OnChange(fn func(Endpoint)) {
+	if fn == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, fn)
+}
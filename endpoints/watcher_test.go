@@ -0,0 +1,55 @@
+package endpoints
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := NewFileStore(t.TempDir())
+	require.NoError(t, store.Put(ctx, "demo", Endpoint{ServerURL: "https://example.org", Token: "one"}))
+
+	w := NewWatcher(WatcherOptions{
+		Source:   store,
+		Name:     "demo",
+		Interval: 10 * time.Millisecond,
+	})
+
+	changes := make(chan Endpoint, 1)
+	w.OnChange(func(ep Endpoint) { changes <- ep })
+
+	require.NoError(t, w.Start(ctx))
+	assert.Equal(t, "one", (<-changes).Token)
+	assert.Equal(t, "one", w.Current().Token)
+
+	require.NoError(t, store.Put(ctx, "demo", Endpoint{ServerURL: "https://example.org", Token: "two"}))
+
+	select {
+	case ep := <-changes:
+		assert.Equal(t, "two", ep.Token)
+		assert.Equal(t, "two", w.Current().Token)
+	case <-time.After(2 * time.Second):
+		t.Fatal("change notification not received")
+	}
+
+	// Unchanged endpoints must not be notified.
+	require.NoError(t, w.Refresh(ctx))
+	assert.Empty(t, changes)
+}
+
+func TestWatcherStartFails(t *testing.T) {
+	w := NewWatcher(WatcherOptions{
+		Source: NewFileStore(t.TempDir()),
+		Name:   "missing",
+	})
+
+	err := w.Start(context.Background())
+	assert.True(t, IsNotFound(err))
+}
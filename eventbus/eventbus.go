@@ -2,18 +2,32 @@ package eventbus
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"path"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
 
+// ErrInvalidEvent is returned, wrapped in PublishResult.Err, when
+// an event implementing Validator fails its validation.
+var ErrInvalidEvent = errors.New("eventbus: invalid event")
+
 type EventID string
 
 type Event interface {
 	EventID() EventID
 }
 
+// Validator is implemented by the events whose payload must be checked
+// before being published; invalid events are not delivered.
+type Validator interface {
+	Validate() error
+}
+
 type EventHandler func(ctx context.Context, event Event) error
 
 type Subscription struct {
@@ -24,8 +38,11 @@ type Subscription struct {
 type PublishResult struct {
 	Delivered int
 	Pending   int
-	Errors    []error
-	Err       error
+	// Dropped counts the subscriptions skipped because their queue was
+	// full (see OverflowDrop) or the bus was closed while publishing.
+	Dropped int
+	Errors  []error
+	Err     error
 }
 
 type HandlerFailure struct {
@@ -38,12 +55,30 @@ type HandlerFailure struct {
 type FailureHook func(HandlerFailure)
 
 type BusSubscriber interface {
+	// Subscribe registers a handler for the events with the specified id;
+	// the id can be a glob pattern (see path.Match) where "*" matches any
+	// sequence of characters, i.e. "k8s.discovery.*".
 	Subscribe(eventID EventID, cb EventHandler) Subscription
 	Unsubscribe(id Subscription)
 }
 
+// OptionSubscriber is implemented by the buses returned by New,
+// accepting subscription options (i.e. WithFilter).
+type OptionSubscriber interface {
+	// SubscribeWithOptions is like Subscribe, with options.
+	SubscribeWithOptions(eventID EventID, cb EventHandler, opts ...SubscribeOption) Subscription
+}
+
 type BusPublisher interface {
+	// PublishSync queues the event and waits for its handlers.
+	//
+	// When called by a handler with the context it received, the event
+	// is handled right away in the calling goroutine, bypassing the
+	// queues: waiting for a worker (see WithWorkers), or behind the
+	// running handler, would deadlock.
 	PublishSync(ctx context.Context, event Event) PublishResult
+	// PublishAsync queues the event and returns without waiting for the
+	// handlers; with OverflowBlock, it waits for room in the queues.
 	PublishAsync(ctx context.Context, event Event) <-chan PublishResult
 }
 
@@ -52,6 +87,13 @@ type Bus interface {
 	BusPublisher
 }
 
+// Closer is implemented by the buses returned by New.
+type Closer interface {
+	// Close stops accepting events and waits until the queued ones
+	// have been handled or the context is done.
+	Close(ctx context.Context) error
+}
+
 type Option func(*bus)
 
 func WithPublishTimeout(timeout time.Duration) Option {
@@ -60,6 +102,30 @@ func WithPublishTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithWorkers bounds the goroutines running the handlers (default: 64);
+// workers are started on demand and exit once there is nothing to do.
+func WithWorkers(n int) Option {
+	return func(b *bus) {
+		b.workers = n
+	}
+}
+
+// WithQueueSize sets how many events can be waiting
+// for each subscription (default: 1024).
+func WithQueueSize(n int) Option {
+	return func(b *bus) {
+		b.queueSize = n
+	}
+}
+
+// WithOverflowPolicy sets what happens when an event is published
+// and a subscription queue is full (default: OverflowBlock).
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(b *bus) {
+		b.overflow = policy
+	}
+}
+
 func WithFailureHook(hook FailureHook) Option {
 	return func(b *bus) {
 		b.failureHook = hook
@@ -73,11 +139,14 @@ func SlogFailureHook(logger *slog.Logger) FailureHook {
 
 	return func(failure HandlerFailure) {
 		attrs := []any{
-			slog.String("event_id", string(failure.Subscription.eventID)),
+			slog.String("event_id", string(failure.Event.EventID())),
 			slog.Uint64("subscription_id", failure.Subscription.id),
 			slog.Any("event", failure.Event),
 			slog.Any("err", failure.Err),
 		}
+		if isPattern(failure.Subscription.eventID) {
+			attrs = append(attrs, slog.String("pattern", string(failure.Subscription.eventID)))
+		}
 		if failure.Panic != nil {
 			attrs = append(attrs, slog.Any("panic", failure.Panic))
 		}
@@ -86,21 +155,57 @@ func SlogFailureHook(logger *slog.Logger) FailureHook {
 	}
 }
 
+type SubscribeOption func(*subscriptionInfo)
+
+// WithFilter delivers to the handler only the events matching all
+// the predicates; filters are run by the publisher and must be cheap.
+func WithFilter(fn func(Event) bool) SubscribeOption {
+	return func(info *subscriptionInfo) {
+		if fn != nil {
+			info.filters = append(info.filters, fn)
+		}
+	}
+}
+
 func New(opts ...Option) Bus {
 	b := &bus{
-		infos: make(map[EventID]subscriptionInfoList),
+		infos:     make(map[EventID]subscriptionInfoList),
+		workers:   defaultWorkers,
+		queueSize: defaultQueueSize,
 	}
 	for _, opt := range opts {
 		if opt != nil {
 			opt(b)
 		}
 	}
+	if b.workers <= 0 {
+		b.workers = defaultWorkers
+	}
+	if b.queueSize <= 0 {
+		b.queueSize = defaultQueueSize
+	}
+	b.space = sync.NewCond(&b.qmu)
 	return b
 }
 
 type subscriptionInfo struct {
-	id uint64
-	cb EventHandler
+	id      uint64
+	eventID EventID
+	cb      EventHandler
+	filters []func(Event) bool
+
+	// guarded by bus.qmu
+	queue     []delivery
+	scheduled bool
+}
+
+func (info *subscriptionInfo) accepts(event Event) bool {
+	for _, fn := range info.filters {
+		if !fn(event) {
+			return false
+		}
+	}
+	return true
 }
 
 type subscriptionInfoList []*subscriptionInfo
@@ -111,22 +216,54 @@ type bus struct {
 	publishTimeout time.Duration
 	failureHook    FailureHook
 	infos          map[EventID]subscriptionInfoList
+	patterns       subscriptionInfoList
+
+	workers   int
+	queueSize int
+	overflow  OverflowPolicy
+
+	qmu     sync.Mutex
+	space   *sync.Cond // signaled when a delivery is dequeued or a worker exits
+	ready   subscriptionInfoList
+	running int
+	closed  bool
 }
 
 func (bus *bus) Subscribe(eventID EventID, cb EventHandler) Subscription {
+	return bus.SubscribeWithOptions(eventID, cb)
+}
+
+func (bus *bus) SubscribeWithOptions(eventID EventID, cb EventHandler, opts ...SubscribeOption) Subscription {
 	if cb == nil {
 		panic("eventbus: nil handler")
 	}
+	pattern := isPattern(eventID)
+	if pattern {
+		if _, err := path.Match(string(eventID), ""); err != nil {
+			panic(fmt.Sprintf("eventbus: invalid pattern %q", eventID))
+		}
+	}
+
+	sub := &subscriptionInfo{
+		eventID: eventID,
+		cb:      cb,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(sub)
+		}
+	}
 
 	bus.lock.Lock()
 	defer bus.lock.Unlock()
 	id := bus.nextID
 	bus.nextID++
-	sub := &subscriptionInfo{
-		id: id,
-		cb: cb,
+	sub.id = id
+	if pattern {
+		bus.patterns = append(bus.patterns, sub)
+	} else {
+		bus.infos[eventID] = append(bus.infos[eventID], sub)
 	}
-	bus.infos[eventID] = append(bus.infos[eventID], sub)
 	return Subscription{
 		eventID: eventID,
 		id:      id,
@@ -137,6 +274,13 @@ func (bus *bus) Unsubscribe(subscription Subscription) {
 	bus.lock.Lock()
 	defer bus.lock.Unlock()
 
+	if isPattern(subscription.eventID) {
+		bus.patterns = slices.DeleteFunc(bus.patterns, func(info *subscriptionInfo) bool {
+			return info.id == subscription.id
+		})
+		return
+	}
+
 	if infos, ok := bus.infos[subscription.eventID]; ok {
 		for idx, info := range infos {
 			if info.id == subscription.id {
@@ -152,17 +296,73 @@ func (bus *bus) Unsubscribe(subscription Subscription) {
 	}
 }
 
+// handlerKey marks the contexts passed to the handlers
+// with the bus running them.
+type handlerKey struct{}
+
 func (bus *bus) PublishSync(ctx context.Context, event Event) PublishResult {
+	if ctx != nil && ctx.Value(handlerKey{}) == bus {
+		return bus.publishInline(ctx, event)
+	}
 	return <-bus.PublishAsync(ctx, event)
 }
 
+// publishInline runs the handlers one after the other in the calling
+// goroutine, a handler of the bus, bypassing the queues.
+func (bus *bus) publishInline(ctx context.Context, event Event) PublishResult {
+	if event == nil {
+		panic("eventbus: nil event")
+	}
+	if err := validate(event); err != nil {
+		return PublishResult{Err: err}
+	}
+
+	infos := bus.copySubscriptions(event)
+
+	bus.qmu.Lock()
+	closed := bus.closed
+	bus.qmu.Unlock()
+	if closed {
+		return PublishResult{Dropped: len(infos), Err: ErrClosed}
+	}
+
+	pubCtx, cancel := bus.publishContext(ctx)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	result := PublishResult{}
+	results := make(chan error, 1)
+	for idx, info := range infos {
+		if err := pubCtx.Err(); err != nil {
+			result.Pending = len(infos) - idx
+			result.Err = err
+			break
+		}
+
+		bus.invokeHandler(pubCtx, event, info, results)
+		result.Delivered++
+		if err := <-results; err != nil {
+			result.Errors = append(result.Errors, err)
+		}
+	}
+
+	return result
+}
+
 func (bus *bus) PublishAsync(ctx context.Context, event Event) <-chan PublishResult {
 	if event == nil {
 		panic("eventbus: nil event")
 	}
 
-	infos := bus.copySubscriptions(event.EventID())
 	resultCh := make(chan PublishResult, 1)
+	if err := validate(event); err != nil {
+		resultCh <- PublishResult{Err: err}
+		close(resultCh)
+		return resultCh
+	}
+
+	infos := bus.copySubscriptions(event)
 	if len(infos) == 0 {
 		resultCh <- PublishResult{}
 		close(resultCh)
@@ -172,9 +372,14 @@ func (bus *bus) PublishAsync(ctx context.Context, event Event) <-chan PublishRes
 	pubCtx, cancel := bus.publishContext(ctx)
 	results := make(chan error, len(infos))
 
-	for _, info := range infos {
-		info := info
-		go bus.invokeHandler(pubCtx, event, info, results)
+	queued, dropped, err := bus.enqueue(pubCtx, event, infos, results)
+	if queued == 0 {
+		if cancel != nil {
+			cancel()
+		}
+		resultCh <- PublishResult{Dropped: dropped, Err: err}
+		close(resultCh)
+		return resultCh
 	}
 
 	go func() {
@@ -183,8 +388,8 @@ func (bus *bus) PublishAsync(ctx context.Context, event Event) <-chan PublishRes
 			defer cancel()
 		}
 
-		result := PublishResult{}
-		remaining := len(infos)
+		result := PublishResult{Dropped: dropped, Err: err}
+		remaining := queued
 
 		for remaining > 0 {
 			select {
@@ -225,7 +430,7 @@ func (bus *bus) invokeHandler(
 		if err != nil {
 			bus.handleFailure(HandlerFailure{
 				Subscription: Subscription{
-					eventID: event.EventID(),
+					eventID: info.eventID,
 					id:      info.id,
 				},
 				Event: event,
@@ -236,7 +441,19 @@ func (bus *bus) invokeHandler(
 		results <- err
 	}()
 
-	err = info.cb(ctx, event)
+	err = info.cb(context.WithValue(ctx, handlerKey{}, bus), event)
+}
+
+// validate checks the events implementing Validator.
+func validate(event Event) error {
+	v, ok := event.(Validator)
+	if !ok {
+		return nil
+	}
+	if err := v.Validate(); err != nil {
+		return fmt.Errorf("%w %q: %w", ErrInvalidEvent, event.EventID(), err)
+	}
+	return nil
 }
 
 func (bus *bus) handleFailure(failure HandlerFailure) {
@@ -255,13 +472,25 @@ func (bus *bus) publishContext(parent context.Context) (context.Context, context
 	return context.WithTimeout(parent, bus.publishTimeout)
 }
 
-func (bus *bus) copySubscriptions(eventID EventID) subscriptionInfoList {
+// copySubscriptions returns the subscriptions, exact and by pattern,
+// whose filters accept the event.
+func (bus *bus) copySubscriptions(event Event) subscriptionInfoList {
+	eventID := event.EventID()
+
 	bus.lock.Lock()
-	defer bus.lock.Unlock()
-	if infos, ok := bus.infos[eventID]; ok {
-		cloned := make(subscriptionInfoList, len(infos))
-		copy(cloned, infos)
-		return cloned
+	cloned := slices.Clone(bus.infos[eventID])
+	for _, info := range bus.patterns {
+		if ok, _ := path.Match(string(info.eventID), string(eventID)); ok {
+			cloned = append(cloned, info)
+		}
 	}
-	return subscriptionInfoList{}
+	bus.lock.Unlock()
+
+	return slices.DeleteFunc(cloned, func(info *subscriptionInfo) bool {
+		return !info.accepts(event)
+	})
+}
+
+func isPattern(eventID EventID) bool {
+	return strings.ContainsAny(string(eventID), "*?[")
 }
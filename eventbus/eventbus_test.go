@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -68,3 +69,135 @@ type testEvent struct {
 func (testEvent) EventID() EventID {
 	return "test.event"
 }
+
+func TestPatternSubscriptions(t *testing.T) {
+	bus := New()
+
+	var got []EventID
+	var mu sync.Mutex
+	record := func(ctx context.Context, event Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, event.EventID())
+		return nil
+	}
+
+	sub := bus.Subscribe("k8s.discovery.*", record)
+
+	tests := []struct {
+		event     Event
+		delivered int
+	}{
+		{idEvent("k8s.discovery.resource.added"), 1},
+		{idEvent("k8s.discovery.resource.removed"), 1},
+		{idEvent("helm.release.installed"), 0},
+		{idEvent("k8s.discovery"), 0},
+	}
+	for _, tc := range tests {
+		result := bus.PublishSync(context.Background(), tc.event)
+		require.Equal(t, tc.delivered, result.Delivered, tc.event.EventID())
+	}
+	require.Equal(t, []EventID{"k8s.discovery.resource.added", "k8s.discovery.resource.removed"}, got)
+
+	bus.Unsubscribe(sub)
+	result := bus.PublishSync(context.Background(), idEvent("k8s.discovery.resource.added"))
+	require.Equal(t, 0, result.Delivered)
+}
+
+func TestPatternAndExactSubscriptions(t *testing.T) {
+	bus := New()
+
+	handler := func(ctx context.Context, event Event) error { return nil }
+	bus.Subscribe("*", handler)
+	bus.Subscribe("test.*", handler)
+	bus.Subscribe(testEvent{}.EventID(), handler)
+	bus.Subscribe("other.event", handler)
+
+	result := bus.PublishSync(context.Background(), testEvent{Name: "alpha"})
+	require.Equal(t, 3, result.Delivered)
+}
+
+func TestSubscribeInvalidPattern(t *testing.T) {
+	require.Panics(t, func() {
+		New().Subscribe("test.[", func(ctx context.Context, event Event) error { return nil })
+	})
+}
+
+func TestWithFilter(t *testing.T) {
+	bus := New()
+
+	var got []string
+	bus.(OptionSubscriber).SubscribeWithOptions(testEvent{}.EventID(), func(ctx context.Context, event Event) error {
+		got = append(got, event.(testEvent).Name)
+		return nil
+	},
+		WithFilter(func(event Event) bool { return event.(testEvent).Name != "beta" }),
+		WithFilter(func(event Event) bool { return event.(testEvent).Name != "gamma" }),
+	)
+
+	for _, name := range []string{"alpha", "beta", "gamma", "delta"} {
+		bus.PublishSync(context.Background(), testEvent{Name: name})
+	}
+	require.Equal(t, []string{"alpha", "delta"}, got)
+}
+
+func TestPatternFailureHookReportsSubscription(t *testing.T) {
+	var buf bytes.Buffer
+	var got HandlerFailure
+
+	logHook := SlogFailureHook(slog.New(slog.NewJSONHandler(&buf, nil)))
+	bus := New(WithFailureHook(func(failure HandlerFailure) {
+		got = failure
+		logHook(failure)
+	}))
+
+	sub := bus.Subscribe("test.*", func(ctx context.Context, event Event) error {
+		return errors.New("boom")
+	})
+
+	bus.PublishSync(context.Background(), testEvent{Name: "alpha"})
+	require.Equal(t, sub, got.Subscription)
+	require.Contains(t, buf.String(), `"event_id":"test.event"`)
+	require.Contains(t, buf.String(), `"pattern":"test.*"`)
+}
+
+func TestPublishValidatesEvents(t *testing.T) {
+	bus := New()
+
+	calls := 0
+	bus.Subscribe(validatedEvent{}.EventID(), func(ctx context.Context, event Event) error {
+		calls++
+		return nil
+	})
+
+	result := bus.PublishSync(context.Background(), validatedEvent{})
+	require.ErrorIs(t, result.Err, ErrInvalidEvent)
+	require.ErrorContains(t, result.Err, "name is required")
+	require.Equal(t, 0, result.Delivered)
+
+	result = bus.PublishSync(context.Background(), validatedEvent{Name: "alpha"})
+	require.NoError(t, result.Err)
+	require.Equal(t, 1, result.Delivered)
+	require.Equal(t, 1, calls)
+}
+
+type idEvent EventID
+
+func (e idEvent) EventID() EventID {
+	return EventID(e)
+}
+
+type validatedEvent struct {
+	Name string
+}
+
+func (validatedEvent) EventID() EventID {
+	return "test.validated"
+}
+
+func (e validatedEvent) Validate() error {
+	if e.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
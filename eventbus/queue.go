@@ -0,0 +1,185 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	defaultWorkers   = 64
+	defaultQueueSize = 1024
+)
+
+var (
+	// ErrQueueFull is returned, with OverflowError, when
+	// a subscription queue has no room for the event.
+	ErrQueueFull = errors.New("eventbus: subscription queue is full")
+	// ErrClosed is returned when publishing on a closed bus.
+	ErrClosed = errors.New("eventbus: bus is closed")
+)
+
+// OverflowPolicy tells what to do with an event published
+// when a subscription queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for room in the queue, until the publish
+	// context is done; handlers publishing on their own bus may
+	// deadlock with it when the queues fill up.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDrop skips the subscriptions whose queue is full
+	// (see PublishResult.Dropped).
+	OverflowDrop
+	// OverflowError fails the publish with ErrQueueFull,
+	// without delivering the event to any subscription.
+	OverflowError
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDrop:
+		return "drop"
+	case OverflowError:
+		return "error"
+	default:
+		return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+	}
+}
+
+// delivery is an event waiting in a subscription queue.
+type delivery struct {
+	ctx     context.Context
+	event   Event
+	results chan<- error
+}
+
+// enqueue appends the event to the subscription queues, applying the
+// overflow policy; it returns how many deliveries have been queued and
+// dropped, and the error that stopped it, if any.
+func (bus *bus) enqueue(ctx context.Context, event Event, infos subscriptionInfoList, results chan<- error) (queued, dropped int, err error) {
+	bus.qmu.Lock()
+	defer bus.qmu.Unlock()
+
+	if bus.closed {
+		return 0, len(infos), ErrClosed
+	}
+
+	if bus.overflow == OverflowError {
+		for _, info := range infos {
+			if len(info.queue) >= bus.queueSize {
+				return 0, 0, fmt.Errorf("%w (%q)", ErrQueueFull, info.eventID)
+			}
+		}
+	}
+
+	for idx, info := range infos {
+		if len(info.queue) >= bus.queueSize && bus.overflow == OverflowDrop {
+			dropped++
+			continue
+		}
+
+		for len(info.queue) >= bus.queueSize {
+			if err := bus.waitSpace(ctx); err != nil {
+				return queued, dropped + len(infos) - idx, err
+			}
+		}
+
+		info.queue = append(info.queue, delivery{ctx: ctx, event: event, results: results})
+		queued++
+		bus.schedule(info)
+	}
+
+	return queued, dropped, nil
+}
+
+// waitSpace waits, with bus.qmu held, for a delivery to be dequeued.
+func (bus *bus) waitSpace(ctx context.Context) error {
+	if bus.closed {
+		return ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	stop := context.AfterFunc(ctx, bus.wakeUp)
+	defer stop()
+
+	bus.space.Wait()
+	return nil
+}
+
+func (bus *bus) wakeUp() {
+	bus.qmu.Lock()
+	defer bus.qmu.Unlock()
+	bus.space.Broadcast()
+}
+
+// schedule marks the subscription as ready, starting a worker when
+// the pool is not full; a subscription is either ready or being
+// handled by one worker, so that its events are handled in order.
+func (bus *bus) schedule(info *subscriptionInfo) {
+	if info.scheduled {
+		return
+	}
+	info.scheduled = true
+	bus.ready = append(bus.ready, info)
+
+	if bus.running < bus.workers {
+		bus.running++
+		go bus.work()
+	}
+}
+
+// work handles one event at a time of the ready subscriptions,
+// exiting when there are none left.
+func (bus *bus) work() {
+	bus.qmu.Lock()
+	defer bus.qmu.Unlock()
+
+	for len(bus.ready) > 0 {
+		info := bus.ready[0]
+		bus.ready[0] = nil
+		bus.ready = bus.ready[1:]
+
+		next := info.queue[0]
+		info.queue[0] = delivery{}
+		info.queue = info.queue[1:]
+		bus.space.Broadcast()
+
+		bus.qmu.Unlock()
+		bus.invokeHandler(next.ctx, next.event, info, next.results)
+		bus.qmu.Lock()
+
+		if len(info.queue) > 0 {
+			bus.ready = append(bus.ready, info)
+		} else {
+			info.scheduled = false
+		}
+	}
+
+	bus.running--
+	bus.space.Broadcast()
+}
+
+func (bus *bus) Close(ctx context.Context) error {
+	bus.qmu.Lock()
+	defer bus.qmu.Unlock()
+
+	bus.closed = true
+	// fails the publishers waiting for room
+	bus.space.Broadcast()
+
+	stop := context.AfterFunc(ctx, bus.wakeUp)
+	defer stop()
+
+	for bus.running > 0 {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("eventbus: close: %w", err)
+		}
+		bus.space.Wait()
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkersBound(t *testing.T) {
+	bus := New(WithWorkers(2))
+
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	for range 10 {
+		bus.Subscribe(testEvent{}.EventID(), func(ctx context.Context, event Event) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				cur := peak.Load()
+				if n <= cur || peak.CompareAndSwap(cur, n) {
+					break
+				}
+			}
+			<-release
+			return nil
+		})
+	}
+
+	resultCh := bus.PublishAsync(context.Background(), testEvent{Name: "alpha"})
+	require.Eventually(t, func() bool { return running.Load() == 2 }, time.Second, time.Millisecond)
+	close(release)
+
+	result := <-resultCh
+	require.Equal(t, 10, result.Delivered)
+	require.Equal(t, 0, result.Pending)
+	require.Equal(t, int32(2), peak.Load())
+}
+
+func TestSubscriptionOrder(t *testing.T) {
+	bus := New(WithWorkers(4))
+
+	var mu sync.Mutex
+	got := map[string][]string{}
+	for _, name := range []string{"first", "second"} {
+		bus.Subscribe(testEvent{}.EventID(), func(ctx context.Context, event Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got[name] = append(got[name], event.(testEvent).Name)
+			return nil
+		})
+	}
+
+	var want []string
+	var pending []<-chan PublishResult
+	for i := range 100 {
+		name := fmt.Sprintf("event-%d", i)
+		want = append(want, name)
+		pending = append(pending, bus.PublishAsync(context.Background(), testEvent{Name: name}))
+	}
+	for _, ch := range pending {
+		require.Equal(t, 2, (<-ch).Delivered)
+	}
+
+	require.Equal(t, want, got["first"])
+	require.Equal(t, want, got["second"])
+}
+
+// fillQueue publishes two events on a bus with one worker and a queue
+// of one: the first is being handled until release is closed and
+// the second is waiting in the queue.
+func fillQueue(t *testing.T, bus Bus) (release chan struct{}, pending []<-chan PublishResult) {
+	t.Helper()
+
+	release = make(chan struct{})
+	started := make(chan struct{}, 10)
+	bus.Subscribe(testEvent{}.EventID(), func(ctx context.Context, event Event) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+
+	pending = append(pending, bus.PublishAsync(context.Background(), testEvent{Name: "alpha"}))
+	<-started
+	pending = append(pending, bus.PublishAsync(context.Background(), testEvent{Name: "beta"}))
+	return release, pending
+}
+
+func TestOverflowDrop(t *testing.T) {
+	bus := New(WithWorkers(1), WithQueueSize(1), WithOverflowPolicy(OverflowDrop))
+
+	var others atomic.Int32
+	release, pending := fillQueue(t, bus)
+
+	result := bus.PublishSync(context.Background(), testEvent{Name: "gamma"})
+	require.Equal(t, PublishResult{Dropped: 1}, result)
+
+	bus.Subscribe("test.*", func(ctx context.Context, event Event) error {
+		others.Add(1)
+		return nil
+	})
+	resultCh := bus.PublishAsync(context.Background(), testEvent{Name: "delta"})
+
+	close(release)
+	result = <-resultCh
+	require.Equal(t, 1, result.Delivered)
+	require.Equal(t, 1, result.Dropped)
+	require.Equal(t, int32(1), others.Load())
+
+	for _, ch := range pending {
+		require.Equal(t, 1, (<-ch).Delivered)
+	}
+}
+
+func TestOverflowError(t *testing.T) {
+	bus := New(WithWorkers(1), WithQueueSize(1), WithOverflowPolicy(OverflowError))
+
+	var others atomic.Int32
+	bus.Subscribe("test.*", func(ctx context.Context, event Event) error {
+		others.Add(1)
+		return nil
+	})
+	release, pending := fillQueue(t, bus)
+	defer close(release)
+
+	result := bus.PublishSync(context.Background(), testEvent{Name: "gamma"})
+	require.ErrorIs(t, result.Err, ErrQueueFull)
+	require.Equal(t, 0, result.Delivered)
+	require.Equal(t, 0, result.Dropped)
+
+	// no delivery at all, even to the subscriptions having room
+	require.Never(t, func() bool { return others.Load() > 2 }, 50*time.Millisecond, 5*time.Millisecond)
+	require.Len(t, pending, 2)
+}
+
+func TestOverflowBlock(t *testing.T) {
+	bus := New(WithWorkers(1), WithQueueSize(1))
+
+	release, pending := fillQueue(t, bus)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	result := bus.PublishSync(ctx, testEvent{Name: "gamma"})
+	require.ErrorIs(t, result.Err, context.DeadlineExceeded)
+	require.Equal(t, 1, result.Dropped)
+
+	resultCh := make(chan PublishResult, 1)
+	go func() {
+		resultCh <- bus.PublishSync(context.Background(), testEvent{Name: "delta"})
+	}()
+	select {
+	case <-resultCh:
+		t.Fatal("publish should block while the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	require.Equal(t, PublishResult{Delivered: 1}, <-resultCh)
+	for _, ch := range pending {
+		require.Equal(t, 1, (<-ch).Delivered)
+	}
+}
+
+func TestPublishTimeoutPending(t *testing.T) {
+	bus := New(WithWorkers(1), WithPublishTimeout(20*time.Millisecond))
+
+	release := make(chan struct{})
+	defer close(release)
+	for range 2 {
+		bus.Subscribe(testEvent{}.EventID(), func(ctx context.Context, event Event) error {
+			<-release
+			return nil
+		})
+	}
+
+	result := bus.PublishSync(context.Background(), testEvent{Name: "alpha"})
+	require.ErrorIs(t, result.Err, context.DeadlineExceeded)
+	require.Equal(t, 0, result.Delivered)
+	require.Equal(t, 2, result.Pending)
+}
+
+func TestCloseDrains(t *testing.T) {
+	bus := New(WithWorkers(2))
+
+	var handled atomic.Int32
+	bus.Subscribe(testEvent{}.EventID(), func(ctx context.Context, event Event) error {
+		time.Sleep(time.Millisecond)
+		handled.Add(1)
+		return nil
+	})
+
+	for range 20 {
+		bus.PublishAsync(context.Background(), testEvent{Name: "alpha"})
+	}
+
+	require.NoError(t, bus.(Closer).Close(context.Background()))
+	require.Equal(t, int32(20), handled.Load())
+
+	result := bus.PublishSync(context.Background(), testEvent{Name: "beta"})
+	require.ErrorIs(t, result.Err, ErrClosed)
+	require.Equal(t, 1, result.Dropped)
+}
+
+func TestCloseTimeout(t *testing.T) {
+	bus := New(WithWorkers(1), WithQueueSize(1))
+
+	release, pending := fillQueue(t, bus)
+
+	// a publisher waiting for room fails once the bus is closed
+	blocked := make(chan PublishResult, 1)
+	go func() {
+		blocked <- bus.PublishSync(context.Background(), testEvent{Name: "gamma"})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, bus.(Closer).Close(ctx), context.DeadlineExceeded)
+	require.ErrorIs(t, (<-blocked).Err, ErrClosed)
+
+	close(release)
+	require.NoError(t, bus.(Closer).Close(context.Background()))
+	for _, ch := range pending {
+		require.Equal(t, 1, (<-ch).Delivered)
+	}
+}
+
+func TestNestedPublishSync(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  []string
+	}{
+		{"same subscription", testEvent{Name: "inner"}, []string{"outer", "inner"}},
+		{"other subscription", nestedEvent{}, []string{"outer", "nested"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// a single worker, busy running the outer handler
+			bus := New(WithWorkers(1))
+
+			var got []string
+			bus.Subscribe(testEvent{}.EventID(), func(ctx context.Context, event Event) error {
+				name := event.(testEvent).Name
+				got = append(got, name)
+				if name != "outer" {
+					return nil
+				}
+
+				nested := bus.PublishSync(ctx, tc.event)
+				if nested.Err != nil || nested.Delivered != 1 || len(nested.Errors) > 0 {
+					return fmt.Errorf("nested publish: %+v", nested)
+				}
+				return nil
+			})
+			bus.Subscribe("test.nested", func(ctx context.Context, event Event) error {
+				got = append(got, "nested")
+				return nil
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			result := bus.PublishSync(ctx, testEvent{Name: "outer"})
+			require.NoError(t, result.Err)
+			require.Empty(t, result.Errors)
+			require.Equal(t, 1, result.Delivered)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// nestedEvent is published by the handlers in TestNestedPublishSync.
+type nestedEvent struct{}
+
+func (nestedEvent) EventID() EventID { return "test.nested" }
@@ -0,0 +1,54 @@
+package eventbus
+
+import "context"
+
+// TypedHandler handles the events of type T.
+type TypedHandler[T Event] func(ctx context.Context, event T) error
+
+// SubscribeTyped registers a handler receiving the events as T; the
+// events of other types matching eventID (i.e. when it is a pattern)
+// are skipped, as if filtered out.
+//
+// When the bus is not an OptionSubscriber, the filters are run by
+// the handler, and the skipped events are counted as delivered.
+func SubscribeTyped[T Event](bus BusSubscriber, eventID EventID, cb TypedHandler[T], opts ...SubscribeOption) Subscription {
+	if cb == nil {
+		panic("eventbus: nil handler")
+	}
+
+	opts = append([]SubscribeOption{WithFilter(func(event Event) bool {
+		_, ok := event.(T)
+		return ok
+	})}, opts...)
+
+	handler := func(ctx context.Context, event Event) error {
+		return cb(ctx, event.(T))
+	}
+
+	if sub, ok := bus.(OptionSubscriber); ok {
+		return sub.SubscribeWithOptions(eventID, handler, opts...)
+	}
+
+	// the filters are run by the handler
+	info := &subscriptionInfo{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(info)
+		}
+	}
+	return bus.Subscribe(eventID, func(ctx context.Context, event Event) error {
+		if !info.accepts(event) {
+			return nil
+		}
+		return handler(ctx, event)
+	})
+}
+
+// Where is like WithFilter for the events of type T;
+// events of other types are filtered out.
+func Where[T Event](fn func(T) bool) SubscribeOption {
+	return WithFilter(func(event Event) bool {
+		ev, ok := event.(T)
+		return ok && fn(ev)
+	})
+}
@@ -0,0 +1,105 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeTyped(t *testing.T) {
+	bus := New()
+
+	var got []string
+	bus.Subscribe("test.*", func(ctx context.Context, event Event) error { return nil })
+	sub := SubscribeTyped(bus, testEvent{}.EventID(), func(ctx context.Context, event testEvent) error {
+		got = append(got, event.Name)
+		return nil
+	})
+
+	result := bus.PublishSync(context.Background(), testEvent{Name: "alpha"})
+	require.Equal(t, 2, result.Delivered)
+	require.Equal(t, []string{"alpha"}, got)
+
+	bus.Unsubscribe(sub)
+	result = bus.PublishSync(context.Background(), testEvent{Name: "beta"})
+	require.Equal(t, 1, result.Delivered)
+	require.Equal(t, []string{"alpha"}, got)
+}
+
+func TestSubscribeTypedPatternSkipsOtherTypes(t *testing.T) {
+	bus := New()
+
+	var mu sync.Mutex
+	var got []string
+	SubscribeTyped(bus, "test.*", func(ctx context.Context, event testEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, event.Name)
+		return nil
+	})
+
+	result := bus.PublishSync(context.Background(), validatedEvent{Name: "skipped"})
+	require.Equal(t, 0, result.Delivered)
+	require.Empty(t, result.Errors)
+
+	result = bus.PublishSync(context.Background(), testEvent{Name: "alpha"})
+	require.Equal(t, 1, result.Delivered)
+	require.Equal(t, []string{"alpha"}, got)
+}
+
+func TestSubscribeTypedHandlerError(t *testing.T) {
+	handlerErr := errors.New("handler failed")
+	bus := New()
+
+	SubscribeTyped(bus, testEvent{}.EventID(), func(ctx context.Context, event testEvent) error {
+		return handlerErr
+	})
+
+	result := bus.PublishSync(context.Background(), testEvent{Name: "alpha"})
+	require.Len(t, result.Errors, 1)
+	require.ErrorIs(t, result.Errors[0], handlerErr)
+}
+
+func TestWhere(t *testing.T) {
+	bus := New()
+
+	var mu sync.Mutex
+	var got []EventID
+	bus.(OptionSubscriber).SubscribeWithOptions("test.*", func(ctx context.Context, event Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, event.EventID())
+		return nil
+	}, Where(func(event testEvent) bool { return event.Name == "alpha" }))
+
+	bus.PublishSync(context.Background(), testEvent{Name: "alpha"})
+	bus.PublishSync(context.Background(), testEvent{Name: "beta"})
+	bus.PublishSync(context.Background(), validatedEvent{Name: "alpha"})
+
+	require.Equal(t, []EventID{"test.event"}, got)
+}
+
+// plainSubscriber hides the OptionSubscriber implementation of the bus.
+type plainSubscriber struct{ BusSubscriber }
+
+func TestSubscribeTypedWithoutOptions(t *testing.T) {
+	bus := New()
+
+	var mu sync.Mutex
+	var got []string
+	SubscribeTyped(plainSubscriber{bus}, "test.*", func(ctx context.Context, event testEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, event.Name)
+		return nil
+	}, Where(func(event testEvent) bool { return event.Name != "beta" }))
+
+	bus.PublishSync(context.Background(), testEvent{Name: "alpha"})
+	bus.PublishSync(context.Background(), testEvent{Name: "beta"})
+	bus.PublishSync(context.Background(), validatedEvent{Name: "gamma"})
+
+	require.Equal(t, []string{"alpha"}, got)
+}
@@ -7,14 +7,55 @@ import (
 	"github.com/krateoplatformops/plumbing/endpoints"
 )
 
-func HTTPClientForEndpoint(ep *endpoints.Endpoint, ex *RequestInfo) (*http.Client, error) {
-	rt, err := tlsConfigFor(ep)
+// HTTPClientForEndpoint returns a client configured with the TLS settings,
+// the proxy and the authentication method of the endpoint.
+//
+// The request info is no longer required: AWS requests are signed
+// one by one as they are sent.
+func HTTPClientForEndpoint(ep *endpoints.Endpoint, _ *RequestInfo) (*http.Client, error) {
+	rt, _, err := roundTripperFor(ep)
 	if err != nil {
-		return &http.Client{
-			Transport: &traceIdRoundTripper{defaultTransport()},
-		}, err
+		if rt == nil {
+			return nil, err
+		}
+		return &http.Client{Transport: rt}, err
+	}
+
+	return &http.Client{Transport: rt}, nil
+}
+
+// HTTPClientForWatcher returns a client that always uses the latest
+// credentials of the watched endpoint.
+//
+// The TLS configuration and the authentication headers are swapped
+// in place whenever the watcher reports a change, so long-lived
+// callers can keep reusing the same client across rotations.
+//
+// Unlike HTTPClientForEndpoint it takes no request info:
+// every authentication method, AWS included, is applied
+// per request by the round trippers.
+func HTTPClientForWatcher(w *endpoints.Watcher) (*http.Client, error) {
+	rt, err := newReloadingRoundTripper(w)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{Transport: rt}, nil
+}
+
+// roundTripperFor builds the round tripper chain for the endpoint and
+// returns it along with the underlying transport.
+//
+// When the TLS configuration can not be built, a plain round tripper
+// is returned together with the error.
+func roundTripperFor(ep *endpoints.Endpoint) (http.RoundTripper, *http.Transport, error) {
+	base, err := tlsConfigFor(ep)
+	if err != nil {
+		base = defaultTransport()
+		return &traceIdRoundTripper{base}, base, err
 	}
-	rt = &traceIdRoundTripper{rt}
+
+	var rt http.RoundTripper = &traceIdRoundTripper{base}
 
 	if ep.Debug {
 		rt = &debuggingRoundTripper{
@@ -34,10 +75,13 @@ func HTTPClientForEndpoint(ep *endpoints.Endpoint, ex *RequestInfo) (*http.Clien
 	if ep.HasAwsAuth() {
 		countTrue += 1
 	}
+	if ep.HasOAuth2() {
+		countTrue += 1
+	}
 
 	switch {
 	case countTrue > 1:
-		return nil, fmt.Errorf("only one of username/password, bearer token and AWS must be set")
+		return nil, nil, fmt.Errorf("only one of username/password, bearer token, AWS and OAuth2 must be set")
 
 	case ep.HasTokenAuth():
 		rt = &bearerAuthRoundTripper{
@@ -52,16 +96,15 @@ func HTTPClientForEndpoint(ep *endpoints.Endpoint, ex *RequestInfo) (*http.Clien
 			rt:       rt,
 		}
 
-	case ep.HasAwsAuth():
-		if ex == nil {
-			return nil, fmt.Errorf("extra request options required for AWS authentication")
-		} else {
-			rt = &awsAuthRoundTripper{
-				headerPayload: ComputeAwsSignature(ep, ex),
-				rt:            rt,
-			}
+	case ep.HasOAuth2():
+		rt = &oauth2RoundTripper{
+			src: oauth2TokenSourceFor(ep, base),
+			rt:  rt,
 		}
+
+	case ep.HasAwsAuth():
+		rt = NewAwsRoundTripper(AwsSignerForEndpoint(ep), rt)
 	}
 
-	return &http.Client{Transport: rt}, nil
+	return rt, base, nil
 }
@@ -0,0 +1,131 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	MediaTypeJSON   = "application/json"
+	MediaTypeYAML   = "application/yaml"
+	MediaTypeNDJSON = "application/x-ndjson"
+	MediaTypeText   = "text/plain"
+)
+
+// Decoder turns a response body into a Go value.
+type Decoder func(body io.Reader) (any, error)
+
+// Decoders is a registry of response decoders keyed by media type.
+type Decoders struct {
+	mu    sync.RWMutex
+	items map[string]Decoder
+}
+
+// DefaultDecoders is the registry used by Do when
+// RequestOptions.Decoders is nil.
+var DefaultDecoders = NewDecoders()
+
+// NewDecoders returns a registry with the built-in JSON,
+// YAML, NDJSON and plain text decoders.
+func NewDecoders() *Decoders {
+	res := &Decoders{items: map[string]Decoder{}}
+	res.Register(MediaTypeJSON, decodeJSON)
+	res.Register("text/json", decodeJSON)
+	res.Register(MediaTypeYAML, decodeYAML)
+	res.Register("application/x-yaml", decodeYAML)
+	res.Register("text/yaml", decodeYAML)
+	res.Register(MediaTypeNDJSON, decodeNDJSON)
+	res.Register("application/ndjson", decodeNDJSON)
+	res.Register("application/jsonl", decodeNDJSON)
+	res.Register(MediaTypeText, decodeText)
+	return res
+}
+
+// Register adds (or replaces) the decoder for the specified media type.
+func (d *Decoders) Register(mediaType string, dec Decoder) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.items[strings.ToLower(mediaType)] = dec
+}
+
+// Lookup returns the decoder for the specified Content-Type header value.
+//
+// Media types with a structured syntax suffix (i.e. application/vnd.api+json)
+// fall back to the decoder registered for application/<suffix>.
+func (d *Decoders) Lookup(contentType string) (string, Decoder, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", nil, false
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if dec, ok := d.items[mediaType]; ok {
+		return mediaType, dec, true
+	}
+
+	if idx := strings.LastIndex(mediaType, "+"); idx > 0 {
+		if dec, ok := d.items["application/"+mediaType[idx+1:]]; ok {
+			return mediaType, dec, true
+		}
+	}
+
+	return mediaType, nil, false
+}
+
+// NDJSONStream iterates over newline delimited JSON values.
+//
+// The stream reads directly from the response body,
+// so it must be consumed inside the response handler.
+type NDJSONStream struct {
+	dec *json.Decoder
+}
+
+// Next decodes the next value into v; it returns io.EOF
+// when the stream is exhausted.
+func (s *NDJSONStream) Next(v any) error {
+	return s.dec.Decode(v)
+}
+
+func decodeJSON(body io.Reader) (any, error) {
+	var res any
+	if err := json.NewDecoder(body).Decode(&res); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+func decodeYAML(body io.Reader) (any, error) {
+	dat, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var res any
+	if err := yaml.Unmarshal(dat, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func decodeNDJSON(body io.Reader) (any, error) {
+	return &NDJSONStream{dec: json.NewDecoder(body)}, nil
+}
+
+func decodeText(body io.Reader) (any, error) {
+	dat, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	return string(dat), nil
+}
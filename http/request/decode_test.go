@@ -0,0 +1,174 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/krateoplatformops/plumbing/endpoints"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodersLookup(t *testing.T) {
+	tests := []struct {
+		contentType string
+		mediaType   string
+		found       bool
+	}{
+		{"application/json", "application/json", true},
+		{"application/json; charset=utf-8", "application/json", true},
+		{"application/vnd.api+json", "application/vnd.api+json", true},
+		{"application/YAML", "application/yaml", true},
+		{"application/x-ndjson", "application/x-ndjson", true},
+		{"text/plain; charset=utf-8", "text/plain", true},
+		{"application/octet-stream", "application/octet-stream", false},
+		{"", "", false},
+	}
+
+	dec := NewDecoders()
+	for _, tc := range tests {
+		t.Run(tc.contentType, func(t *testing.T) {
+			mediaType, _, ok := dec.Lookup(tc.contentType)
+			assert.Equal(t, tc.found, ok)
+			assert.Equal(t, tc.mediaType, mediaType)
+		})
+	}
+}
+
+func TestDoDecodedResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        any
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `{"message": "success"}`,
+			want:        map[string]any{"message": "success"},
+		},
+		{
+			name:        "yaml",
+			contentType: "application/yaml",
+			body:        "message: success\n",
+			want:        map[string]any{"message": "success"},
+		},
+		{
+			name:        "text",
+			contentType: "text/plain; charset=utf-8",
+			body:        "hello world",
+			want:        "hello world",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			var got any
+			status := Do(context.Background(), RequestOptions{
+				Endpoint: &endpoints.Endpoint{ServerURL: server.URL},
+				DecodedResponseHandler: func(_ string, v any) error {
+					got = v
+					return nil
+				},
+			})
+			require.Equal(t, http.StatusOK, status.Code, status.Message)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDoDecodedNDJSONStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n"))
+	}))
+	defer server.Close()
+
+	var ids []int
+	status := Do(context.Background(), RequestOptions{
+		Endpoint: &endpoints.Endpoint{ServerURL: server.URL},
+		DecodedResponseHandler: func(mediaType string, v any) error {
+			assert.Equal(t, MediaTypeNDJSON, mediaType)
+
+			stream, ok := v.(*NDJSONStream)
+			require.True(t, ok)
+			for {
+				var item struct {
+					ID int `json:"id"`
+				}
+				err := stream.Next(&item)
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				ids = append(ids, item.ID)
+			}
+		},
+	})
+	require.Equal(t, http.StatusOK, status.Code, status.Message)
+	assert.Equal(t, []int{1, 2, 3}, ids)
+}
+
+func TestDoUnknownContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte("raw"))
+	}))
+	defer server.Close()
+
+	var got string
+	opts := RequestOptions{
+		Endpoint: &endpoints.Endpoint{ServerURL: server.URL},
+		DecodedResponseHandler: func(_ string, v any) error {
+			dat, err := io.ReadAll(v.(io.Reader))
+			got = string(dat)
+			return err
+		},
+	}
+
+	status := Do(context.Background(), opts)
+	assert.Equal(t, http.StatusNotAcceptable, status.Code)
+
+	opts.AllowUnknownContentTypes = true
+	status = Do(context.Background(), opts)
+	assert.Equal(t, http.StatusOK, status.Code)
+	assert.Equal(t, "raw", got)
+}
+
+func TestDoCustomDecoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte("a,b"))
+	}))
+	defer server.Close()
+
+	decoders := NewDecoders()
+	decoders.Register("text/csv", func(body io.Reader) (any, error) {
+		return "csv", nil
+	})
+
+	var got any
+	status := Do(context.Background(), RequestOptions{
+		Endpoint: &endpoints.Endpoint{ServerURL: server.URL},
+		Decoders: decoders,
+		DecodedResponseHandler: func(_ string, v any) error {
+			got = v
+			return nil
+		},
+	})
+	require.Equal(t, http.StatusOK, status.Code, status.Message)
+	assert.Equal(t, "csv", got)
+}
@@ -0,0 +1,287 @@
+package request
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/cache"
+	"github.com/krateoplatformops/plumbing/endpoints"
+)
+
+const (
+	// oauth2ExpiryDelta is how early a token is considered expired,
+	// so that it is refreshed before the upstream rejects it.
+	oauth2ExpiryDelta = 10 * time.Second
+
+	oauth2SourceTTL        = time.Hour
+	oauth2SourceMaxEntries = 1024
+)
+
+// oauth2Sources shares token sources among the short-lived
+// clients created by HTTPClientForEndpoint, so that an access
+// token is fetched once and reused until it expires.
+var (
+	oauth2Sources = cache.NewTTL[string, *oauth2TokenSource](
+		cache.WithCleanupInterval(0),
+		cache.WithMaxEntries(oauth2SourceMaxEntries),
+	)
+	// oauth2SourcesMu makes the lookup and the creation of a source
+	// atomic: two sources for the same endpoint would both spend
+	// the single-use refresh token.
+	oauth2SourcesMu sync.Mutex
+)
+
+type oauth2Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresIn    expiresIn `json:"expires_in,omitempty"`
+
+	expiry time.Time
+}
+
+// expiresIn is the token lifetime in seconds, sent either as a number or,
+// by some providers (i.e. Azure AD v1), as a numeric string.
+type expiresIn int64
+
+func (e *expiresIn) UnmarshalJSON(data []byte) error {
+	if len(data) == 0 || string(data) == "null" || string(data) == `""` {
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	i, err := n.Int64()
+	if err != nil {
+		return err
+	}
+	if i > math.MaxInt32 {
+		i = math.MaxInt32
+	}
+	*e = expiresIn(i)
+	return nil
+}
+
+type oauth2Error struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// oauth2GrantError is returned when the token endpoint
+// rejects the grant with a standard error response.
+type oauth2GrantError struct {
+	status int
+	oauth2Error
+}
+
+func (e *oauth2GrantError) Error() string {
+	return fmt.Sprintf("oauth2: token request failed (%d): %s %s",
+		e.status, e.oauth2Error.Error, e.Description)
+}
+
+// oauth2TokenSource fetches, caches and refreshes access tokens
+// using the client credentials grant or, when a refresh token
+// is available, the refresh token grant.
+type oauth2TokenSource struct {
+	mu           sync.Mutex
+	cli          *http.Client
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       string
+	audience     string
+	refreshToken string
+	tok          *oauth2Token
+	now          func() time.Time
+}
+
+func oauth2TokenSourceFor(ep *endpoints.Endpoint, base http.RoundTripper) *oauth2TokenSource {
+	key := oauth2SourceKey(ep)
+
+	oauth2SourcesMu.Lock()
+	defer oauth2SourcesMu.Unlock()
+
+	if src, ok := oauth2Sources.Get(key); ok {
+		oauth2Sources.Set(key, src, oauth2SourceTTL)
+		return src
+	}
+
+	src := &oauth2TokenSource{
+		cli:          &http.Client{Transport: base, Timeout: 30 * time.Second},
+		tokenURL:     ep.OAuth2TokenURL,
+		clientID:     ep.OAuth2ClientID,
+		clientSecret: ep.OAuth2ClientSecret,
+		scopes:       ep.OAuth2Scopes,
+		audience:     ep.OAuth2Audience,
+		refreshToken: ep.OAuth2RefreshToken,
+		now:          time.Now,
+	}
+	oauth2Sources.Set(key, src, oauth2SourceTTL)
+	return src
+}
+
+func oauth2SourceKey(ep *endpoints.Endpoint) string {
+	hash := sha256.New()
+	for _, el := range []string{
+		ep.OAuth2TokenURL, ep.OAuth2ClientID, ep.OAuth2ClientSecret,
+		ep.OAuth2Scopes, ep.OAuth2Audience, ep.OAuth2RefreshToken,
+	} {
+		hash.Write([]byte(el))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// token returns a valid access token, fetching a new one if needed.
+func (ts *oauth2TokenSource) token(ctx context.Context) (*oauth2Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.tok != nil && ts.valid(ts.tok) {
+		return ts.tok, nil
+	}
+
+	tok, err := ts.retrieve(ctx, ts.refreshToken)
+	if err != nil && len(ts.refreshToken) > 0 {
+		// The refresh token may have been spent already, i.e. rotated
+		// by a source that has since been dropped or by a previous run
+		// of the process: start over with the client credentials.
+		var ge *oauth2GrantError
+		if errors.As(err, &ge) && ge.oauth2Error.Error == "invalid_grant" {
+			ts.refreshToken = ""
+			tok, err = ts.retrieve(ctx, "")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(tok.RefreshToken) > 0 {
+		// Providers may rotate the refresh token on every use.
+		ts.refreshToken = tok.RefreshToken
+	}
+
+	ts.tok = tok
+	return tok, nil
+}
+
+// invalidate drops the cached token if it is still the given one.
+func (ts *oauth2TokenSource) invalidate(tok *oauth2Token) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.tok == tok {
+		ts.tok = nil
+	}
+}
+
+func (ts *oauth2TokenSource) valid(tok *oauth2Token) bool {
+	if len(tok.AccessToken) == 0 {
+		return false
+	}
+	if tok.expiry.IsZero() {
+		return true
+	}
+	return ts.now().Add(oauth2ExpiryDelta).Before(tok.expiry)
+}
+
+// retrieve fetches a token using the refresh token grant,
+// or the client credentials grant when refreshToken is empty.
+func (ts *oauth2TokenSource) retrieve(ctx context.Context, refreshToken string) (*oauth2Token, error) {
+	form := url.Values{}
+	if len(refreshToken) > 0 {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	form.Set("client_id", ts.clientID)
+	if len(ts.clientSecret) > 0 {
+		form.Set("client_secret", ts.clientSecret)
+	}
+	if len(ts.scopes) > 0 {
+		form.Set("scope", ts.scopes)
+	}
+	if len(ts.audience) > 0 {
+		form.Set("audience", ts.audience)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.tokenURL,
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := ts.cli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: cannot fetch token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	dat, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: cannot read token response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		oe := oauth2Error{}
+		if json.Unmarshal(dat, &oe) == nil && len(oe.Error) > 0 {
+			return nil, &oauth2GrantError{status: resp.StatusCode, oauth2Error: oe}
+		}
+		return nil, fmt.Errorf("oauth2: token request failed (%d): %s",
+			resp.StatusCode, string(dat))
+	}
+
+	tok := &oauth2Token{}
+	if err := json.Unmarshal(dat, tok); err != nil {
+		return nil, fmt.Errorf("oauth2: cannot decode token response: %w", err)
+	}
+	if len(tok.AccessToken) == 0 {
+		return nil, fmt.Errorf("oauth2: server response missing access_token")
+	}
+	if tok.ExpiresIn > 0 {
+		tok.expiry = ts.now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	}
+
+	return tok, nil
+}
+
+type oauth2RoundTripper struct {
+	src *oauth2TokenSource
+	rt  http.RoundTripper
+}
+
+func (rt *oauth2RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(req.Header.Get("Authorization")) != 0 {
+		return rt.rt.RoundTrip(req)
+	}
+
+	tok, err := rt.src.token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	req = cloneRequest(req)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tok.AccessToken))
+
+	resp, err := rt.rt.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		// The token may have been revoked: fetch a new one next time.
+		rt.src.invalidate(tok)
+	}
+	return resp, err
+}
@@ -0,0 +1,240 @@
+package request
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/endpoints"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTokenServer struct {
+	*httptest.Server
+	issued atomic.Int32
+	grants chan string
+}
+
+func newFakeTokenServer(t *testing.T, expiresIn int) *fakeTokenServer {
+	ts := &fakeTokenServer{grants: make(chan string, 10)}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("client_id") != "my-client" || r.PostForm.Get("client_secret") != "my-secret" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		assert.Equal(t, "read write", r.PostForm.Get("scope"))
+		assert.Equal(t, "https://api.example.org", r.PostForm.Get("audience"))
+
+		if r.PostForm.Get("grant_type") == "refresh_token" &&
+			!strings.HasPrefix(r.PostForm.Get("refresh_token"), "ref-") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant","error_description":"refresh token already used"}`)
+			return
+		}
+
+		ts.grants <- r.PostForm.Get("grant_type")
+		n := ts.issued.Add(1)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"tok-%d","token_type":"bearer","expires_in":%d,"refresh_token":"ref-%d"}`,
+			n, expiresIn, n)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestOAuth2RoundTripper(t *testing.T) {
+	tokenServer := newFakeTokenServer(t, 3600)
+
+	var got atomic.Value
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.Store(r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer api.Close()
+
+	ep := endpoints.Endpoint{
+		ServerURL:          api.URL,
+		OAuth2TokenURL:     tokenServer.URL,
+		OAuth2ClientID:     "my-client",
+		OAuth2ClientSecret: "my-secret",
+		OAuth2Scopes:       "read write",
+		OAuth2Audience:     "https://api.example.org",
+	}
+
+	for range 3 {
+		// A new client every time, as request.Do does.
+		cli, err := HTTPClientForEndpoint(&ep, nil)
+		require.NoError(t, err)
+
+		resp, err := cli.Get(api.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, "Bearer tok-1", got.Load())
+	}
+
+	assert.Equal(t, int32(1), tokenServer.issued.Load())
+	assert.Equal(t, "client_credentials", <-tokenServer.grants)
+}
+
+func TestOAuth2TokenSourceRefreshesBeforeExpiry(t *testing.T) {
+	tokenServer := newFakeTokenServer(t, 60)
+
+	now := time.Now()
+	src := oauth2TokenSourceFor(&endpoints.Endpoint{
+		OAuth2TokenURL:     tokenServer.URL,
+		OAuth2ClientID:     "my-client",
+		OAuth2ClientSecret: "my-secret",
+		OAuth2Scopes:       "read write",
+		OAuth2Audience:     "https://api.example.org",
+	}, defaultTransport())
+	src.now = func() time.Time { return now }
+
+	tok, err := src.token(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "tok-1", tok.AccessToken)
+	assert.Equal(t, "client_credentials", <-tokenServer.grants)
+
+	// Still valid
+	now = now.Add(30 * time.Second)
+	tok, err = src.token(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "tok-1", tok.AccessToken)
+
+	// Within the expiry delta: refreshed using the issued refresh token
+	now = now.Add(25 * time.Second)
+	tok, err = src.token(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "tok-2", tok.AccessToken)
+	assert.Equal(t, "refresh_token", <-tokenServer.grants)
+}
+
+func TestOAuth2RoundTripperInvalidatesOnUnauthorized(t *testing.T) {
+	tokenServer := newFakeTokenServer(t, 0)
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer tok-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer api.Close()
+
+	ep := endpoints.Endpoint{
+		ServerURL:          api.URL,
+		OAuth2TokenURL:     tokenServer.URL,
+		OAuth2ClientID:     "my-client",
+		OAuth2ClientSecret: "my-secret",
+		OAuth2Scopes:       "read write",
+		OAuth2Audience:     "https://api.example.org",
+	}
+
+	cli, err := HTTPClientForEndpoint(&ep, nil)
+	require.NoError(t, err)
+
+	resp, err := cli.Get(api.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = cli.Get(api.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestOAuth2TokenError(t *testing.T) {
+	tokenServer := newFakeTokenServer(t, 0)
+
+	ep := endpoints.Endpoint{
+		ServerURL:      "http://example.com",
+		OAuth2TokenURL: tokenServer.URL,
+		OAuth2ClientID: "unknown",
+	}
+
+	cli, err := HTTPClientForEndpoint(&ep, nil)
+	require.NoError(t, err)
+
+	_, err = cli.Get(ep.ServerURL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid_client")
+}
+
+func TestOAuth2TokenSourceFallsBackOnSpentRefreshToken(t *testing.T) {
+	tokenServer := newFakeTokenServer(t, 3600)
+
+	// As after an idle period or a restart: the refresh token
+	// in the endpoint has been rotated and spent already.
+	src := oauth2TokenSourceFor(&endpoints.Endpoint{
+		OAuth2TokenURL:     tokenServer.URL,
+		OAuth2ClientID:     "my-client",
+		OAuth2ClientSecret: "my-secret",
+		OAuth2Scopes:       "read write",
+		OAuth2Audience:     "https://api.example.org",
+		OAuth2RefreshToken: "spent",
+	}, defaultTransport())
+
+	tok, err := src.token(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "tok-1", tok.AccessToken)
+	assert.Equal(t, "client_credentials", <-tokenServer.grants)
+	assert.Equal(t, "ref-1", src.refreshToken)
+}
+
+func TestOAuth2TokenSourceForIsShared(t *testing.T) {
+	ep := endpoints.Endpoint{
+		OAuth2TokenURL:     "https://login.example.org/oauth2/token",
+		OAuth2ClientID:     "shared-client",
+		OAuth2RefreshToken: "single-use",
+	}
+
+	const n = 16
+	got := make([]*oauth2TokenSource, n)
+
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got[i] = oauth2TokenSourceFor(&ep, defaultTransport())
+		}()
+	}
+	wg.Wait()
+
+	for _, el := range got {
+		assert.Same(t, got[0], el)
+	}
+}
+
+func TestOAuth2TokenExpiresInString(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"tok-1","token_type":"Bearer","expires_in":"3599"}`)
+	}))
+	defer tokenServer.Close()
+
+	now := time.Now()
+	src := oauth2TokenSourceFor(&endpoints.Endpoint{
+		OAuth2TokenURL:     tokenServer.URL,
+		OAuth2ClientID:     "my-client",
+		OAuth2ClientSecret: "my-secret",
+	}, defaultTransport())
+	src.now = func() time.Time { return now }
+
+	tok, err := src.token(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "tok-1", tok.AccessToken)
+	assert.Equal(t, now.Add(3599*time.Second), tok.expiry)
+}
@@ -0,0 +1,339 @@
+package request
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/krateoplatformops/plumbing/endpoints"
+	"github.com/krateoplatformops/plumbing/http/response"
+	"github.com/krateoplatformops/plumbing/http/util"
+	"github.com/krateoplatformops/plumbing/jqutil"
+)
+
+const defaultMaxPages = 100
+
+// ErrTooManyPages is returned when the iteration
+// stops because MaxPages has been reached.
+var ErrTooManyPages = errors.New("too many pages")
+
+// ErrCrossOriginPage is returned when the strategy points to a page
+// on a scheme or host other than the first one: the endpoint
+// credentials must not be sent there.
+var ErrCrossOriginPage = errors.New("page on another origin")
+
+// StatusError reports a non 2xx response status.
+type StatusError struct {
+	Status *response.Status
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%d: %s", e.Status.Code, e.Status.Message)
+}
+
+// Page is a single page of a paginated response.
+type Page struct {
+	// Number is the page number, starting from 1.
+	Number int
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+}
+
+// Decode unmarshals the JSON page body into v.
+func (p *Page) Decode(v any) error {
+	return json.Unmarshal(p.Body, v)
+}
+
+// PageStrategy tells how to walk a paginated API.
+type PageStrategy interface {
+	// First returns the URL of the first page, or nil when the
+	// strategy cannot walk the API (i.e. for invalid options).
+	First(u *url.URL) *url.URL
+	// Next returns the URL of the page following the specified one,
+	// or nil when there are no more pages.
+	Next(ctx context.Context, page *Page) (*url.URL, error)
+}
+
+type PaginateOptions struct {
+	RequestInfo
+	Endpoint *endpoints.Endpoint
+	Strategy PageStrategy
+	// MaxPages guards against endless iterations (default: 100).
+	MaxPages int
+}
+
+// Paginate returns an iterator over the pages of a paginated API.
+//
+// All the pages share the same HTTP and RetryClient, and so the same
+// rate limiter. The iteration stops on the first error, when the
+// strategy reports no more pages or when the context is canceled.
+//
+// Example usage:
+//
+//	for page, err := range request.Paginate(ctx, request.PaginateOptions{
+//		RequestInfo: request.RequestInfo{Path: "/api/items"},
+//		Endpoint:    ep,
+//		Strategy:    request.NextLink(),
+//	}) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func Paginate(ctx context.Context, opts PaginateOptions) iter.Seq2[*Page, error] {
+	return func(yield func(*Page, error) bool) {
+		if opts.Strategy == nil {
+			yield(nil, errors.New("pagination strategy is required"))
+			return
+		}
+
+		maxPages := opts.MaxPages
+		if maxPages <= 0 {
+			maxPages = defaultMaxPages
+		}
+
+		u, err := endpointURL(opts.Endpoint, opts.Path)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		cli, err := HTTPClientForEndpoint(opts.Endpoint, &opts.RequestInfo)
+		if err != nil {
+			yield(nil, fmt.Errorf("unable to create HTTP Client for endpoint: %w", err))
+			return
+		}
+		retryCli := newRetryClient(opts.Endpoint, cli)
+
+		u = opts.Strategy.First(u)
+		if u == nil {
+			yield(nil, errors.New("pagination strategy returned no first page"))
+			return
+		}
+		origin := *u
+		for num := 1; u != nil; num++ {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			if !strings.EqualFold(u.Scheme, origin.Scheme) || !strings.EqualFold(u.Host, origin.Host) {
+				yield(nil, fmt.Errorf("%w: %s", ErrCrossOriginPage, u.Redacted()))
+				return
+			}
+
+			if num > maxPages {
+				yield(nil, fmt.Errorf("%w: limit is %d", ErrTooManyPages, maxPages))
+				return
+			}
+
+			page, err := fetchPage(ctx, retryCli, u, opts.RequestInfo)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			page.Number = num
+
+			if !yield(page, nil) {
+				return
+			}
+
+			u, err = opts.Strategy.Next(ctx, page)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+	}
+}
+
+func fetchPage(ctx context.Context, retryCli *util.RetryClient, u *url.URL, nfo RequestInfo) (*Page, error) {
+	respo, status := send(ctx, retryCli, u, nfo)
+	if status != nil {
+		return nil, &StatusError{Status: status}
+	}
+	defer respo.Body.Close()
+
+	dat, err := io.ReadAll(respo.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Page{URL: u, Header: respo.Header, Body: dat}, nil
+}
+
+// NextLink follows the RFC 5988 Link header with rel="next".
+// Links to another scheme or host stop the iteration
+// with ErrCrossOriginPage.
+func NextLink() PageStrategy {
+	return nextLinkStrategy{}
+}
+
+type nextLinkStrategy struct{}
+
+func (nextLinkStrategy) First(u *url.URL) *url.URL { return u }
+
+func (nextLinkStrategy) Next(_ context.Context, page *Page) (*url.URL, error) {
+	next, ok := parseNextLink(page.Header)
+	if !ok {
+		return nil, nil
+	}
+
+	ref, err := url.Parse(next)
+	if err != nil {
+		return nil, fmt.Errorf("invalid next link %q: %w", next, err)
+	}
+
+	return page.URL.ResolveReference(ref), nil
+}
+
+// parseNextLink returns the target of the rel="next" link,
+// i.e. Link: <https://api.example.com/items?page=2>; rel="next"
+func parseNextLink(h http.Header) (string, bool) {
+	for _, val := range h.Values("Link") {
+		for _, link := range strings.Split(val, ",") {
+			link = strings.TrimSpace(link)
+			end := strings.Index(link, ">")
+			if !strings.HasPrefix(link, "<") || end < 0 {
+				continue
+			}
+
+			for _, param := range strings.Split(link[end+1:], ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+
+				for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(val), `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return link[1:end], true
+					}
+				}
+			}
+		}
+	}
+
+	return "", false
+}
+
+// JQCursor extracts the next page token from the JSON body using
+// the specified jq query and sends it as the param query parameter.
+// The iteration stops when the query returns null or an empty string.
+func JQCursor(query, param string) PageStrategy {
+	return &jqCursorStrategy{query: query, param: param}
+}
+
+type jqCursorStrategy struct {
+	query string
+	param string
+}
+
+func (s *jqCursorStrategy) First(u *url.URL) *url.URL { return u }
+
+func (s *jqCursorStrategy) Next(ctx context.Context, page *Page) (*url.URL, error) {
+	var data any
+	if err := page.Decode(&data); err != nil {
+		return nil, fmt.Errorf("unable to decode page %d: %w", page.Number, err)
+	}
+
+	tok, err := jqutil.Eval(ctx, jqutil.EvalOptions{
+		Query: s.query, Data: data, Unquote: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tok = strings.TrimSpace(tok)
+	if len(tok) == 0 || tok == "null" {
+		return nil, nil
+	}
+
+	return withQuery(page.URL, map[string]string{s.param: tok}), nil
+}
+
+// OffsetLimit walks the pages using the offset and limit query parameters.
+// The items of each page are selected by the itemsQuery jq expression
+// (default: "."); the iteration stops on the first page holding
+// less than limit items. The limit must be positive: otherwise
+// Paginate fails before sending any request.
+func OffsetLimit(offsetParam, limitParam string, limit int, itemsQuery string) PageStrategy {
+	if len(itemsQuery) == 0 {
+		itemsQuery = "."
+	}
+
+	return &offsetLimitStrategy{
+		offsetParam: offsetParam,
+		limitParam:  limitParam,
+		limit:       limit,
+		itemsQuery:  itemsQuery,
+	}
+}
+
+type offsetLimitStrategy struct {
+	offsetParam string
+	limitParam  string
+	limit       int
+	itemsQuery  string
+}
+
+func (s *offsetLimitStrategy) First(u *url.URL) *url.URL {
+	if s.limit <= 0 {
+		return nil
+	}
+
+	offset := u.Query().Get(s.offsetParam)
+	if len(offset) == 0 {
+		offset = "0"
+	}
+
+	return withQuery(u, map[string]string{
+		s.offsetParam: offset,
+		s.limitParam:  strconv.Itoa(s.limit),
+	})
+}
+
+func (s *offsetLimitStrategy) Next(ctx context.Context, page *Page) (*url.URL, error) {
+	var data any
+	if err := page.Decode(&data); err != nil {
+		return nil, fmt.Errorf("unable to decode page %d: %w", page.Number, err)
+	}
+
+	res, err := jqutil.Eval(ctx, jqutil.EvalOptions{
+		Query: fmt.Sprintf("(%s) | length", s.itemsQuery), Data: data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(res))
+	if err != nil {
+		return nil, fmt.Errorf("query %q must return a JSON array", s.itemsQuery)
+	}
+	if count == 0 || count < s.limit {
+		return nil, nil
+	}
+
+	offset, _ := strconv.Atoi(page.URL.Query().Get(s.offsetParam))
+
+	return withQuery(page.URL, map[string]string{
+		s.offsetParam: strconv.Itoa(offset + count),
+	}), nil
+}
+
+func withQuery(u *url.URL, params map[string]string) *url.URL {
+	res := *u
+	query := res.Query()
+	for k, v := range params {
+		query.Set(k, v)
+	}
+	res.RawQuery = query.Encode()
+	return &res
+}
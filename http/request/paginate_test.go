@@ -0,0 +1,278 @@
+package request
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/endpoints"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collectPages(t *testing.T, opts PaginateOptions) ([]int, error) {
+	t.Helper()
+
+	var items []int
+	for page, err := range Paginate(context.Background(), opts) {
+		if err != nil {
+			return items, err
+		}
+
+		var body struct {
+			Items []int `json:"items"`
+		}
+		require.NoError(t, page.Decode(&body))
+		items = append(items, body.Items...)
+	}
+	return items, nil
+}
+
+func writeItems(w http.ResponseWriter, items []int, extra map[string]any) {
+	body := map[string]any{"items": items}
+	for k, v := range extra {
+		body[k] = v
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func TestPaginateNextLink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if page < 3 {
+			w.Header().Add("Link", fmt.Sprintf(`</items?page=%d>; rel="next", </items?page=3>; rel="last"`, page+1))
+		}
+		writeItems(w, []int{page}, nil)
+	}))
+	defer server.Close()
+
+	items, err := collectPages(t, PaginateOptions{
+		RequestInfo: RequestInfo{Path: "/items"},
+		Endpoint:    &endpoints.Endpoint{ServerURL: server.URL},
+		Strategy:    NextLink(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, items)
+}
+
+func TestPaginateNextLinkCrossOrigin(t *testing.T) {
+	var leaked string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header.Get("Authorization")
+		writeItems(w, []int{2}, nil)
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", fmt.Sprintf(`<%s/items?page=2>; rel="next"`, other.URL))
+		writeItems(w, []int{1}, nil)
+	}))
+	defer server.Close()
+
+	items, err := collectPages(t, PaginateOptions{
+		RequestInfo: RequestInfo{Path: "/items"},
+		Endpoint:    &endpoints.Endpoint{ServerURL: server.URL, Token: "s3cr3t"},
+		Strategy:    NextLink(),
+	})
+	assert.ErrorIs(t, err, ErrCrossOriginPage)
+	assert.Equal(t, []int{1}, items)
+	assert.Empty(t, leaked)
+}
+
+func TestPaginateJQCursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			writeItems(w, []int{1, 2}, map[string]any{"meta": map[string]any{"next": "abc"}})
+		case "abc":
+			writeItems(w, []int{3}, map[string]any{"meta": map[string]any{"next": nil}})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	items, err := collectPages(t, PaginateOptions{
+		RequestInfo: RequestInfo{Path: "/items"},
+		Endpoint:    &endpoints.Endpoint{ServerURL: server.URL},
+		Strategy:    JQCursor(".meta.next", "cursor"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, items)
+}
+
+func TestPaginateOffsetLimit(t *testing.T) {
+	all := []int{1, 2, 3, 4, 5}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		end := min(offset+limit, len(all))
+		writeItems(w, all[offset:end], nil)
+	}))
+	defer server.Close()
+
+	items, err := collectPages(t, PaginateOptions{
+		RequestInfo: RequestInfo{Path: "/items"},
+		Endpoint:    &endpoints.Endpoint{ServerURL: server.URL},
+		Strategy:    OffsetLimit("offset", "limit", 2, ".items"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, all, items)
+}
+
+func TestPaginateOffsetLimitInvalidLimit(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeItems(w, nil, nil)
+	}))
+	defer server.Close()
+
+	for _, limit := range []int{0, -1} {
+		items, err := collectPages(t, PaginateOptions{
+			RequestInfo: RequestInfo{Path: "/items"},
+			Endpoint:    &endpoints.Endpoint{ServerURL: server.URL},
+			Strategy:    OffsetLimit("offset", "limit", limit, ".items"),
+		})
+		assert.Error(t, err)
+		assert.Empty(t, items)
+	}
+	assert.Zero(t, calls)
+}
+
+type noFirstPage struct{ nextLinkStrategy }
+
+func (noFirstPage) First(*url.URL) *url.URL { return nil }
+
+func TestPaginateNoFirstPage(t *testing.T) {
+	items, err := collectPages(t, PaginateOptions{
+		RequestInfo: RequestInfo{Path: "/items"},
+		Endpoint:    &endpoints.Endpoint{ServerURL: "http://localhost"},
+		Strategy:    noFirstPage{},
+	})
+	assert.Error(t, err)
+	assert.Empty(t, items)
+}
+
+func TestPaginateMaxPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", `</items>; rel="next"`)
+		writeItems(w, []int{1}, nil)
+	}))
+	defer server.Close()
+
+	items, err := collectPages(t, PaginateOptions{
+		RequestInfo: RequestInfo{Path: "/items"},
+		Endpoint:    &endpoints.Endpoint{ServerURL: server.URL},
+		Strategy:    NextLink(),
+		MaxPages:    3,
+	})
+	assert.ErrorIs(t, err, ErrTooManyPages)
+	assert.Len(t, items, 3)
+}
+
+func TestPaginateStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := collectPages(t, PaginateOptions{
+		Endpoint: &endpoints.Endpoint{ServerURL: server.URL},
+		Strategy: NextLink(),
+	})
+
+	var se *StatusError
+	require.True(t, errors.As(err, &se))
+	assert.Equal(t, http.StatusNotFound, se.Status.Code)
+}
+
+func TestPaginateContextCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", `</items>; rel="next"`)
+		writeItems(w, []int{1}, nil)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pages := 0
+	var lastErr error
+	for _, err := range Paginate(ctx, PaginateOptions{
+		Endpoint: &endpoints.Endpoint{ServerURL: server.URL},
+		Strategy: NextLink(),
+	}) {
+		if err != nil {
+			lastErr = err
+			break
+		}
+		pages++
+		if pages == 2 {
+			cancel()
+		}
+	}
+
+	assert.Equal(t, 2, pages)
+	assert.ErrorIs(t, lastErr, context.Canceled)
+}
+
+func TestPaginateSharesRateLimiter(t *testing.T) {
+	t.Setenv("CLIENT_QPS", "10")
+	t.Setenv("CLIENT_BURST", "1")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 3 {
+			w.Header().Add("Link", fmt.Sprintf(`</items?page=%d>; rel="next"`, page+1))
+		}
+		writeItems(w, []int{page}, nil)
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	_, err := collectPages(t, PaginateOptions{
+		Endpoint: &endpoints.Endpoint{ServerURL: server.URL},
+		Strategy: NextLink(),
+	})
+	require.NoError(t, err)
+
+	// 4 pages, burst of 1 at 10 QPS: at least 3 waits of 100ms
+	assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond)
+}
+
+func TestParseNextLink(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+		ok   bool
+	}{
+		{`<https://api.example.com/items?page=2>; rel="next"`, "https://api.example.com/items?page=2", true},
+		{`<https://api.example.com/items?page=1>; rel="prev", <https://api.example.com/items?page=3>; rel=next`, "https://api.example.com/items?page=3", true},
+		{`</items?page=2>; title="x"; rel="next last"`, "/items?page=2", true},
+		{`<https://api.example.com/items?page=5>; rel="last"`, "", false},
+		{``, "", false},
+	}
+
+	for _, tc := range tests {
+		h := http.Header{}
+		if len(tc.link) > 0 {
+			h.Set("Link", tc.link)
+		}
+		got, ok := parseNextLink(h)
+		assert.Equal(t, tc.ok, ok, tc.link)
+		assert.Equal(t, tc.want, got, tc.link)
+	}
+}
@@ -0,0 +1,67 @@
+package request
+
+import (
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/krateoplatformops/plumbing/endpoints"
+)
+
+type reloadingState struct {
+	rt   http.RoundTripper
+	base *http.Transport
+}
+
+// reloadingRoundTripper delegates to a round tripper built from the
+// current endpoint, rebuilding it every time the credentials change.
+type reloadingRoundTripper struct {
+	state atomic.Pointer[reloadingState]
+}
+
+func newReloadingRoundTripper(w *endpoints.Watcher) (*reloadingRoundTripper, error) {
+	res := &reloadingRoundTripper{}
+
+	ep := w.Current()
+	if err := res.update(&ep); err != nil {
+		return nil, err
+	}
+
+	w.OnChange(func(ep endpoints.Endpoint) {
+		if err := res.update(&ep); err != nil {
+			slog.Default().Error("unable to reload endpoint credentials",
+				slog.String("serverURL", ep.ServerURL), slog.Any("err", err))
+		}
+	})
+
+	return res, nil
+}
+
+func (rt *reloadingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt.state.Load().rt.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of the current transport.
+func (rt *reloadingRoundTripper) CloseIdleConnections() {
+	if st := rt.state.Load(); st != nil {
+		st.base.CloseIdleConnections()
+	}
+}
+
+// update swaps in a round tripper for the given endpoint.
+// On failure the previous round tripper is kept.
+func (rt *reloadingRoundTripper) update(ep *endpoints.Endpoint) error {
+	next, base, err := roundTripperFor(ep)
+	if err != nil {
+		return err
+	}
+
+	prev := rt.state.Swap(&reloadingState{rt: next, base: base})
+	if prev != nil {
+		// Connections established with the old client
+		// certificate must not be reused.
+		prev.base.CloseIdleConnections()
+	}
+
+	return nil
+}
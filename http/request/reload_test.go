@@ -0,0 +1,80 @@
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/krateoplatformops/plumbing/endpoints"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The signature is part of the public API, keep it stable.
+var _ func(*endpoints.Watcher) (*http.Client, error) = HTTPClientForWatcher
+
+func TestHTTPClientForWatcher(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	store := endpoints.NewFileStore(t.TempDir())
+	require.NoError(t, store.Put(ctx, "demo", endpoints.Endpoint{ServerURL: server.URL, Token: "one"}))
+
+	watcher := endpoints.NewWatcher(endpoints.WatcherOptions{Source: store, Name: "demo"})
+	require.NoError(t, watcher.Refresh(ctx))
+
+	cli, err := HTTPClientForWatcher(watcher)
+	require.NoError(t, err)
+
+	call := func() {
+		resp, err := cli.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	call()
+	assert.Equal(t, "Bearer one", got)
+
+	// Rotate the token
+	require.NoError(t, store.Put(ctx, "demo", endpoints.Endpoint{ServerURL: server.URL, Token: "two"}))
+	require.NoError(t, watcher.Refresh(ctx))
+
+	call()
+	assert.Equal(t, "Bearer two", got)
+
+	// Switch to basic auth
+	require.NoError(t, store.Put(ctx, "demo", endpoints.Endpoint{ServerURL: server.URL, Username: "user", Password: "pass"}))
+	require.NoError(t, watcher.Refresh(ctx))
+
+	call()
+	assert.Equal(t, "Basic dXNlcjpwYXNz", got)
+}
+
+func TestHTTPClientForWatcherKeepsPreviousOnError(t *testing.T) {
+	ctx := context.Background()
+
+	ep := endpoints.Endpoint{ServerURL: "http://example.com", Token: "one"}
+	src := endpoints.SourceFunc(func(context.Context, string) (endpoints.Endpoint, error) {
+		return ep, nil
+	})
+
+	watcher := endpoints.NewWatcher(endpoints.WatcherOptions{Source: src, Name: "demo"})
+	require.NoError(t, watcher.Refresh(ctx))
+
+	cli, err := HTTPClientForWatcher(watcher)
+	require.NoError(t, err)
+	before := cli.Transport.(*reloadingRoundTripper).state.Load()
+
+	// Both bearer and basic auth: invalid
+	ep = endpoints.Endpoint{ServerURL: "http://example.com", Token: "two", Username: "user", Password: "pass"}
+	require.NoError(t, watcher.Refresh(ctx))
+
+	assert.Same(t, before, cli.Transport.(*reloadingRoundTripper).state.Load())
+}
@@ -3,11 +3,11 @@ package request
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"sort"
 	"strings"
 
 	xcontext "github.com/krateoplatformops/plumbing/context"
@@ -21,10 +21,23 @@ const maxUnstructuredResponseTextBytes = 2048
 
 type RequestOptions struct {
 	RequestInfo
-	Endpoint        *endpoints.Endpoint
+	Endpoint *endpoints.Endpoint
+	// ResponseHandler receives the raw response body.
 	ResponseHandler func(io.ReadCloser) error
-	ErrorKey        string
-	ContinueOnError bool
+	// DecodedResponseHandler receives the response body decoded according
+	// to its media type: any for JSON and YAML, string for plain text and
+	// *NDJSONStream for NDJSON. Unknown media types (when allowed) are
+	// passed as io.Reader. Ignored if ResponseHandler is set.
+	DecodedResponseHandler func(mediaType string, v any) error
+	// Cache enables conditional GET requests served from the cache.
+	Cache *ResponseCache
+	// Decoders overrides the DefaultDecoders registry.
+	Decoders *Decoders
+	// AllowUnknownContentTypes accepts responses whose media type
+	// has no registered decoder; by default they are rejected with 406.
+	AllowUnknownContentTypes bool
+	ErrorKey                 string
+	ContinueOnError          bool
 }
 
 type RequestInfo struct {
@@ -35,44 +48,105 @@ type RequestInfo struct {
 }
 
 func Do(ctx context.Context, opts RequestOptions) *response.Status {
-	uri := strings.TrimSuffix(opts.Endpoint.ServerURL, "/")
-	if len(opts.Path) > 0 {
-		uri = fmt.Sprintf("%s/%s", uri, strings.TrimPrefix(opts.Path, "/"))
+	u, err := endpointURL(opts.Endpoint, opts.Path)
+	if err != nil {
+		return response.New(http.StatusInternalServerError, err)
 	}
 
-	u, err := url.Parse(uri)
+	cli, err := HTTPClientForEndpoint(opts.Endpoint, &opts.RequestInfo)
 	if err != nil {
-		return response.New(http.StatusInternalServerError, err)
+		return response.New(http.StatusInternalServerError,
+			fmt.Errorf("unable to create HTTP Client for endpoint: %w", err))
+	}
+
+	if opts.Cache != nil {
+		cli.Transport = opts.Cache.RoundTripper(opts.Endpoint, cli.Transport)
+	}
+
+	// Wrap the existing client in a RetryClient
+	retryCli := newRetryClient(opts.Endpoint, cli)
+
+	respo, status := send(ctx, retryCli, u, opts.RequestInfo)
+	if status != nil {
+		return status
+	}
+	defer respo.Body.Close()
+
+	decoders := opts.Decoders
+	if decoders == nil {
+		decoders = DefaultDecoders
+	}
+
+	ct := respo.Header.Get("Content-Type")
+	mediaType, dec, ok := decoders.Lookup(ct)
+	if !ok && !opts.AllowUnknownContentTypes {
+		return response.New(http.StatusNotAcceptable, fmt.Errorf("content type %q is not allowed", ct))
+	}
+
+	if opts.ResponseHandler != nil {
+		if err := opts.ResponseHandler(respo.Body); err != nil {
+			return response.New(http.StatusInternalServerError, err)
+		}
+		return response.New(http.StatusOK, nil)
+	}
+
+	if opts.DecodedResponseHandler != nil {
+		var val any = respo.Body
+		if ok {
+			val, err = dec(respo.Body)
+			if err != nil {
+				return response.New(http.StatusInternalServerError,
+					fmt.Errorf("unable to decode %q response: %w", mediaType, err))
+			}
+		}
+
+		if err := opts.DecodedResponseHandler(mediaType, val); err != nil {
+			return response.New(http.StatusInternalServerError, err)
+		}
+		return response.New(http.StatusOK, nil)
+	}
+
+	return response.New(http.StatusNoContent, nil)
+}
+
+// newRetryClient returns a RetryClient sharing the rate limiter
+// with all the calls to the same endpoint using the same identity.
+func newRetryClient(ep *endpoints.Endpoint, cli *http.Client) *util.RetryClient {
+	res := util.NewRetryClient(cli)
+	res.LimiterKey = ep.ServerURL
+	if len(ep.Username) > 0 {
+		res.LimiterKey = fmt.Sprintf("%s#%s", ep.ServerURL, ep.Username)
 	}
+	return res
+}
 
-	verb := ptr.Deref(opts.Verb, http.MethodGet)
+func endpointURL(ep *endpoints.Endpoint, path string) (*url.URL, error) {
+	uri := strings.TrimSuffix(ep.ServerURL, "/")
+	if len(path) > 0 {
+		uri = fmt.Sprintf("%s/%s", uri, strings.TrimPrefix(path, "/"))
+	}
+
+	return url.Parse(uri)
+}
+
+// send performs the call returning the response on 2xx status codes;
+// the caller is responsible for closing the response body.
+func send(ctx context.Context, retryCli *util.RetryClient, u *url.URL, nfo RequestInfo) (*http.Response, *response.Status) {
+	verb := ptr.Deref(nfo.Verb, http.MethodGet)
 
 	var body io.Reader
-	if s := ptr.Deref(opts.Payload, ""); len(s) > 0 {
+	if s := ptr.Deref(nfo.Payload, ""); len(s) > 0 {
 		body = strings.NewReader(s)
 	}
 
 	call, err := http.NewRequestWithContext(ctx, verb, u.String(), body)
 	if err != nil {
-		return response.New(http.StatusInternalServerError, err)
-	}
-	// Additional headers for AWS Signature 4 algorithm
-	if opts.Endpoint.HasAwsAuth() {
-		headers, _, _, _, _, _ := ComputeAwsHeaders(opts.Endpoint, &opts.RequestInfo)
-		opts.Headers = append(opts.Headers, headers...)
-		opts.Headers = append(opts.Headers, xcontext.LabelKrateoTraceId+":"+xcontext.TraceId(ctx, true))
-		// Set all headers to lower case for AWS signature
-		for i := range opts.Headers {
-			hParts := strings.Split(opts.Headers[i], ":")
-			opts.Headers[i] = strings.ToLower(strings.Trim(hParts[0], " ")) + ":" + strings.Trim(hParts[1], " ")
-		}
-		sort.Strings(opts.Headers)
-	} else {
-		call.Header.Set(xcontext.LabelKrateoTraceId, xcontext.TraceId(ctx, true))
+		return nil, response.New(http.StatusInternalServerError, err)
 	}
+	call.Header.Set(xcontext.LabelKrateoTraceId, xcontext.TraceId(ctx, true))
 
-	if len(opts.Headers) > 0 {
-		for _, el := range opts.Headers {
+	if len(nfo.Headers) > 0 {
+		for _, el := range nfo.Headers {
 			idx := strings.Index(el, ":")
 			if idx <= 0 {
 				continue
@@ -83,48 +157,32 @@ func Do(ctx context.Context, opts RequestOptions) *response.Status {
 		}
 	}
 
-	cli, err := HTTPClientForEndpoint(opts.Endpoint, &opts.RequestInfo)
-	if err != nil {
-		return response.New(http.StatusInternalServerError,
-			fmt.Errorf("unable to create HTTP Client for endpoint: %w", err))
-	}
-
-	// Wrap the existing client in a RetryClient
-	retryCli := util.NewRetryClient(cli)
-
 	// Use RetryClient instead of the raw client  cli.Do(call)
 	respo, err := retryCli.Do(call)
+	if errors.Is(err, util.ErrCircuitOpen) {
+		return nil, response.New(http.StatusServiceUnavailable, err)
+	}
 	if err != nil {
-		return response.New(http.StatusInternalServerError, err)
+		return nil, response.New(http.StatusInternalServerError, err)
 	}
-	defer respo.Body.Close()
 
 	statusOK := respo.StatusCode >= 200 && respo.StatusCode < 300
 	if !statusOK {
+		defer respo.Body.Close()
+
 		dat, err := io.ReadAll(io.LimitReader(respo.Body, maxUnstructuredResponseTextBytes))
 		if err != nil {
-			return response.New(http.StatusInternalServerError, err)
+			return nil, response.New(http.StatusInternalServerError, err)
 		}
 
 		res := &response.Status{}
 		if err := json.Unmarshal(dat, res); err != nil {
 			res = response.New(respo.StatusCode, fmt.Errorf("%s", string(dat)))
-			return res
+			return nil, res
 		}
 
-		return res
-	}
-
-	if ct := respo.Header.Get("Content-Type"); !strings.Contains(ct, "json") {
-		return response.New(http.StatusNotAcceptable, fmt.Errorf("content type %q is not allowed", ct))
+		return nil, res
 	}
 
-	if opts.ResponseHandler != nil {
-		if err := opts.ResponseHandler(respo.Body); err != nil {
-			return response.New(http.StatusInternalServerError, err)
-		}
-		return response.New(http.StatusOK, nil)
-	}
-
-	return response.New(http.StatusNoContent, nil)
+	return respo, nil
 }
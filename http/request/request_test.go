@@ -35,9 +35,9 @@ func TestDo(t *testing.T) {
 			expectedStatus: http.StatusNoContent,
 		},
 		{
-			name: "Non-JSON response should return 406",
+			name: "Unknown content type should return 406",
 			serverHandler: func(w http.ResponseWriter, r *http.Request) {
-				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Content-Type", "application/octet-stream")
 				w.WriteHeader(http.StatusOK)
 			},
 			opts: RequestOptions{
@@ -100,3 +100,33 @@ func TestDo(t *testing.T) {
 		})
 	}
 }
+
+func TestDoBreakerOpen(t *testing.T) {
+	t.Setenv("CLIENT_BREAKER_ENABLED", "true")
+	t.Setenv("CLIENT_MAX_RETRIES", "1")
+	t.Setenv("CLIENT_BASE_BACKOFF", "1ms")
+	t.Setenv("CLIENT_MAX_BACKOFF", "2ms")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	opts := RequestOptions{
+		Endpoint: &endpoints.Endpoint{ServerURL: server.URL},
+	}
+
+	// The upstream failure is reported as is until the breaker opens
+	status := Do(context.Background(), opts)
+	if status.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status: %d, got: %d", http.StatusInternalServerError, status.Code)
+	}
+
+	// one failure is recorded per call (CLIENT_BREAKER_MIN_REQUESTS)
+	for i := 0; i < 10 && status.Code != http.StatusServiceUnavailable; i++ {
+		status = Do(context.Background(), opts)
+	}
+	if status.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status: %d, got: %d", http.StatusServiceUnavailable, status.Code)
+	}
+}
@@ -0,0 +1,330 @@
+package request
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/cache"
+	"github.com/krateoplatformops/plumbing/endpoints"
+)
+
+const (
+	defaultResponseCacheTTL        = 10 * time.Minute
+	defaultResponseCacheMaxEntries = 1024
+	defaultResponseCacheMaxBody    = 1 << 20
+)
+
+type ResponseCacheOptions struct {
+	// TTL is how long a response is kept for revalidation (default: 10m).
+	TTL time.Duration
+	// MaxEntries caps the number of cached responses (default: 1024).
+	MaxEntries int
+	// MaxBodySize skips caching larger responses (default: 1MiB).
+	MaxBodySize int64
+}
+
+// ResponseCache stores GET responses along with their ETag and
+// Last-Modified validators, so that later requests are sent as
+// conditional requests and served from the cache on 304 Not Modified.
+//
+// Entries are keyed by the endpoint identity (server URL and credentials)
+// and by the Authorization header of the request, so one user never sees
+// the responses fetched by another one; the request headers listed in
+// the response Vary header must match as well.
+type ResponseCache struct {
+	opts  ResponseCacheOptions
+	items *cache.TTLCache[string, *cachedResponse]
+	now   func() time.Time
+}
+
+type cachedResponse struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+	// vary holds the values of the request headers
+	// named by the response Vary header.
+	vary map[string]string
+	// expires is the freshness lifetime given by max-age;
+	// fresh responses are served without contacting the server.
+	expires time.Time
+}
+
+func NewResponseCache(opts ResponseCacheOptions) *ResponseCache {
+	if opts.TTL <= 0 {
+		opts.TTL = defaultResponseCacheTTL
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = defaultResponseCacheMaxEntries
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = defaultResponseCacheMaxBody
+	}
+
+	return &ResponseCache{
+		opts: opts,
+		items: cache.NewTTL[string, *cachedResponse](
+			cache.WithMaxEntries(opts.MaxEntries),
+		),
+		now: time.Now,
+	}
+}
+
+// Close stops the cache cleanup goroutine.
+func (c *ResponseCache) Close() {
+	c.items.Close()
+}
+
+// RoundTripper returns a round tripper caching the responses
+// of the given endpoint.
+func (c *ResponseCache) RoundTripper(ep *endpoints.Endpoint, rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	return &cachingRoundTripper{
+		cache:    c,
+		identity: endpointIdentity(ep),
+		rt:       rt,
+	}
+}
+
+// endpointIdentity hashes everything that tells two users apart.
+func endpointIdentity(ep *endpoints.Endpoint) string {
+	hash := sha256.New()
+	for _, el := range []string{
+		ep.ServerURL, ep.Username, ep.Password, ep.Token,
+		ep.ClientCertificateData, ep.AwsAccessKey, ep.AwsSecretKey,
+		ep.OAuth2TokenURL, ep.OAuth2ClientID, ep.OAuth2RefreshToken,
+	} {
+		hash.Write([]byte(el))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type cachingRoundTripper struct {
+	cache    *ResponseCache
+	identity string
+	rt       http.RoundTripper
+}
+
+func (rt *cachingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || hasCacheDirective(req.Header, "no-store") {
+		return rt.rt.RoundTrip(req)
+	}
+
+	key := rt.key(req)
+
+	entry, ok := rt.cache.items.Get(key)
+	if ok && !entry.matches(req) {
+		entry, ok = nil, false
+	}
+	if ok && rt.cache.now().Before(entry.expires) &&
+		!hasCacheDirective(req.Header, "no-cache") {
+		return entry.response(req), nil
+	}
+
+	if ok {
+		req = cloneRequest(req)
+		if etag := entry.header.Get("ETag"); len(etag) > 0 {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lm := entry.header.Get("Last-Modified"); len(lm) > 0 {
+			req.Header.Set("If-Modified-Since", lm)
+		}
+	}
+
+	resp, err := rt.rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && ok:
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		// Refresh the stored headers (i.e. a new max-age)
+		header := entry.header.Clone()
+		for _, k := range []string{"Cache-Control", "ETag", "Expires", "Last-Modified", "Date"} {
+			if v := resp.Header.Get(k); len(v) > 0 {
+				header.Set(k, v)
+			}
+		}
+		updated := &cachedResponse{
+			statusCode: entry.statusCode,
+			header:     header,
+			body:       entry.body,
+			vary:       entry.vary,
+		}
+		rt.cache.store(key, updated)
+		return updated.response(req), nil
+
+	case resp.StatusCode == http.StatusOK:
+		return rt.cache.maybeStore(key, req, resp)
+	}
+
+	return resp, nil
+}
+
+// key hashes the request identity; the Authorization header is part
+// of it since the caller may set it in place of the endpoint credentials.
+func (rt *cachingRoundTripper) key(req *http.Request) string {
+	hash := sha256.New()
+	for _, el := range []string{
+		rt.identity, req.URL.String(),
+		req.Header.Get("Accept"), req.Header.Get("Authorization"),
+	} {
+		hash.Write([]byte(el))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// maybeStore caches the response if it has validators or a max-age and
+// the server did not forbid it; the returned response has a fresh body.
+func (c *ResponseCache) maybeStore(key string, req *http.Request, resp *http.Response) (*http.Response, error) {
+	vary, ok := varyValues(req, resp.Header)
+	if !ok || hasCacheDirective(resp.Header, "no-store") {
+		c.items.Remove(key)
+		return resp, nil
+	}
+
+	_, hasMaxAge := maxAge(resp.Header)
+	if len(resp.Header.Get("ETag")) == 0 &&
+		len(resp.Header.Get("Last-Modified")) == 0 && !hasMaxAge {
+		return resp, nil
+	}
+
+	dat, err := io.ReadAll(io.LimitReader(resp.Body, c.opts.MaxBodySize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	if int64(len(dat)) > c.opts.MaxBodySize {
+		// Too large: hand it over untouched
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(dat), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	entry := &cachedResponse{
+		statusCode: resp.StatusCode,
+		header:     resp.Header.Clone(),
+		body:       dat,
+		vary:       vary,
+	}
+	c.store(key, entry)
+
+	resp.Body = io.NopCloser(bytes.NewReader(dat))
+	return resp, nil
+}
+
+func (c *ResponseCache) store(key string, entry *cachedResponse) {
+	ttl := c.opts.TTL
+
+	entry.expires = time.Time{}
+	if age, ok := maxAge(entry.header); ok && !hasCacheDirective(entry.header, "no-cache") {
+		entry.expires = c.now().Add(age)
+		if age > ttl {
+			ttl = age
+		}
+	}
+
+	c.items.Set(key, entry, ttl)
+}
+
+func (e *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(e.statusCode) + " " + http.StatusText(e.statusCode),
+		StatusCode:    e.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+// matches reports whether the request carries the same
+// values of the headers the response varies on.
+func (e *cachedResponse) matches(req *http.Request) bool {
+	for name, val := range e.vary {
+		if req.Header.Get(name) != val {
+			return false
+		}
+	}
+	return true
+}
+
+// varyValues returns the request values of the headers named by the
+// response Vary header; it reports false for "Vary: *", meaning that
+// the response cannot be reused.
+func varyValues(req *http.Request, h http.Header) (map[string]string, bool) {
+	var res map[string]string
+	for _, line := range h.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, false
+			}
+			if len(name) == 0 {
+				continue
+			}
+			if res == nil {
+				res = map[string]string{}
+			}
+			res[http.CanonicalHeaderKey(name)] = req.Header.Get(name)
+		}
+	}
+	return res, true
+}
+
+// maxAge returns the Cache-Control max-age value.
+func maxAge(h http.Header) (time.Duration, bool) {
+	for _, dir := range cacheDirectives(h) {
+		key, val, ok := strings.Cut(dir, "=")
+		if !ok || key != "max-age" {
+			continue
+		}
+		secs, err := strconv.Atoi(strings.Trim(val, `"`))
+		if err != nil || secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	return 0, false
+}
+
+func hasCacheDirective(h http.Header, name string) bool {
+	for _, dir := range cacheDirectives(h) {
+		if dir == name || strings.HasPrefix(dir, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+func cacheDirectives(h http.Header) []string {
+	var res []string
+	for _, val := range h.Values("Cache-Control") {
+		for _, dir := range strings.Split(val, ",") {
+			if dir = strings.ToLower(strings.TrimSpace(dir)); len(dir) > 0 {
+				res = append(res, dir)
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,228 @@
+package request
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/krateoplatformops/plumbing/endpoints"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getCached(t *testing.T, c *ResponseCache, ep *endpoints.Endpoint) any {
+	t.Helper()
+
+	var got any
+	status := Do(context.Background(), RequestOptions{
+		Endpoint: ep,
+		Cache:    c,
+		DecodedResponseHandler: func(_ string, v any) error {
+			got = v
+			return nil
+		},
+	})
+	require.Equal(t, http.StatusOK, status.Code, status.Message)
+	return got
+}
+
+func TestResponseCacheETag(t *testing.T) {
+	var hits, notModified atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version": 1}`))
+	}))
+	defer server.Close()
+
+	c := NewResponseCache(ResponseCacheOptions{})
+	defer c.Close()
+
+	ep := &endpoints.Endpoint{ServerURL: server.URL, Token: "XYZ"}
+
+	want := map[string]any{"version": float64(1)}
+	assert.Equal(t, want, getCached(t, c, ep))
+	assert.Equal(t, want, getCached(t, c, ep))
+
+	assert.Equal(t, int32(2), hits.Load())
+	assert.Equal(t, int32(1), notModified.Load())
+}
+
+func TestResponseCacheLastModified(t *testing.T) {
+	const lastModified = "Wed, 21 Oct 2015 07:28:00 GMT"
+
+	var notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Modified-Since") == lastModified {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", lastModified)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	c := NewResponseCache(ResponseCacheOptions{})
+	defer c.Close()
+
+	ep := &endpoints.Endpoint{ServerURL: server.URL}
+	assert.Equal(t, "hello", getCached(t, c, ep))
+	assert.Equal(t, "hello", getCached(t, c, ep))
+	assert.Equal(t, int32(1), notModified.Load())
+}
+
+func TestResponseCacheControl(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		wantHits     int32
+	}{
+		{cacheControl: "max-age=60", wantHits: 1},
+		{cacheControl: "private, max-age=60", wantHits: 1},
+		{cacheControl: "no-cache, max-age=60", wantHits: 2},
+		{cacheControl: "no-store", wantHits: 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.cacheControl, func(t *testing.T) {
+			var hits, conditional atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				if len(r.Header.Get("If-None-Match")) > 0 {
+					conditional.Add(1)
+				}
+				w.Header().Set("Cache-Control", tc.cacheControl)
+				w.Header().Set("ETag", `"v1"`)
+				w.Header().Set("Content-Type", "text/plain")
+				w.Write([]byte("hello"))
+			}))
+			defer server.Close()
+
+			c := NewResponseCache(ResponseCacheOptions{})
+			defer c.Close()
+
+			ep := &endpoints.Endpoint{ServerURL: server.URL}
+			getCached(t, c, ep)
+			getCached(t, c, ep)
+
+			assert.Equal(t, tc.wantHits, hits.Load())
+			if tc.cacheControl == "no-store" {
+				assert.Equal(t, int32(0), conditional.Load())
+			}
+		})
+	}
+}
+
+func TestResponseCacheIsPerUser(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := r.Header.Get("Authorization")
+		etag := fmt.Sprintf("%q", user)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if len(r.Header.Get("If-None-Match")) > 0 {
+			t.Errorf("unexpected validator %q for %q", r.Header.Get("If-None-Match"), user)
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(user))
+	}))
+	defer server.Close()
+
+	c := NewResponseCache(ResponseCacheOptions{})
+	defer c.Close()
+
+	alice := &endpoints.Endpoint{ServerURL: server.URL, Token: "alice"}
+	bob := &endpoints.Endpoint{ServerURL: server.URL, Token: "bob"}
+
+	assert.Equal(t, "Bearer alice", getCached(t, c, alice))
+	assert.Equal(t, "Bearer bob", getCached(t, c, bob))
+	assert.Equal(t, "Bearer alice", getCached(t, c, alice))
+}
+
+func TestResponseCacheIsPerAuthorizationHeader(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	c := NewResponseCache(ResponseCacheOptions{})
+	defer c.Close()
+
+	// one shared endpoint, the token is set by the caller on each request
+	ep := &endpoints.Endpoint{ServerURL: server.URL}
+	get := func(token string) any {
+		var got any
+		status := Do(context.Background(), RequestOptions{
+			RequestInfo: RequestInfo{Headers: []string{"Authorization: Bearer " + token}},
+			Endpoint:    ep,
+			Cache:       c,
+			DecodedResponseHandler: func(_ string, v any) error {
+				got = v
+				return nil
+			},
+		})
+		require.Equal(t, http.StatusOK, status.Code, status.Message)
+		return got
+	}
+
+	assert.Equal(t, "Bearer alice", get("alice"))
+	assert.Equal(t, "Bearer bob", get("bob"))
+	assert.Equal(t, "Bearer alice", get("alice"))
+	assert.Equal(t, int32(2), hits.Load())
+}
+
+func TestResponseCacheVary(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "X-Tenant")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(r.Header.Get("X-Tenant")))
+	}))
+	defer server.Close()
+
+	c := NewResponseCache(ResponseCacheOptions{})
+	defer c.Close()
+
+	ep := &endpoints.Endpoint{ServerURL: server.URL}
+	get := func(tenant string) any {
+		var got any
+		status := Do(context.Background(), RequestOptions{
+			RequestInfo: RequestInfo{Headers: []string{"X-Tenant: " + tenant}},
+			Endpoint:    ep,
+			Cache:       c,
+			DecodedResponseHandler: func(_ string, v any) error {
+				got = v
+				return nil
+			},
+		})
+		require.Equal(t, http.StatusOK, status.Code, status.Message)
+		return got
+	}
+
+	assert.Equal(t, "acme", get("acme"))
+	assert.Equal(t, "acme", get("acme"))
+	assert.Equal(t, int32(1), hits.Load())
+	assert.Equal(t, "globex", get("globex"))
+	assert.Equal(t, int32(2), hits.Load())
+}
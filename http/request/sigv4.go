@@ -0,0 +1,333 @@
+package request
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/endpoints"
+)
+
+// Docs:
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_sigv-create-signed-request.html
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+
+const (
+	awsAlgorithm       = "AWS4-HMAC-SHA256"
+	awsRequestType     = "aws4_request"
+	awsTimeFormatLong  = "20060102T150405Z"
+	awsTimeFormatShort = "20060102"
+
+	awsDateHeader          = "X-Amz-Date"
+	awsSecurityTokenHeader = "X-Amz-Security-Token"
+	awsContentSha256Header = "X-Amz-Content-Sha256"
+
+	// AwsUnsignedPayload can be set as value of the X-Amz-Content-Sha256
+	// header to skip the payload from the signature.
+	AwsUnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// awsIgnoredHeaders are never signed since proxies
+// and transports are allowed to change them.
+var awsIgnoredHeaders = map[string]bool{
+	"authorization":     true,
+	"user-agent":        true,
+	"x-amzn-trace-id":   true,
+	"expect":            true,
+	"connection":        true,
+	"transfer-encoding": true,
+}
+
+// AwsSigner signs HTTP requests using the AWS Signature Version 4 algorithm.
+type AwsSigner struct {
+	AccessKey string
+	SecretKey string
+	// SessionToken is sent as X-Amz-Security-Token when using
+	// temporary (STS) credentials.
+	SessionToken string
+	Region       string
+	Service      string
+	// UnsignedPayload skips hashing the request body,
+	// useful for large or streaming uploads (S3 only).
+	UnsignedPayload bool
+	// Now returns the signing time (default: time.Now).
+	Now func() time.Time
+}
+
+// AwsSignerForEndpoint returns a signer using the AWS credentials of the endpoint.
+func AwsSignerForEndpoint(ep *endpoints.Endpoint) *AwsSigner {
+	res := &AwsSigner{
+		AccessKey:    ep.AwsAccessKey,
+		SecretKey:    ep.AwsSecretKey,
+		SessionToken: ep.AwsSessionToken,
+		Region:       ep.AwsRegion,
+		Service:      ep.AwsService,
+	}
+
+	if len(ep.AwsTime) > 0 {
+		layout := awsTimeFormatLong
+		if len(ep.AwsTime) == len(awsTimeFormatShort) {
+			layout = awsTimeFormatShort
+		}
+		if t, err := time.Parse(layout, ep.AwsTime); err == nil {
+			res.Now = func() time.Time { return t }
+		}
+	}
+
+	return res
+}
+
+// NewAwsRoundTripper returns a round tripper signing every outgoing
+// request that does not already carry an Authorization header.
+func NewAwsRoundTripper(signer *AwsSigner, rt http.RoundTripper) http.RoundTripper {
+	return &awsSigV4RoundTripper{signer: signer, rt: rt}
+}
+
+type awsSigV4RoundTripper struct {
+	signer *AwsSigner
+	rt     http.RoundTripper
+}
+
+func (rt *awsSigV4RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(req.Header.Get("Authorization")) != 0 {
+		return rt.rt.RoundTrip(req)
+	}
+
+	req = cloneRequest(req)
+	if err := rt.signer.Sign(req); err != nil {
+		return nil, err
+	}
+
+	return rt.rt.RoundTrip(req)
+}
+
+// Sign adds the X-Amz-* and Authorization headers to the request.
+//
+// The payload hash is computed on the actual body; when the body
+// can not be re-read (GetBody is nil) it is buffered and replaced.
+// A X-Amz-Content-Sha256 header already set on the request
+// (i.e. UNSIGNED-PAYLOAD) is honored as is.
+func (s *AwsSigner) Sign(req *http.Request) error {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	ts := now().UTC()
+
+	if len(s.SessionToken) > 0 {
+		req.Header.Set(awsSecurityTokenHeader, s.SessionToken)
+	}
+
+	// A Date header set by the caller is the request time,
+	// otherwise the signing time goes into X-Amz-Date.
+	if date, err := http.ParseTime(req.Header.Get("Date")); err == nil {
+		ts = date.UTC()
+	} else {
+		req.Header.Set(awsDateHeader, ts.Format(awsTimeFormatLong))
+	}
+
+	payloadHash := req.Header.Get(awsContentSha256Header)
+	if len(payloadHash) == 0 {
+		if s.UnsignedPayload {
+			payloadHash = AwsUnsignedPayload
+		} else {
+			var err error
+			payloadHash, err = awsPayloadHash(req)
+			if err != nil {
+				return err
+			}
+		}
+
+		// S3 requires the header, other services
+		// only need it for unsigned payloads.
+		if s.Service == "s3" || payloadHash == AwsUnsignedPayload {
+			req.Header.Set(awsContentSha256Header, payloadHash)
+		}
+	}
+
+	signedHeaders, canonicalHeaders := awsCanonicalHeaders(req)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalPath(req, s.Service != "s3"),
+		awsCanonicalQuery(req),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	dateStamp := ts.Format(awsTimeFormatShort)
+	credentialScope := strings.Join([]string{dateStamp, s.Region, s.Service, awsRequestType}, "/")
+
+	hashedCanonicalRequest := sha256.Sum256([]byte(canonicalRequest))
+
+	stringToSign := strings.Join([]string{
+		awsAlgorithm,
+		ts.Format(awsTimeFormatLong),
+		credentialScope,
+		hex.EncodeToString(hashedCanonicalRequest[:]),
+	}, "\n")
+
+	signingKey := getHMAC([]byte("AWS4"+s.SecretKey), []byte(dateStamp))
+	signingKey = getHMAC(signingKey, []byte(s.Region))
+	signingKey = getHMAC(signingKey, []byte(s.Service))
+	signingKey = getHMAC(signingKey, []byte(awsRequestType))
+
+	signature := hex.EncodeToString(getHMAC(signingKey, []byte(stringToSign)))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsAlgorithm, s.AccessKey, credentialScope, signedHeaders, signature))
+
+	return nil
+}
+
+func awsPayloadHash(req *http.Request) (string, error) {
+	hash := sha256.New()
+	if req.Body == nil || req.Body == http.NoBody {
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+
+		if _, err := io.Copy(hash, body); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	// Streaming body: buffer it so it can be both hashed and sent.
+	dat, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(dat))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(dat)), nil
+	}
+	req.ContentLength = int64(len(dat))
+
+	hash.Write(dat)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func awsCanonicalPath(req *http.Request, normalize bool) string {
+	res := req.URL.EscapedPath()
+	if res == "" {
+		return "/"
+	}
+	if !normalize {
+		return res
+	}
+
+	trailing := strings.HasSuffix(res, "/")
+	res = path.Clean(res)
+	if trailing && res != "/" {
+		res += "/"
+	}
+	return res
+}
+
+func awsCanonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(query))
+	for _, k := range keys {
+		vals := append([]string(nil), query[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+func awsCanonicalHeaders(req *http.Request) (string, string) {
+	all := map[string][]string{
+		"host": {awsHost(req)},
+	}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if k == "host" || awsIgnoredHeaders[k] {
+			continue
+		}
+		all[k] = append(all[k], v...)
+	}
+
+	keys := make([]string, 0, len(all))
+	for k := range all {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		vals := make([]string, len(all[k]))
+		for i, v := range all[k] {
+			vals[i] = strings.Join(strings.Fields(v), " ")
+		}
+		sb.WriteString(k)
+		sb.WriteByte(':')
+		sb.WriteString(strings.Join(vals, ","))
+		sb.WriteByte('\n')
+	}
+
+	return strings.Join(keys, ";"), sb.String()
+}
+
+// awsHost returns the request host without the default port.
+func awsHost(req *http.Request) string {
+	host := req.Host
+	if len(host) == 0 {
+		host = req.URL.Host
+	}
+
+	h, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return host
+	}
+	if (port == "80" && req.URL.Scheme == "http") ||
+		(port == "443" && req.URL.Scheme == "https") {
+		return h
+	}
+	return host
+}
+
+// awsEscape encodes everything but the RFC 3986 unreserved characters.
+func awsEscape(s string) string {
+	const hex = "0123456789ABCDEF"
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			sb.WriteByte(c)
+			continue
+		}
+		sb.WriteByte('%')
+		sb.WriteByte(hex[c>>4])
+		sb.WriteByte(hex[c&15])
+	}
+	return sb.String()
+}
@@ -13,9 +13,14 @@ import (
 
 	xcontext "github.com/krateoplatformops/plumbing/context"
 	"github.com/krateoplatformops/plumbing/endpoints"
+	"github.com/krateoplatformops/plumbing/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func tlsConfigFor(ep *endpoints.Endpoint) (http.RoundTripper, error) {
+func tlsConfigFor(ep *endpoints.Endpoint) (*http.Transport, error) {
 	res := defaultTransport()
 
 	if ep.Insecure {
@@ -108,14 +113,43 @@ type traceIdRoundTripper struct {
 	delegatedRoundTripper http.RoundTripper
 }
 
+// RoundTrip sends the Krateo trace id along with the W3C trace context
+// of a new OpenTelemetry client span, child of the span found in the
+// request context; the sampling decision of the parent is kept.
 func (rt *traceIdRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	traceId := req.Header.Get(xcontext.LabelKrateoTraceId)
 	if len(traceId) == 0 {
 		traceId = xcontext.TraceId(req.Context(), true)
 	}
+
+	ctx := tracing.ContextWithKrateoTraceId(req.Context(), traceId)
+	ctx, span := tracing.Tracer().Start(ctx, req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Hostname()),
+			attribute.String("url.path", req.URL.Path),
+			attribute.String("krateo.trace_id", traceId),
+		))
+	defer span.End()
+
+	req = cloneRequest(req).WithContext(ctx)
 	req.Header.Set(xcontext.LabelKrateoTraceId, traceId)
+	tracing.Propagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := rt.delegatedRoundTripper.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
 
-	return rt.delegatedRoundTripper.RoundTrip(req)
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+
+	return resp, nil
 }
 
 type debuggingRoundTripper struct {
@@ -177,20 +211,3 @@ func (rt *bearerAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response,
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	return rt.rt.RoundTrip(req)
 }
-
-type awsAuthRoundTripper struct {
-	headerPayload string
-	rt            http.RoundTripper
-}
-
-func (rt *awsAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	if len(req.Header.Get("Authorization")) != 0 {
-		return rt.rt.RoundTrip(req)
-	}
-
-	req = cloneRequest(req)
-	token := rt.headerPayload
-
-	req.Header.Set("Authorization", token)
-	return rt.rt.RoundTrip(req)
-}
@@ -1,6 +1,7 @@
 package request
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
@@ -18,9 +19,17 @@ import (
 	"testing"
 	"time"
 
+	xcontext "github.com/krateoplatformops/plumbing/context"
 	"github.com/krateoplatformops/plumbing/endpoints"
+	"github.com/krateoplatformops/plumbing/tracing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestParseProxyURL(t *testing.T) {
@@ -243,3 +252,95 @@ func generateSelfSignedCert() (certPEM []byte, keyPEM []byte, tlsCert tls.Certif
 
 	return certPEM, keyPEM, tlsCert
 }
+
+// inMemoryTracer installs a TracerProvider recording
+// the spans in memory until the end of the test.
+func inMemoryTracer(t *testing.T) *tracetest.InMemoryExporter {
+	exp := tracetest.NewInMemoryExporter()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return exp
+}
+
+func extractSpanContext(h http.Header) trace.SpanContext {
+	ctx := propagation.TraceContext{}.Extract(context.Background(), propagation.HeaderCarrier(h))
+	return trace.SpanContextFromContext(ctx)
+}
+
+func TestDoPropagatesTraceContext(t *testing.T) {
+	exp := inMemoryTracer(t)
+
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	parent := extractSpanContext(http.Header{
+		"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	})
+	require.True(t, parent.IsValid())
+
+	ctx := xcontext.BuildContext(context.Background(), xcontext.WithTraceId("p0Vq1DSxR"))
+	ctx = trace.ContextWithRemoteSpanContext(ctx, parent)
+
+	status := Do(ctx, RequestOptions{
+		Endpoint: &endpoints.Endpoint{ServerURL: server.URL},
+	})
+	require.Equal(t, http.StatusNoContent, status.Code, status.Message)
+
+	assert.Equal(t, "p0Vq1DSxR", got.Get(xcontext.LabelKrateoTraceId))
+
+	sc := extractSpanContext(got)
+	require.True(t, sc.IsValid())
+	assert.Equal(t, parent.TraceID(), sc.TraceID())
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind)
+	assert.Equal(t, sc.SpanID(), spans[0].SpanContext.SpanID())
+	assert.Equal(t, parent.SpanID(), spans[0].Parent.SpanID())
+	assert.Contains(t, spans[0].Attributes, attribute.Int("http.response.status_code", http.StatusOK))
+}
+
+func TestDoKeepsUnsampledTraceContext(t *testing.T) {
+	exp := inMemoryTracer(t)
+
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+	}))
+	defer server.Close()
+
+	parent := extractSpanContext(http.Header{
+		"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+	})
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), parent)
+	Do(ctx, RequestOptions{Endpoint: &endpoints.Endpoint{ServerURL: server.URL}})
+
+	sc := extractSpanContext(got)
+	require.True(t, sc.IsValid())
+	assert.Equal(t, parent.TraceID(), sc.TraceID())
+	assert.False(t, sc.IsSampled())
+	assert.Empty(t, exp.GetSpans())
+}
+
+func TestDoDerivesTraceFromKrateoTraceId(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+	}))
+	defer server.Close()
+
+	ctx := xcontext.BuildContext(context.Background(), xcontext.WithTraceId("p0Vq1DSxR"))
+	Do(ctx, RequestOptions{Endpoint: &endpoints.Endpoint{ServerURL: server.URL}})
+
+	sc := extractSpanContext(got)
+	require.True(t, sc.IsValid())
+	assert.Equal(t, tracing.TraceIDFromString("p0Vq1DSxR"), sc.TraceID())
+}
@@ -52,6 +52,9 @@ func isTextResponse(resp *http.Response) bool {
 	return strings.HasPrefix(media, "text/")
 }
 
+// ComputeAwsHeaders returns the headers required by the AWS Signature 4 algorithm.
+//
+// Deprecated: requests are now signed as they are sent, see AwsSigner.
 func ComputeAwsHeaders(ep *endpoints.Endpoint, ex *RequestInfo) ([]string, string, string, string, string, string) {
 	var dateStamp, amzDate string
 	if len(ep.AwsTime) != 0 {
@@ -94,6 +97,9 @@ func ComputeAwsHeaders(ep *endpoints.Endpoint, ex *RequestInfo) ([]string, strin
 	}, dateStamp, amzDate, host, canonicalURI, payloadHash
 }
 
+// ComputeAwsSignature returns the AWS Signature 4 Authorization header value.
+//
+// Deprecated: it ignores the query string and the session token, use AwsSigner.
 func ComputeAwsSignature(ep *endpoints.Endpoint, ex *RequestInfo) string {
 	// Docs:
 	// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-authenticating-requests.html
@@ -37,6 +37,22 @@ func Forbidden(w http.ResponseWriter, err error) error {
 	return Encode(w, New(http.StatusForbidden, err))
 }
 
+func RequestEntityTooLarge(w http.ResponseWriter, err error) error {
+	return Encode(w, New(http.StatusRequestEntityTooLarge, err))
+}
+
+func UnsupportedMediaType(w http.ResponseWriter, err error) error {
+	return Encode(w, New(http.StatusUnsupportedMediaType, err))
+}
+
+func TooManyRequests(w http.ResponseWriter, err error) error {
+	return Encode(w, New(http.StatusTooManyRequests, err))
+}
+
+func GatewayTimeout(w http.ResponseWriter, err error) error {
+	return Encode(w, New(http.StatusGatewayTimeout, err))
+}
+
 func Encode(w http.ResponseWriter, status *Status) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status.Code)
@@ -265,6 +265,134 @@ func TestForbidden(t *testing.T) {
 	}
 }
 
+func TestRequestEntityTooLarge(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		expected   string
+		statusCode int
+	}{
+		{
+			name:       "Request entity too large error",
+			err:        errors.New("body too large"),
+			expected:   `{"apiVersion":"v1", "code":413, "kind":"Status", "message":"body too large", "reason":"RequestEntityTooLarge", "status":"Failure"}`,
+			statusCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Mock ResponseWriter
+			var buf bytes.Buffer
+			w := &mockResponseWriter{buf: &buf}
+
+			// Call the RequestEntityTooLarge function
+			err := RequestEntityTooLarge(w, tt.err)
+			assert.NoError(t, err)
+
+			// Check the response
+			assert.Equal(t, tt.statusCode, w.statusCode)
+			assert.JSONEq(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestUnsupportedMediaType(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		expected   string
+		statusCode int
+	}{
+		{
+			name:       "Unsupported media type error",
+			err:        errors.New("unsupported content type"),
+			expected:   `{"apiVersion":"v1", "code":415, "kind":"Status", "message":"unsupported content type", "reason":"UnsupportedMediaType", "status":"Failure"}`,
+			statusCode: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Mock ResponseWriter
+			var buf bytes.Buffer
+			w := &mockResponseWriter{buf: &buf}
+
+			// Call the UnsupportedMediaType function
+			err := UnsupportedMediaType(w, tt.err)
+			assert.NoError(t, err)
+
+			// Check the response
+			assert.Equal(t, tt.statusCode, w.statusCode)
+			assert.JSONEq(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestGatewayTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		expected   string
+		statusCode int
+	}{
+		{
+			name:       "Gateway timeout error",
+			err:        errors.New("request timed out"),
+			expected:   `{"apiVersion":"v1", "code":504, "kind":"Status", "message":"request timed out", "reason":"Timeout", "status":"Failure"}`,
+			statusCode: http.StatusGatewayTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Mock ResponseWriter
+			var buf bytes.Buffer
+			w := &mockResponseWriter{buf: &buf}
+
+			// Call the GatewayTimeout function
+			err := GatewayTimeout(w, tt.err)
+			assert.NoError(t, err)
+
+			// Check the response
+			assert.Equal(t, tt.statusCode, w.statusCode)
+			assert.JSONEq(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestTooManyRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		expected   string
+		statusCode int
+	}{
+		{
+			name:       "Too many requests error",
+			err:        errors.New("rate limit exceeded"),
+			expected:   `{"apiVersion":"v1", "code":429, "kind":"Status", "message":"rate limit exceeded", "reason":"TooManyRequests", "status":"Failure"}`,
+			statusCode: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Mock ResponseWriter
+			var buf bytes.Buffer
+			w := &mockResponseWriter{buf: &buf}
+
+			// Call the TooManyRequests function
+			err := TooManyRequests(w, tt.err)
+			assert.NoError(t, err)
+
+			// Check the response
+			assert.Equal(t, tt.statusCode, w.statusCode)
+			assert.JSONEq(t, tt.expected, buf.String())
+		})
+	}
+}
+
 // Mock ResponseWriter
 type mockResponseWriter struct {
 	headers    http.Header
@@ -167,6 +167,12 @@ func New(code int, err error) *Status {
 	case http.StatusUnsupportedMediaType:
 		res.Status = StatusFailure
 		res.Reason = StatusReasonUnsupportedMediaType
+	case http.StatusTooManyRequests:
+		res.Status = StatusFailure
+		res.Reason = StatusReasonTooManyRequests
+	case http.StatusGatewayTimeout:
+		res.Status = StatusFailure
+		res.Reason = StatusReasonTimeout
 	default:
 		res.Status = StatusSuccess
 	}
@@ -0,0 +1,262 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/env"
+)
+
+// ErrCircuitOpen is returned when a request is rejected
+// because the circuit breaker of the target host is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// StateClosed lets all the requests through.
+	StateClosed BreakerState = iota
+	// StateOpen rejects all the requests until the cool-down expires.
+	StateOpen
+	// StateHalfOpen lets a few probe requests through.
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+type BreakerOptions struct {
+	// FailureRatio opens the breaker when failures/requests reaches it.
+	FailureRatio float64
+	// MinRequests is the minimum number of requests in the
+	// window before the failure ratio is evaluated.
+	MinRequests int
+	// Window is the interval after which the counters are reset.
+	Window time.Duration
+	// CoolDown is how long the breaker stays open.
+	CoolDown time.Duration
+	// HalfOpenRequests is the number of probe requests allowed
+	// while half-open.
+	HalfOpenRequests int
+}
+
+// BreakerOptionsFromEnv returns the breaker settings sourced
+// from environment variables.
+//
+// Environment variables (with defaults):
+//
+//   - CLIENT_BREAKER_FAILURE_RATIO (float, default: 0.5)
+//   - CLIENT_BREAKER_MIN_REQUESTS (int, default: 10)
+//   - CLIENT_BREAKER_WINDOW (duration, default: 60s)
+//   - CLIENT_BREAKER_COOLDOWN (duration, default: 30s)
+//   - CLIENT_BREAKER_HALF_OPEN_REQUESTS (int, default: 1)
+func BreakerOptionsFromEnv() BreakerOptions {
+	return BreakerOptions{
+		FailureRatio:     env.Float64("CLIENT_BREAKER_FAILURE_RATIO", 0.5),
+		MinRequests:      env.Int("CLIENT_BREAKER_MIN_REQUESTS", 10),
+		Window:           env.Duration("CLIENT_BREAKER_WINDOW", 60*time.Second),
+		CoolDown:         env.Duration("CLIENT_BREAKER_COOLDOWN", 30*time.Second),
+		HalfOpenRequests: env.Int("CLIENT_BREAKER_HALF_OPEN_REQUESTS", 1),
+	}
+}
+
+// StateChangeFunc is notified on every breaker state transition.
+type StateChangeFunc func(host string, from, to BreakerState)
+
+// Breakers holds a circuit breaker for each host.
+type Breakers struct {
+	opts BreakerOptions
+	now  func() time.Time
+
+	mu        sync.Mutex
+	items     map[string]*breaker
+	listeners []StateChangeFunc
+}
+
+type breaker struct {
+	state       BreakerState
+	total       int
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+	inFlight    int
+}
+
+func NewBreakers(opts BreakerOptions) *Breakers {
+	if opts.FailureRatio <= 0 {
+		opts.FailureRatio = 0.5
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 1
+	}
+	if opts.HalfOpenRequests <= 0 {
+		opts.HalfOpenRequests = 1
+	}
+
+	return &Breakers{
+		opts:  opts,
+		now:   time.Now,
+		items: map[string]*breaker{},
+	}
+}
+
+var (
+	sharedBreakers     *Breakers
+	sharedBreakersOnce sync.Once
+)
+
+// SharedBreakers returns the process wide breakers used by NewRetryClient,
+// configured by BreakerOptionsFromEnv; their state is exported by the
+// http_client_circuit_breaker_state metric.
+func SharedBreakers() *Breakers {
+	sharedBreakersOnce.Do(func() {
+		sharedBreakers = NewBreakers(BreakerOptionsFromEnv())
+		sharedBreakers.OnStateChange(observeBreakerState)
+	})
+	return sharedBreakers
+}
+
+// OnStateChange registers a listener for the state transitions.
+// Listeners are called synchronously and must not block.
+func (b *Breakers) OnStateChange(fn StateChangeFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, fn)
+}
+
+// State returns the current state of the breaker for the specified host.
+func (b *Breakers) State(host string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if cb, ok := b.items[host]; ok {
+		return cb.state
+	}
+	return StateClosed
+}
+
+// Allow reports whether a request to the specified host can be sent.
+// On success either the returned func must be called with the request
+// outcome or, when there is none to record (i.e. the request has been
+// canceled by the caller), Release must be called.
+func (b *Breakers) Allow(host string) (func(success bool), error) {
+	b.mu.Lock()
+
+	now := b.now()
+	cb, ok := b.items[host]
+	if !ok {
+		cb = &breaker{windowStart: now}
+		b.items[host] = cb
+	}
+
+	var notify func()
+	switch cb.state {
+	case StateClosed:
+		if b.opts.Window > 0 && now.Sub(cb.windowStart) >= b.opts.Window {
+			cb.reset(now)
+		}
+	case StateOpen:
+		if now.Sub(cb.openedAt) < b.opts.CoolDown {
+			b.mu.Unlock()
+			return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+		}
+		notify = b.transition(host, cb, StateHalfOpen, now)
+		fallthrough
+	case StateHalfOpen:
+		if cb.inFlight >= b.opts.HalfOpenRequests {
+			b.mu.Unlock()
+			return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+		}
+		cb.inFlight++
+	}
+
+	b.mu.Unlock()
+	if notify != nil {
+		notify()
+	}
+
+	return func(success bool) {
+		b.record(host, success)
+	}, nil
+}
+
+// Release gives back the probe slot taken by Allow without
+// recording any outcome.
+func (b *Breakers) Release(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if cb, ok := b.items[host]; ok && cb.state == StateHalfOpen && cb.inFlight > 0 {
+		cb.inFlight--
+	}
+}
+
+func (b *Breakers) record(host string, success bool) {
+	b.mu.Lock()
+
+	now := b.now()
+	cb := b.items[host]
+
+	var notify func()
+	switch cb.state {
+	case StateClosed:
+		cb.total++
+		if !success {
+			cb.failures++
+		}
+		if cb.total >= b.opts.MinRequests &&
+			float64(cb.failures)/float64(cb.total) >= b.opts.FailureRatio {
+			notify = b.transition(host, cb, StateOpen, now)
+		}
+	case StateHalfOpen:
+		if cb.inFlight > 0 {
+			cb.inFlight--
+		}
+		if success {
+			notify = b.transition(host, cb, StateClosed, now)
+		} else {
+			notify = b.transition(host, cb, StateOpen, now)
+		}
+	}
+
+	b.mu.Unlock()
+	if notify != nil {
+		notify()
+	}
+}
+
+// transition must be called holding the lock; the returned
+// func notifies the listeners and must be called without it.
+func (b *Breakers) transition(host string, cb *breaker, to BreakerState, now time.Time) func() {
+	from := cb.state
+	cb.state = to
+	cb.reset(now)
+	if to == StateOpen {
+		cb.openedAt = now
+	}
+
+	listeners := append([]StateChangeFunc(nil), b.listeners...)
+	return func() {
+		for _, fn := range listeners {
+			fn(host, from, to)
+		}
+	}
+}
+
+func (cb *breaker) reset(now time.Time) {
+	cb.total, cb.failures, cb.inFlight = 0, 0, 0
+	cb.windowStart = now
+}
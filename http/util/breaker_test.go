@@ -0,0 +1,197 @@
+package util_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/http/util"
+)
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		transitions []string
+	)
+
+	breakers := util.NewBreakers(util.BreakerOptions{
+		FailureRatio: 0.5,
+		MinRequests:  4,
+		Window:       time.Minute,
+		CoolDown:     100 * time.Millisecond,
+	})
+	breakers.OnStateChange(func(host string, from, to util.BreakerState) {
+		mu.Lock()
+		defer mu.Unlock()
+		transitions = append(transitions, from.String()+"->"+to.String())
+	})
+
+	const host = "api.example.com"
+
+	for i := 0; i < 4; i++ {
+		done, err := breakers.Allow(host)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		done(i%2 == 0) // 50% failures
+	}
+
+	if got := breakers.State(host); got != util.StateOpen {
+		t.Fatalf("expected open state, got %s", got)
+	}
+
+	if _, err := breakers.Allow(host); !errors.Is(err, util.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	// Other hosts are not affected
+	if _, err := breakers.Allow("other.example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	probe, err := breakers.Allow(host)
+	if err != nil {
+		t.Fatalf("expected half-open probe, got %v", err)
+	}
+	if _, err := breakers.Allow(host); !errors.Is(err, util.ErrCircuitOpen) {
+		t.Fatalf("expected a single half-open probe, got %v", err)
+	}
+	probe(true)
+
+	if got := breakers.State(host); got != util.StateClosed {
+		t.Fatalf("expected closed state, got %s", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"closed->open", "open->half-open", "half-open->closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("expected transitions %v, got %v", want, transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("expected transitions %v, got %v", want, transitions)
+		}
+	}
+}
+
+func TestBreakerHalfOpenFailureReopens(t *testing.T) {
+	breakers := util.NewBreakers(util.BreakerOptions{
+		MinRequests: 1,
+		CoolDown:    50 * time.Millisecond,
+	})
+
+	done, _ := breakers.Allow("host")
+	done(false)
+
+	time.Sleep(60 * time.Millisecond)
+
+	probe, err := breakers.Allow("host")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	probe(false)
+
+	if got := breakers.State("host"); got != util.StateOpen {
+		t.Fatalf("expected open state, got %s", got)
+	}
+}
+
+func TestRetryClientRecordsOneOutcomePerCall(t *testing.T) {
+	attempts := 0
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		http.Error(w, "unavailable", http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	cli := &util.RetryClient{
+		Client:      http.DefaultClient,
+		MaxRetries:  3,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  5 * time.Millisecond,
+		Breakers: util.NewBreakers(util.BreakerOptions{
+			MinRequests: 2,
+			CoolDown:    time.Minute,
+		}),
+	}
+	u, _ := url.Parse(ts.URL)
+
+	call := func() error {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, ts.URL, nil)
+		resp, err := cli.Do(req)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// the retries of a single call are one failure
+	if err := call(); err == nil || errors.Is(err, util.ErrCircuitOpen) {
+		t.Fatalf("expected a server error, got %v", err)
+	}
+	if attempts != 4 {
+		t.Fatalf("expected 4 attempts, got %d", attempts)
+	}
+	if got := cli.Breakers.State(u.Host); got != util.StateClosed {
+		t.Fatalf("expected closed state, got %s", got)
+	}
+
+	call()
+	if got := cli.Breakers.State(u.Host); got != util.StateOpen {
+		t.Fatalf("expected open state, got %s", got)
+	}
+
+	if err := call(); !errors.Is(err, util.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if attempts != 8 {
+		t.Fatalf("expected 8 attempts, got %d", attempts)
+	}
+}
+
+func TestRetryClientIgnoresCanceledRequests(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+
+	breakers := util.NewBreakers(util.BreakerOptions{
+		MinRequests: 1,
+		CoolDown:    20 * time.Millisecond,
+	})
+	done, _ := breakers.Allow(u.Host)
+	done(false)
+	time.Sleep(30 * time.Millisecond)
+
+	cli := &util.RetryClient{Client: http.DefaultClient, Breakers: breakers}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	if _, err := cli.Do(req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// the canceled probe neither closed the breaker nor kept its slot
+	if got := breakers.State(u.Host); got != util.StateHalfOpen {
+		t.Fatalf("expected half-open state, got %s", got)
+	}
+	probe, err := breakers.Allow(u.Host)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	probe(true)
+	if got := breakers.State(u.Host); got != util.StateClosed {
+		t.Fatalf("expected closed state, got %s", got)
+	}
+}
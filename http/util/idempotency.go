@@ -0,0 +1,57 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/krateoplatformops/plumbing/shortid"
+)
+
+// IdempotencyKeyHeader carries the key identifying a logical request
+// so that servers can safely deduplicate retried attempts.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// acceptsIdempotencyKey returns true for HTTP methods
+// that can be retried using an idempotency key
+func acceptsIdempotencyKey(method string) bool {
+	return method == http.MethodPost || method == http.MethodPatch
+}
+
+// withIdempotencyKey returns a copy of the request with an Idempotency-Key
+// header; a key already set by the caller is preserved.
+func withIdempotencyKey(req *http.Request) (*http.Request, error) {
+	if len(req.Header.Get(IdempotencyKeyHeader)) > 0 {
+		return req, nil
+	}
+
+	key, err := shortid.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	res := req.Clone(req.Context())
+	res.Header.Set(IdempotencyKeyHeader, key)
+	return res, nil
+}
+
+// bufferBody reads the request body in memory and sets GetBody,
+// so that every attempt can resend the whole payload.
+func bufferBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return nil
+	}
+
+	dat, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(dat))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(dat)), nil
+	}
+	req.ContentLength = int64(len(dat))
+	return nil
+}
@@ -0,0 +1,132 @@
+package util_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/http/util"
+)
+
+func TestRetryPostWithIdempotencyKey(t *testing.T) {
+	var (
+		keys   []string
+		bodies []string
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dat, _ := io.ReadAll(r.Body)
+		keys = append(keys, r.Header.Get(util.IdempotencyKeyHeader))
+		bodies = append(bodies, string(dat))
+		if len(keys) <= 2 {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	cli := &util.RetryClient{
+		Client:          http.DefaultClient,
+		MaxRetries:      5,
+		BaseBackoff:     time.Millisecond,
+		MaxBackoff:      5 * time.Millisecond,
+		IdempotencyKeys: true,
+	}
+
+	// A streaming body: GetBody is nil
+	body := io.NopCloser(strings.NewReader(`{"name":"demo"}`))
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, ts.URL, body)
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(keys))
+	}
+	for i := range keys {
+		if keys[i] == "" || keys[i] != keys[0] {
+			t.Fatalf("expected the same idempotency key on every attempt, got %v", keys)
+		}
+		if bodies[i] != `{"name":"demo"}` {
+			t.Fatalf("attempt %d: unexpected body %q", i, bodies[i])
+		}
+	}
+}
+
+func TestRetryPatchKeepsCallerIdempotencyKey(t *testing.T) {
+	var keys []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(util.IdempotencyKeyHeader))
+		if len(keys) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	cli := &util.RetryClient{
+		Client:          http.DefaultClient,
+		MaxRetries:      2,
+		BaseBackoff:     time.Millisecond,
+		MaxBackoff:      5 * time.Millisecond,
+		IdempotencyKeys: true,
+	}
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPatch, ts.URL, strings.NewReader("{}"))
+	req.Header.Set(util.IdempotencyKeyHeader, "my-key")
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if len(keys) != 2 || keys[0] != "my-key" || keys[1] != "my-key" {
+		t.Fatalf("expected caller idempotency key on every attempt, got %v", keys)
+	}
+}
+
+func TestRetryPutReplaysBody(t *testing.T) {
+	var bodies []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dat, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(dat))
+		if len(bodies) == 1 {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	cli := &util.RetryClient{
+		Client:      http.DefaultClient,
+		MaxRetries:  2,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  5 * time.Millisecond,
+	}
+
+	body := io.NopCloser(strings.NewReader("payload"))
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPut, ts.URL, body)
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if len(bodies) != 2 || bodies[0] != "payload" || bodies[1] != "payload" {
+		t.Fatalf("expected the payload on every attempt, got %q", bodies)
+	}
+}
@@ -0,0 +1,220 @@
+package util
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/cache"
+	"github.com/krateoplatformops/plumbing/env"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// limiterIdleTTL is how long an unused limiter is kept in the registry.
+	limiterIdleTTL = time.Hour
+	// limiterMinFactor is the lowest fraction of the configured
+	// QPS the adaptive limiter can slow down to on 429s.
+	limiterMinFactor = 1.0 / 32
+)
+
+type LimitersOptions struct {
+	// QPS is the sustained rate of each limiter (default: CLIENT_QPS).
+	QPS float64
+	// Burst is the burst size of each limiter (default: CLIENT_BURST).
+	Burst int
+	// MaxEntries caps the number of limiters (default: 4096).
+	MaxEntries int
+}
+
+// Limiters is a registry of adaptive rate limiters keyed by endpoint,
+// so that the limits are enforced across all the clients calling it.
+type Limiters struct {
+	opts  LimitersOptions
+	mu    sync.Mutex
+	items *cache.TTLCache[string, *AdaptiveLimiter]
+}
+
+func NewLimiters(opts LimitersOptions) *Limiters {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 4096
+	}
+
+	return &Limiters{
+		opts: opts,
+		items: cache.NewTTL[string, *AdaptiveLimiter](
+			cache.WithCleanupInterval(0),
+			cache.WithMaxEntries(opts.MaxEntries),
+		),
+	}
+}
+
+var (
+	sharedLimiters     *Limiters
+	sharedLimitersOnce sync.Once
+)
+
+// SharedLimiters returns the process wide registry used by NewRetryClient.
+// Limiters are created using the CLIENT_QPS and CLIENT_BURST values
+// found when the key is seen for the first time.
+func SharedLimiters() *Limiters {
+	sharedLimitersOnce.Do(func() {
+		sharedLimiters = NewLimiters(LimitersOptions{})
+	})
+	return sharedLimiters
+}
+
+// Get returns the limiter for the specified key, creating it if needed.
+func (l *Limiters) Get(key string) *AdaptiveLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	res, ok := l.items.Get(key)
+	if !ok {
+		qps := l.opts.QPS
+		if qps <= 0 {
+			qps = env.Float64("CLIENT_QPS", 30.0)
+		}
+		burst := l.opts.Burst
+		if burst <= 0 {
+			burst = env.Int("CLIENT_BURST", 45)
+		}
+		res = NewAdaptiveLimiter(qps, burst)
+	}
+
+	// Sliding expiration: keep the limiters in use
+	l.items.Set(key, res, limiterIdleTTL)
+	return res
+}
+
+// AdaptiveLimiter is a token bucket that slows down following
+// the RateLimit-* (or X-RateLimit-*) response headers and 429 responses,
+// and speeds up again to the configured rate when the pressure is gone.
+type AdaptiveLimiter struct {
+	base rate.Limit
+	lim  *rate.Limiter
+	now  func() time.Time
+
+	mu           sync.Mutex
+	blockedUntil time.Time
+}
+
+func NewAdaptiveLimiter(qps float64, burst int) *AdaptiveLimiter {
+	return &AdaptiveLimiter{
+		base: rate.Limit(qps),
+		lim:  rate.NewLimiter(rate.Limit(qps), burst),
+		now:  time.Now,
+	}
+}
+
+// Limit returns the current rate.
+func (al *AdaptiveLimiter) Limit() rate.Limit {
+	return al.lim.Limit()
+}
+
+// Wait blocks until the endpoint quota is available again
+// and a token can be taken, or the context is done.
+func (al *AdaptiveLimiter) Wait(ctx context.Context) error {
+	al.mu.Lock()
+	delay := al.blockedUntil.Sub(al.now())
+	al.mu.Unlock()
+
+	if delay > 0 {
+		if err := sleepWithContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+
+	return al.lim.Wait(ctx)
+}
+
+// Observe adapts the rate to the response.
+func (al *AdaptiveLimiter) Observe(resp *http.Response) {
+	now := al.now()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		al.block(now.Add(parseRetryAfter(resp)))
+		al.scaleLimit(0.5)
+		return
+	}
+
+	remaining, okRemaining := headerInt(resp.Header, "RateLimit-Remaining", "X-RateLimit-Remaining")
+	reset, okReset := parseRateLimitReset(resp.Header, now)
+	if !okRemaining {
+		// Slowly speed up back to the configured rate
+		al.scaleLimit(1.1)
+		return
+	}
+
+	if !okReset {
+		if remaining == 0 {
+			al.scaleLimit(0.5)
+		}
+		return
+	}
+
+	if remaining == 0 {
+		al.block(reset)
+		return
+	}
+
+	// Spread the remaining quota until the window resets
+	window := reset.Sub(now).Seconds()
+	if window <= 0 {
+		al.setLimit(al.base)
+		return
+	}
+	al.setLimit(rate.Limit(float64(remaining) / window))
+}
+
+func (al *AdaptiveLimiter) block(until time.Time) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if until.After(al.blockedUntil) {
+		al.blockedUntil = until
+	}
+}
+
+func (al *AdaptiveLimiter) scaleLimit(factor float64) {
+	r := al.lim.Limit() * rate.Limit(factor)
+	al.setLimit(rate.Limit(math.Max(float64(r), float64(al.base)*limiterMinFactor)))
+}
+
+// setLimit changes the rate, never exceeding the configured one.
+func (al *AdaptiveLimiter) setLimit(r rate.Limit) {
+	r = rate.Limit(math.Min(float64(r), float64(al.base)))
+	if r != al.lim.Limit() {
+		al.lim.SetLimit(r)
+	}
+}
+
+func headerInt(h http.Header, keys ...string) (int, bool) {
+	for _, k := range keys {
+		if v := strings.TrimSpace(h.Get(k)); len(v) > 0 {
+			if n, err := strconv.Atoi(v); err == nil {
+				return n, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// parseRateLimitReset handles both delta seconds (RateLimit-Reset)
+// and unix timestamps (i.e. GitHub X-RateLimit-Reset).
+func parseRateLimitReset(h http.Header, now time.Time) (time.Time, bool) {
+	n, ok := headerInt(h, "RateLimit-Reset", "X-RateLimit-Reset")
+	if !ok || n < 0 {
+		return time.Time{}, false
+	}
+
+	// Deltas are never that large: it's an epoch
+	if n > 1_000_000_000 {
+		return time.Unix(int64(n), 0), true
+	}
+	return now.Add(time.Duration(n) * time.Second), true
+}
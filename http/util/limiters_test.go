@@ -0,0 +1,122 @@
+package util_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/http/util"
+	"golang.org/x/time/rate"
+)
+
+func TestLimitersAreSharedByKey(t *testing.T) {
+	lims := util.NewLimiters(util.LimitersOptions{QPS: 10, Burst: 1})
+
+	if lims.Get("a") != lims.Get("a") {
+		t.Fatal("expected the same limiter for the same key")
+	}
+	if lims.Get("a") == lims.Get("b") {
+		t.Fatal("expected different limiters for different keys")
+	}
+}
+
+func TestAdaptiveLimiterObserve(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    int
+		headers map[string]string
+		want    rate.Limit
+	}{
+		{
+			name:    "spreads the remaining quota",
+			code:    http.StatusOK,
+			headers: map[string]string{"RateLimit-Remaining": "20", "RateLimit-Reset": "10"},
+			want:    2,
+		},
+		{
+			name: "github style epoch reset",
+			code: http.StatusOK,
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "50",
+				"X-RateLimit-Reset":     strconv.FormatInt(time.Now().Add(100*time.Second).Unix(), 10),
+			},
+			want: 0.5,
+		},
+		{
+			name:    "never faster than configured",
+			code:    http.StatusOK,
+			headers: map[string]string{"RateLimit-Remaining": "5000", "RateLimit-Reset": "1"},
+			want:    100,
+		},
+		{
+			name: "too many requests halves the rate",
+			code: http.StatusTooManyRequests,
+			want: 50,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			lim := util.NewAdaptiveLimiter(100, 10)
+
+			resp := &http.Response{StatusCode: tc.code, Header: http.Header{}}
+			for k, v := range tc.headers {
+				resp.Header.Set(k, v)
+			}
+			lim.Observe(resp)
+
+			if got := lim.Limit(); got < tc.want*0.95 || got > tc.want*1.05 {
+				t.Fatalf("expected limit ~%v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAdaptiveLimiterBlocksWhenExhausted(t *testing.T) {
+	lim := util.NewAdaptiveLimiter(100, 10)
+	lim.Observe(&http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Ratelimit-Remaining": []string{"0"},
+			"Ratelimit-Reset":     []string{"30"},
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := lim.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected to wait for the quota reset, got %v", err)
+	}
+}
+
+func TestRetryClientsShareLimiter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	lims := util.NewLimiters(util.LimitersOptions{QPS: 10, Burst: 1})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		// A new client for every call, as request.Do does
+		cli := &util.RetryClient{Client: http.DefaultClient, Limiters: lims}
+
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, ts.URL, nil)
+		resp, err := cli.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	// burst of 1 at 10 QPS: at least 2 waits of 100ms
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("expected the limiter to be shared, took %v", elapsed)
+	}
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	clientRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_client_requests_total",
+		Help: "Total number of upstream HTTP attempts by status code (\"error\" for transport failures).",
+	}, []string{"host", "code"})
+
+	clientRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_client_retries_total",
+		Help: "Total number of retried HTTP attempts.",
+	}, []string{"host"})
+
+	clientBackoffSeconds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_client_backoff_seconds_total",
+		Help: "Total time spent waiting between retries.",
+	}, []string{"host"})
+
+	clientLimiterWaitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_client_limiter_wait_seconds",
+		Help:    "Time spent waiting for the rate limiter.",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30},
+	}, []string{"host"})
+
+	clientBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_client_circuit_breaker_state",
+		Help: "State of the circuit breaker (0: closed, 1: open, 2: half-open).",
+	}, []string{"host"})
+)
+
+// maxHostLabels bounds the distinct values of the host label:
+// the hosts seen once the limit is reached are all recorded as otherHost,
+// so that clients calling arbitrary URLs can not blow up the series.
+const (
+	maxHostLabels = 100
+	otherHost     = "other"
+)
+
+var (
+	hostLabelsMu sync.Mutex
+	hostLabels   = map[string]struct{}{}
+)
+
+func hostLabel(host string) string {
+	hostLabelsMu.Lock()
+	defer hostLabelsMu.Unlock()
+
+	if _, ok := hostLabels[host]; ok {
+		return host
+	}
+	if len(hostLabels) >= maxHostLabels {
+		return otherHost
+	}
+	hostLabels[host] = struct{}{}
+	return host
+}
+
+func init() {
+	metrics.MustRegister(
+		clientRequestsTotal,
+		clientRetriesTotal,
+		clientBackoffSeconds,
+		clientLimiterWaitSeconds,
+		clientBreakerState,
+	)
+}
+
+// waitBeforeRetry sleeps for the given duration recording the retry.
+func waitBeforeRetry(ctx context.Context, host string, d time.Duration) error {
+	host = hostLabel(host)
+	clientRetriesTotal.WithLabelValues(host).Inc()
+	clientBackoffSeconds.WithLabelValues(host).Add(d.Seconds())
+	return sleepWithContext(ctx, d)
+}
+
+func observeStatusCode(host string, code int) {
+	clientRequestsTotal.WithLabelValues(hostLabel(host), strconv.Itoa(code)).Inc()
+}
+
+func observeTransportError(host string) {
+	clientRequestsTotal.WithLabelValues(hostLabel(host), "error").Inc()
+}
+
+func observeLimiterWait(host string, start time.Time) {
+	clientLimiterWaitSeconds.WithLabelValues(hostLabel(host)).Observe(time.Since(start).Seconds())
+}
+
+func observeBreakerState(host string, _, to BreakerState) {
+	clientBreakerState.WithLabelValues(hostLabel(host)).Set(float64(to))
+}
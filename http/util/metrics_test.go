@@ -0,0 +1,97 @@
+package util_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/http/util"
+	"github.com/krateoplatformops/plumbing/metrics"
+)
+
+func TestRetryClientMetrics(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	cli := &util.RetryClient{
+		Client:      http.DefaultClient,
+		MaxRetries:  2,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  5 * time.Millisecond,
+		Limiters:    util.NewLimiters(util.LimitersOptions{QPS: 100, Burst: 10}),
+	}
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, ts.URL, nil)
+	resp, err := cli.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	out := rec.Body.String()
+
+	u, _ := url.Parse(ts.URL)
+	for _, want := range []string{
+		fmt.Sprintf(`http_client_requests_total{code="503",host=%q} 1`, u.Host),
+		fmt.Sprintf(`http_client_requests_total{code="200",host=%q} 1`, u.Host),
+		fmt.Sprintf(`http_client_retries_total{host=%q} 1`, u.Host),
+		fmt.Sprintf(`http_client_backoff_seconds_total{host=%q}`, u.Host),
+		fmt.Sprintf(`http_client_limiter_wait_seconds_count{host=%q} 1`, u.Host),
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected metric %q", want)
+		}
+	}
+}
+
+type okTransport struct{}
+
+func (okTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+func TestRetryClientMetricsBoundHosts(t *testing.T) {
+	cli := &util.RetryClient{
+		Client:   &http.Client{Transport: okTransport{}},
+		Limiters: util.NewLimiters(util.LimitersOptions{QPS: 1000, Burst: 1000}),
+	}
+
+	for i := range 150 {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://host-%d.example.org", i), nil)
+		resp, err := cli.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	out := rec.Body.String()
+
+	if !strings.Contains(out, `http_client_requests_total{code="200",host="other"}`) {
+		t.Errorf("expected the hosts beyond the limit to be labelled as other")
+	}
+	if strings.Contains(out, `host="host-149.example.org"`) {
+		t.Errorf("unexpected label for a host beyond the limit")
+	}
+}
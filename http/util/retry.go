@@ -33,8 +33,19 @@ import (
 //   - CLIENT_BURST (int, default: 45)
 //     Maximum burst of requests allowed before the limiter enforces QPS.
 //
+// The rate limiters are shared by all the clients calling the same
+// endpoint (see SharedLimiters) and adapt to the RateLimit-* response
+// headers and to 429 Too Many Requests responses.
+//
+//   - CLIENT_BREAKER_ENABLED (bool, default: false)
+//     Enables the per-host circuit breaker (see BreakerOptionsFromEnv).
+//
+//   - CLIENT_IDEMPOTENCY_KEYS (bool, default: false)
+//     Retries POST and PATCH requests sending an Idempotency-Key header.
+//
 // The returned client retries failed requests (429 and 5xx) with exponential
-// backoff and jitter, and enforces request rate limiting using a token bucket.
+// backoff and jitter, enforces request rate limiting using a token bucket and,
+// when enabled, stops calling hosts that keep failing using the SharedBreakers.
 //
 // Example usage:
 //
@@ -42,16 +53,20 @@ import (
 //	retryCli := NewRetryClient(cli)
 //	resp, err := retryCli.Do(req)
 func NewRetryClient(cli *http.Client) *RetryClient {
-	return &RetryClient{
-		Client:      cli,
-		MaxRetries:  env.Int("CLIENT_MAX_RETRIES", 5),
-		BaseBackoff: env.Duration("CLIENT_BASE_BACKOFF", 500*time.Millisecond),
-		MaxBackoff:  env.Duration("CLIENT_MAX_BACKOFF", 10*time.Second),
-		Limiter: rate.NewLimiter(rate.Limit(
-			env.Float64("CLIENT_QPS", 30.0)),
-			env.Int("CLIENT_BURST", 45),
-		),
+	res := &RetryClient{
+		Client:          cli,
+		MaxRetries:      env.Int("CLIENT_MAX_RETRIES", 5),
+		BaseBackoff:     env.Duration("CLIENT_BASE_BACKOFF", 500*time.Millisecond),
+		MaxBackoff:      env.Duration("CLIENT_MAX_BACKOFF", 10*time.Second),
+		Limiters:        SharedLimiters(),
+		IdempotencyKeys: env.Bool("CLIENT_IDEMPOTENCY_KEYS", false),
+	}
+
+	if env.Bool("CLIENT_BREAKER_ENABLED", false) {
+		res.Breakers = SharedBreakers()
 	}
+
+	return res
 }
 
 // RetryClient wraps an http.Client to add automatic retry on 429 and 5xx errors.
@@ -61,24 +76,88 @@ type RetryClient struct {
 	BaseBackoff time.Duration
 	MaxBackoff  time.Duration
 	Limiter     *rate.Limiter // controls QPS and Burst
+	// Breakers, when set, records one outcome per Do call
+	// (not per attempt) in the breaker of the request host.
+	Breakers *Breakers
+	// Limiters provides the adaptive rate limiter for the request,
+	// used when Limiter is nil.
+	Limiters *Limiters
+	// LimiterKey selects the Limiters entry (default: the request host).
+	LimiterKey string
+	// IdempotencyKeys enables retries of POST and PATCH requests,
+	// sending the same Idempotency-Key header on every attempt.
+	IdempotencyKeys bool
 }
 
-// Do executes the request with retry logic only for idempotent methods.
+// Do executes the request with retry logic only for idempotent methods
+// (and for POST and PATCH when IdempotencyKeys is enabled).
 func (rc *RetryClient) Do(req *http.Request) (*http.Response, error) {
+	if rc.Breakers == nil {
+		return rc.do(req)
+	}
+
+	host := req.URL.Host
+	done, err := rc.Breakers.Allow(host)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := rc.do(req)
+	switch {
+	case req.Context().Err() != nil:
+		// A canceled request says nothing about the host health
+		rc.Breakers.Release(host)
+	case resp != nil:
+		done(resp.StatusCode < 500)
+	default:
+		done(err == nil)
+	}
+	return resp, err
+}
+
+func (rc *RetryClient) do(req *http.Request) (*http.Response, error) {
 	if rc.Client == nil {
 		rc.Client = http.DefaultClient
 	}
 
+	host := req.URL.Host
+
 	// enforce QPS/Burst limits
-	if rc.Limiter != nil {
+	var adaptive *AdaptiveLimiter
+	start := time.Now()
+	switch {
+	case rc.Limiter != nil:
 		if err := rc.Limiter.Wait(req.Context()); err != nil {
 			return nil, err
 		}
+	case rc.Limiters != nil:
+		key := rc.LimiterKey
+		if len(key) == 0 {
+			key = host
+		}
+		adaptive = rc.Limiters.Get(key)
+		if err := adaptive.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	observeLimiterWait(host, start)
+
+	retryable := isIdempotentMethod(req.Method)
+	if !retryable && rc.IdempotencyKeys && acceptsIdempotencyKey(req.Method) {
+		var err error
+		if req, err = withIdempotencyKey(req); err != nil {
+			return nil, err
+		}
+		retryable = true
 	}
 
 	// Only retry idempotent HTTP methods
-	if !isIdempotentMethod(req.Method) {
-		return rc.Client.Do(req)
+	if !retryable {
+		return rc.send(req, adaptive)
+	}
+
+	if err := bufferBody(req); err != nil {
+		return nil, err
 	}
 
 	var lastErr error
@@ -87,12 +166,19 @@ func (rc *RetryClient) Do(req *http.Request) (*http.Response, error) {
 	for attempt := 0; attempt <= rc.MaxRetries; attempt++ {
 		// Clone the request to ensure Body can be reused
 		clonedReq := req.Clone(req.Context())
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			clonedReq.Body = body
+		}
 
-		resp, lastErr = rc.Client.Do(clonedReq)
+		resp, lastErr = rc.send(clonedReq, adaptive)
 		if lastErr != nil {
 			// Network error → retry
 			if attempt < rc.MaxRetries {
-				if err := sleepWithContext(req.Context(), backoff(attempt, rc.BaseBackoff, rc.MaxBackoff)); err != nil {
+				if err := waitBeforeRetry(req.Context(), host, backoff(attempt, rc.BaseBackoff, rc.MaxBackoff)); err != nil {
 					return nil, err
 				}
 				continue
@@ -110,7 +196,7 @@ func (rc *RetryClient) Do(req *http.Request) (*http.Response, error) {
 			retryAfter := parseRetryAfter(resp)
 			resp.Body.Close()
 			if attempt < rc.MaxRetries {
-				if err := sleepWithContext(req.Context(), retryAfter); err != nil {
+				if err := waitBeforeRetry(req.Context(), host, retryAfter); err != nil {
 					return nil, err
 				}
 				continue
@@ -122,7 +208,7 @@ func (rc *RetryClient) Do(req *http.Request) (*http.Response, error) {
 		if resp.StatusCode >= 500 {
 			resp.Body.Close()
 			if attempt < rc.MaxRetries {
-				if err := sleepWithContext(req.Context(), backoff(attempt, rc.BaseBackoff, rc.MaxBackoff)); err != nil {
+				if err := waitBeforeRetry(req.Context(), host, backoff(attempt, rc.BaseBackoff, rc.MaxBackoff)); err != nil {
 					return nil, err
 				}
 				continue
@@ -134,6 +220,22 @@ func (rc *RetryClient) Do(req *http.Request) (*http.Response, error) {
 	return resp, lastErr
 }
 
+// send executes a single attempt, feeding the adaptive limiter with the response
+func (rc *RetryClient) send(req *http.Request, adaptive *AdaptiveLimiter) (*http.Response, error) {
+	resp, err := rc.Client.Do(req)
+	if err != nil {
+		observeTransportError(req.URL.Host)
+		return nil, err
+	}
+	observeStatusCode(req.URL.Host, resp.StatusCode)
+
+	if adaptive != nil {
+		adaptive.Observe(resp)
+	}
+
+	return resp, nil
+}
+
 // isIdempotentMethod returns true for HTTP methods that are safe to retry
 func isIdempotentMethod(method string) bool {
 	switch method {
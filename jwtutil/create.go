@@ -1,6 +1,7 @@
 package jwtutil
 
 import (
+	"crypto/rand"
 	"fmt"
 	"time"
 
@@ -18,8 +19,17 @@ type UserInfo struct {
 	Groups   []string `json:"groups"`
 }
 
+const (
+	// TokenUseAccess marks the tokens authorizing the requests; tokens
+	// without the "token_use" claim are access tokens as well.
+	TokenUseAccess = "access"
+	// TokenUseRefresh marks the tokens exchanged for new access tokens.
+	TokenUseRefresh = "refresh"
+)
+
 type KrateoClaims struct {
 	UserInfo
+	TokenUse string `json:"token_use,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -28,15 +38,23 @@ type CreateTokenOptions struct {
 	Groups     []string
 	Duration   time.Duration
 	SigningKey string
+	// Signer, when set, signs the token with an asymmetric key
+	// in place of the SigningKey shared secret.
+	Signer *Signer
 }
 
 // CreateToken generates a signed JWT token using the provided
 // username, group list, and expiration duration.
-// The token is signed using the HS256 algorithm.
+// The token is signed using the HS256 algorithm, or the Signer
+// algorithm (RS256, ES256 or EdDSA) when specified.
 // The signing key is read from the environment variable AUTHN_JWT_SECRET.
 // If the environment variable is not set, the function returns an error.
 func CreateToken(opts CreateTokenOptions) (string, error) {
-	if opts.SigningKey == "" {
+	return createToken(opts, TokenUseAccess, opts.Duration)
+}
+
+func createToken(opts CreateTokenOptions, use string, duration time.Duration) (string, error) {
+	if opts.SigningKey == "" && opts.Signer == nil {
 		return "", fmt.Errorf("signing key cannot be empty")
 	}
 
@@ -51,15 +69,21 @@ func CreateToken(opts CreateTokenOptions) (string, error) {
 			Username: opts.Username,
 			Groups:   opts.Groups,
 		},
+		TokenUse: use,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        rand.Text(),
 			Issuer:    defaultISS,
-			ExpiresAt: jwt.NewNumericDate(now.Add(opts.Duration)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 			Subject:   opts.Username,
 		},
 	}
 
+	if opts.Signer != nil {
+		return opts.Signer.sign(claims)
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	return token.SignedString([]byte(opts.SigningKey))
@@ -0,0 +1,278 @@
+package jwtutil
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// KeyProvider looks up the public key used to verify a token.
+type KeyProvider interface {
+	PublicKey(ctx context.Context, kid string) (JWK, crypto.PublicKey, error)
+}
+
+// KeyLister is implemented by the key providers able to list all their
+// keys, tried in turn to verify the tokens without a "kid" header.
+type KeyLister interface {
+	Keys(ctx context.Context) ([]JWK, error)
+}
+
+// KeySet is an in memory set of public keys; it can be used both to
+// verify tokens locally and to publish the keys (see Handler).
+//
+// Keys are rotated adding the new key, signing with it once the
+// verifiers had the time to fetch it, and then removing the old one.
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []JWK
+}
+
+func NewKeySet(keys ...JWK) *KeySet {
+	ks := &KeySet{}
+	for _, k := range keys {
+		ks.Add(k)
+	}
+	return ks
+}
+
+// Add adds a key, replacing the one with the same id.
+func (ks *KeySet) Add(key JWK) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.keys = slices.DeleteFunc(ks.keys, func(x JWK) bool { return x.Kid == key.Kid })
+	ks.keys = append(ks.keys, key)
+}
+
+// Remove drops the key with the specified id.
+func (ks *KeySet) Remove(kid string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.keys = slices.DeleteFunc(ks.keys, func(x JWK) bool { return x.Kid == kid })
+}
+
+// JWKS returns a copy of the keys.
+func (ks *KeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return JWKS{Keys: slices.Clone(ks.keys)}
+}
+
+func (ks *KeySet) Keys(context.Context) ([]JWK, error) {
+	return ks.JWKS().Keys, nil
+}
+
+func (ks *KeySet) PublicKey(_ context.Context, kid string) (JWK, crypto.PublicKey, error) {
+	ks.mu.RLock()
+	idx := slices.IndexFunc(ks.keys, func(x JWK) bool { return x.Kid == kid })
+	var key JWK
+	if idx >= 0 {
+		key = ks.keys[idx]
+	}
+	ks.mu.RUnlock()
+
+	if idx < 0 {
+		return JWK{}, nil, fmt.Errorf("key %q not found", kid)
+	}
+
+	pub, err := key.PublicKey()
+	return key, pub, err
+}
+
+// Handler publishes the keys as a JWKS document
+// (i.e. on "/.well-known/jwks.json").
+func (ks *KeySet) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(ks.JWKS())
+	})
+}
+
+type RemoteKeySetOptions struct {
+	// Client is used to fetch the JWKS (default: a client with a 10s timeout).
+	Client *http.Client
+	// RefreshInterval is how long the fetched keys are trusted (default: 10m).
+	RefreshInterval time.Duration
+	// MinRefreshInterval is the minimum time between two fetches, bounding
+	// the ones triggered by tokens signed with unknown keys (default: 1m).
+	MinRefreshInterval time.Duration
+}
+
+// RemoteKeySet caches the keys published at a JWKS URL, fetching them
+// again when they get stale or a token signed by an unknown key shows up
+// (i.e. after a rotation).
+//
+// Fetches run in the background, one at a time, bounded by the Client
+// timeout rather than by the caller context: callers only wait for them
+// as long as their own context allows.
+type RemoteKeySet struct {
+	url  string
+	opts RemoteKeySetOptions
+
+	mu        sync.Mutex
+	keys      *KeySet
+	fetchedAt time.Time
+	attemptAt time.Time
+	lastErr   error
+	// fetching is closed when the fetch in progress completes.
+	fetching chan struct{}
+}
+
+func NewRemoteKeySet(url string, opts RemoteKeySetOptions) *RemoteKeySet {
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = 10 * time.Minute
+	}
+	if opts.MinRefreshInterval <= 0 {
+		opts.MinRefreshInterval = time.Minute
+	}
+
+	return &RemoteKeySet{url: url, opts: opts, keys: NewKeySet()}
+}
+
+func (rks *RemoteKeySet) PublicKey(ctx context.Context, kid string) (JWK, crypto.PublicKey, error) {
+	rks.mu.Lock()
+	stale := time.Since(rks.fetchedAt) > rks.opts.RefreshInterval
+	rks.mu.Unlock()
+
+	if stale {
+		// on failure the stale keys are still used
+		if err := rks.refresh(ctx); err != nil {
+			return JWK{}, nil, err
+		}
+	}
+
+	key, pub, err := rks.current().PublicKey(ctx, kid)
+	if err == nil {
+		return key, pub, nil
+	}
+
+	if err := rks.refresh(ctx); err != nil {
+		return JWK{}, nil, err
+	}
+
+	rks.mu.Lock()
+	keys, lastErr := rks.keys, rks.lastErr
+	rks.mu.Unlock()
+
+	key, pub, err = keys.PublicKey(ctx, kid)
+	if err != nil && lastErr != nil {
+		return JWK{}, nil, lastErr
+	}
+	return key, pub, err
+}
+
+// Keys returns the cached keys, fetching them again when stale
+// or when none has been fetched yet.
+func (rks *RemoteKeySet) Keys(ctx context.Context) ([]JWK, error) {
+	rks.mu.Lock()
+	stale := time.Since(rks.fetchedAt) > rks.opts.RefreshInterval
+	rks.mu.Unlock()
+
+	if stale {
+		if err := rks.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	rks.mu.Lock()
+	keys, lastErr := rks.keys, rks.lastErr
+	rks.mu.Unlock()
+
+	res := keys.JWKS().Keys
+	if len(res) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return res, nil
+}
+
+func (rks *RemoteKeySet) current() *KeySet {
+	rks.mu.Lock()
+	defer rks.mu.Unlock()
+	return rks.keys
+}
+
+// refresh waits for the fetch in progress, starting one unless throttled
+// to one every MinRefreshInterval; it only fails if ctx is done first.
+func (rks *RemoteKeySet) refresh(ctx context.Context) error {
+	rks.mu.Lock()
+	done := rks.fetching
+	if done == nil {
+		if time.Since(rks.attemptAt) < rks.opts.MinRefreshInterval {
+			rks.mu.Unlock()
+			return nil
+		}
+		rks.attemptAt = time.Now()
+		done = make(chan struct{})
+		rks.fetching = done
+		go rks.fetch(context.WithoutCancel(ctx), done)
+	}
+	rks.mu.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fetch replaces the cached keys and closes done; on failure
+// the previous ones are kept until the next attempt.
+func (rks *RemoteKeySet) fetch(ctx context.Context, done chan struct{}) {
+	timeout := rks.opts.Client.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	keys, err := rks.load(ctx)
+
+	rks.mu.Lock()
+	if err == nil {
+		rks.keys = keys
+		rks.fetchedAt = time.Now()
+	}
+	rks.lastErr = err
+	rks.fetching = nil
+	rks.mu.Unlock()
+
+	close(done)
+}
+
+func (rks *RemoteKeySet) load(ctx context.Context) (*KeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rks.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := rks.opts.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch JWKS: unexpected status code %d", resp.StatusCode)
+	}
+
+	var doc JWKS
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	return NewKeySet(doc.Keys...), nil
+}
@@ -0,0 +1,140 @@
+package jwtutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/jwtutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySetHandler(t *testing.T) {
+	keys := testKeys(t)
+
+	rsaSigner, err := jwtutil.NewSigner("rsa", keys["RS256"])
+	require.NoError(t, err)
+	edSigner, err := jwtutil.NewSigner("ed", keys["EdDSA"])
+	require.NoError(t, err)
+
+	ks := jwtutil.NewKeySet(rsaSigner.JWK(), edSigner.JWK())
+
+	rec := httptest.NewRecorder()
+	ks.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var doc jwtutil.JWKS
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	require.Len(t, doc.Keys, 2)
+	assert.Equal(t, "rsa", doc.Keys[0].Kid)
+	assert.Equal(t, "RSA", doc.Keys[0].Kty)
+	assert.Equal(t, "ed", doc.Keys[1].Kid)
+	assert.Equal(t, "OKP", doc.Keys[1].Kty)
+
+	ks.Remove("rsa")
+	_, _, err = ks.PublicKey(context.Background(), "rsa")
+	assert.Error(t, err)
+}
+
+func TestRemoteKeySetRotation(t *testing.T) {
+	keys := testKeys(t)
+
+	oldSigner, err := jwtutil.NewSigner("old", keys["ES256"])
+	require.NoError(t, err)
+	newSigner, err := jwtutil.NewSigner("new", keys["EdDSA"])
+	require.NoError(t, err)
+
+	published := jwtutil.NewKeySet(oldSigner.JWK())
+
+	var fetches atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		published.Handler().ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	rks := jwtutil.NewRemoteKeySet(ts.URL, jwtutil.RemoteKeySetOptions{
+		MinRefreshInterval: 50 * time.Millisecond,
+	})
+
+	sign := func(s *jwtutil.Signer) string {
+		tok, err := jwtutil.CreateToken(jwtutil.CreateTokenOptions{
+			Username: "alice",
+			Groups:   []string{"devs"},
+			Duration: time.Minute,
+			Signer:   s,
+		})
+		require.NoError(t, err)
+		return tok
+	}
+
+	ctx := context.Background()
+
+	user, err := jwtutil.ValidateWithKeys(ctx, rks, sign(oldSigner))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+
+	// cached keys are reused
+	_, err = jwtutil.ValidateWithKeys(ctx, rks, sign(oldSigner))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// the new key is published and used for signing
+	published.Add(newSigner.JWK())
+	time.Sleep(60 * time.Millisecond)
+
+	_, err = jwtutil.ValidateWithKeys(ctx, rks, sign(newSigner))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), fetches.Load())
+
+	// unknown keys do not trigger fetches more often than MinRefreshInterval
+	rogue, err := jwtutil.NewSigner("rogue", keys["RS256"])
+	require.NoError(t, err)
+	time.Sleep(60 * time.Millisecond)
+	for range 3 {
+		_, err = jwtutil.ValidateWithKeys(ctx, rks, sign(rogue))
+		assert.ErrorIs(t, err, jwtutil.ErrTokenInvalid)
+	}
+	assert.Equal(t, int32(3), fetches.Load())
+}
+
+func TestRemoteKeySetCallerContext(t *testing.T) {
+	signer, err := jwtutil.NewSigner("k1", testKeys(t)["ES256"])
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		jwtutil.NewKeySet(signer.JWK()).Handler().ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	rks := jwtutil.NewRemoteKeySet(ts.URL, jwtutil.RemoteKeySetOptions{})
+
+	// the caller gives up, the fetch goes on
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err = rks.PublicKey(ctx, "k1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// other callers aren't blocked by the fetch in progress
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err = rks.PublicKey(ctx, "k1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+
+	// and get its keys, although the throttle forbids another one
+	_, _, err = rks.PublicKey(context.Background(), "k1")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load())
+}
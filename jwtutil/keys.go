@@ -0,0 +1,232 @@
+package jwtutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signer signs tokens with an asymmetric private key,
+// so that they can be verified using only the public key (see KeySet).
+type Signer struct {
+	kid    string
+	method jwt.SigningMethod
+	key    crypto.Signer
+}
+
+// NewSigner returns a Signer for the specified key id and private key;
+// the algorithm is chosen from the key type:
+//   - *rsa.PrivateKey: RS256
+//   - *ecdsa.PrivateKey (P-256): ES256
+//   - ed25519.PrivateKey: EdDSA
+func NewSigner(kid string, key crypto.Signer) (*Signer, error) {
+	if kid == "" {
+		return nil, fmt.Errorf("key id cannot be empty")
+	}
+
+	method, err := signingMethodFor(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signer{kid: kid, method: method, key: key}, nil
+}
+
+// KeyID returns the id set in the "kid" header of the signed tokens.
+func (s *Signer) KeyID() string {
+	return s.kid
+}
+
+// Algorithm returns the JWS algorithm (i.e. "RS256").
+func (s *Signer) Algorithm() string {
+	return s.method.Alg()
+}
+
+// JWK returns the public key to publish in a JWKS.
+func (s *Signer) JWK() JWK {
+	res, _ := NewJWK(s.kid, s.key.Public())
+	return res
+}
+
+func (s *Signer) sign(claims jwt.Claims) (string, error) {
+	tok := jwt.NewWithClaims(s.method, claims)
+	tok.Header["kid"] = s.kid
+
+	return tok.SignedString(s.key)
+}
+
+func signingMethodFor(pub crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported elliptic curve %q", k.Curve.Params().Name)
+		}
+		return jwt.SigningMethodES256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", pub)
+	}
+}
+
+// curves maps the JWK curve names to the ones of the EC keys.
+var curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// verifyMethods lists the algorithms accepted by ParseWithKeys for
+// each key type; the ones of the tokens signed by other providers
+// are accepted too, not only the ones used by Signer.
+var verifyMethods = map[string][]string{
+	"RSA": {"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"},
+	"EC":  {"ES256", "ES384", "ES512"},
+	"OKP": {"EdDSA"},
+}
+
+// ecMethods maps the curves to the only algorithm they can verify.
+var ecMethods = map[string]string{
+	"P-256": "ES256",
+	"P-384": "ES384",
+	"P-521": "ES512",
+}
+
+// JWK is a public JSON Web Key (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK encodes a public key supported by NewSigner.
+func NewJWK(kid string, pub crypto.PublicKey) (JWK, error) {
+	method, err := signingMethodFor(pub)
+	if err != nil {
+		return JWK{}, err
+	}
+
+	res := JWK{Kid: kid, Use: "sig", Alg: method.Alg()}
+
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		res.Kty = "RSA"
+		res.N = b64(k.N.Bytes())
+		res.E = b64(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		raw, err := k.Bytes() // uncompressed point: 0x04 || X || Y
+		if err != nil {
+			return JWK{}, err
+		}
+		size := (len(raw) - 1) / 2
+		res.Kty = "EC"
+		res.Crv = k.Curve.Params().Name
+		res.X = b64(raw[1 : 1+size])
+		res.Y = b64(raw[1+size:])
+	case ed25519.PublicKey:
+		res.Kty = "OKP"
+		res.Crv = "Ed25519"
+		res.X = b64(k)
+	}
+
+	return res, nil
+}
+
+// PublicKey decodes the JWK public key.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := unb64(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := unb64(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 || exp.Int64() < 3 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+
+	case "EC":
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported elliptic curve %q", k.Crv)
+		}
+		x, err := unb64(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC x coordinate: %w", err)
+		}
+		y, err := unb64(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC y coordinate: %w", err)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, fmt.Errorf("invalid EC coordinates length")
+		}
+		raw := append(append([]byte{4}, x...), y...)
+		return ecdsa.ParseUncompressedPublicKey(curve, raw)
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := unb64(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Ed25519 key: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key length")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// CanVerify reports whether the key can verify tokens signed
+// with the specified JWS algorithm (i.e. "PS256").
+func (k JWK) CanVerify(alg string) bool {
+	if k.Alg != "" {
+		return k.Alg == alg
+	}
+	if k.Kty == "EC" {
+		return ecMethods[k.Crv] == alg
+	}
+	return slices.Contains(verifyMethods[k.Kty], alg)
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func unb64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
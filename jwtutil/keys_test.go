@@ -0,0 +1,91 @@
+package jwtutil_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+
+	"github.com/krateoplatformops/plumbing/jwtutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKeys(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return map[string]crypto.Signer{
+		"RS256": rsaKey,
+		"ES256": ecKey,
+		"EdDSA": edKey,
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	for alg, key := range testKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			signer, err := jwtutil.NewSigner("key-1", key)
+			require.NoError(t, err)
+			assert.Equal(t, alg, signer.Algorithm())
+			assert.Equal(t, "key-1", signer.KeyID())
+		})
+	}
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, err = jwtutil.NewSigner("key-1", p384)
+	assert.Error(t, err)
+
+	_, err = jwtutil.NewSigner("", p384)
+	assert.Error(t, err)
+}
+
+func TestJWKRoundTrip(t *testing.T) {
+	for alg, key := range testKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			jwk, err := jwtutil.NewJWK("key-1", key.Public())
+			require.NoError(t, err)
+			assert.Equal(t, alg, jwk.Alg)
+			assert.Equal(t, "sig", jwk.Use)
+
+			data, err := json.Marshal(jwk)
+			require.NoError(t, err)
+
+			var got jwtutil.JWK
+			require.NoError(t, json.Unmarshal(data, &got))
+
+			pub, err := got.PublicKey()
+			require.NoError(t, err)
+
+			type equaler interface{ Equal(crypto.PublicKey) bool }
+			assert.True(t, pub.(equaler).Equal(key.Public()))
+		})
+	}
+}
+
+func TestJWKPublicKeyErrors(t *testing.T) {
+	tests := []jwtutil.JWK{
+		{Kty: "oct"},
+		{Kty: "EC", Crv: "P-384"},
+		{Kty: "EC", Crv: "P-256", X: "AAAA", Y: "AAAA"},
+		{Kty: "OKP", Crv: "Ed25519", X: "AAAA"},
+		{Kty: "RSA", N: "AQAB", E: "AQ"},
+		{Kty: "RSA", N: "!", E: "AQAB"},
+	}
+
+	for _, tc := range tests {
+		_, err := tc.PublicKey()
+		assert.Error(t, err, "%+v", tc)
+	}
+}
@@ -0,0 +1,108 @@
+package jwtutil
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TokenPair is a short lived access token with
+// the long lived refresh token used to renew it.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+type TokenPairOptions struct {
+	// CreateTokenOptions sets the user and the signing key;
+	// Duration is the lifetime of the access token.
+	CreateTokenOptions
+	// RefreshDuration is the lifetime of the refresh token.
+	RefreshDuration time.Duration
+}
+
+// CreateTokenPair generates an access token and its refresh token.
+func CreateTokenPair(opts TokenPairOptions) (TokenPair, error) {
+	if opts.RefreshDuration < opts.Duration {
+		return TokenPair{}, fmt.Errorf("refresh duration cannot be shorter than the access token one")
+	}
+
+	access, err := createToken(opts.CreateTokenOptions, TokenUseAccess, opts.Duration)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refresh, err := createToken(opts.CreateTokenOptions, TokenUseRefresh, opts.RefreshDuration)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{AccessToken: access, RefreshToken: refresh}, nil
+}
+
+type RefreshOptions struct {
+	// TokenPairOptions sets the signing key and the lifetimes of the new
+	// tokens; the user is the one of the refresh token.
+	TokenPairOptions
+	// Keys verifies the refresh tokens signed by a Signer
+	// (default: the Signer public key).
+	Keys KeyProvider
+	// Store, when set, rejects the revoked refresh tokens and
+	// revokes the exchanged ones, so that they can be used only once.
+	Store RevocationStore
+	// Lookup, when set, reloads the user info (i.e. the current groups)
+	// before issuing the new tokens; an error denies the exchange.
+	Lookup func(context.Context, UserInfo) (UserInfo, error)
+}
+
+// RefreshTokenPair exchanges a refresh token for a new token pair.
+func RefreshTokenPair(ctx context.Context, refreshToken string, opts RefreshOptions) (TokenPair, error) {
+	var (
+		claims *KrateoClaims
+		err    error
+	)
+	if opts.Signer != nil {
+		keys := opts.Keys
+		if keys == nil {
+			keys = NewKeySet(opts.Signer.JWK())
+		}
+		claims = &KrateoClaims{}
+		if _, err = ParseWithKeys(ctx, keys, refreshToken, claims); err != nil {
+			err = tokenError(err)
+		}
+	} else {
+		claims, err = parseClaims(opts.SigningKey, refreshToken)
+	}
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if claims.TokenUse != TokenUseRefresh {
+		return TokenPair{}, ErrTokenInvalid
+	}
+
+	if err := CheckRevoked(ctx, opts.Store, claims); err != nil {
+		return TokenPair{}, err
+	}
+
+	userInfo := claims.UserInfo
+	if opts.Lookup != nil {
+		userInfo, err = opts.Lookup(ctx, userInfo)
+		if err != nil {
+			return TokenPair{}, err
+		}
+	}
+
+	if opts.Store != nil && claims.ExpiresAt != nil {
+		// CheckRevoked alone would let concurrent exchanges through
+		revoked, err := opts.Store.RevokeOnce(ctx, claims.ID, claims.ExpiresAt.Time)
+		if err != nil {
+			return TokenPair{}, err
+		}
+		if revoked {
+			return TokenPair{}, ErrTokenRevoked
+		}
+	}
+
+	opts.Username, opts.Groups = userInfo.Username, userInfo.Groups
+	return CreateTokenPair(opts.TokenPairOptions)
+}
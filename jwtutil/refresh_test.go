@@ -0,0 +1,165 @@
+package jwtutil_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/jwtutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshTokenPair(t *testing.T) {
+	ctx := context.Background()
+
+	store := jwtutil.NewMemoryRevocationStore()
+	defer store.Close()
+
+	pairOpts := jwtutil.TokenPairOptions{
+		CreateTokenOptions: jwtutil.CreateTokenOptions{
+			Username:   "alice",
+			Groups:     []string{"admins"},
+			Duration:   time.Minute,
+			SigningKey: "secret",
+		},
+		RefreshDuration: time.Hour,
+	}
+
+	pair, err := jwtutil.CreateTokenPair(pairOpts)
+	require.NoError(t, err)
+
+	user, err := jwtutil.Validate("secret", pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+
+	_, err = jwtutil.Validate("secret", pair.RefreshToken)
+	assert.ErrorIs(t, err, jwtutil.ErrTokenInvalid, "refresh tokens are not access tokens")
+
+	refreshOpts := jwtutil.RefreshOptions{
+		TokenPairOptions: jwtutil.TokenPairOptions{
+			CreateTokenOptions: jwtutil.CreateTokenOptions{
+				Duration:   time.Minute,
+				SigningKey: "secret",
+			},
+			RefreshDuration: time.Hour,
+		},
+		Store: store,
+		Lookup: func(_ context.Context, ui jwtutil.UserInfo) (jwtutil.UserInfo, error) {
+			ui.Groups = []string{"devs"}
+			return ui, nil
+		},
+	}
+
+	_, err = jwtutil.RefreshTokenPair(ctx, pair.AccessToken, refreshOpts)
+	assert.ErrorIs(t, err, jwtutil.ErrTokenInvalid, "access tokens cannot be exchanged")
+
+	renewed, err := jwtutil.RefreshTokenPair(ctx, pair.RefreshToken, refreshOpts)
+	require.NoError(t, err)
+
+	user, err = jwtutil.Validate("secret", renewed.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+	assert.Equal(t, []string{"devs"}, user.Groups)
+
+	_, err = jwtutil.RefreshTokenPair(ctx, pair.RefreshToken, refreshOpts)
+	assert.ErrorIs(t, err, jwtutil.ErrTokenRevoked, "refresh tokens can be used once")
+
+	require.NoError(t, store.RevokeUser(ctx, "alice", time.Hour))
+	_, err = jwtutil.RefreshTokenPair(ctx, renewed.RefreshToken, refreshOpts)
+	assert.ErrorIs(t, err, jwtutil.ErrTokenRevoked)
+}
+
+func TestRefreshTokenPairConcurrent(t *testing.T) {
+	store := jwtutil.NewMemoryRevocationStore()
+	defer store.Close()
+
+	opts := jwtutil.TokenPairOptions{
+		CreateTokenOptions: jwtutil.CreateTokenOptions{
+			Username:   "alice",
+			Duration:   time.Minute,
+			SigningKey: "secret",
+		},
+		RefreshDuration: time.Hour,
+	}
+
+	pair, err := jwtutil.CreateTokenPair(opts)
+	require.NoError(t, err)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		renewed  int
+		rejected int
+	)
+	for range 16 {
+		wg.Go(func() {
+			_, err := jwtutil.RefreshTokenPair(context.Background(), pair.RefreshToken,
+				jwtutil.RefreshOptions{TokenPairOptions: opts, Store: store})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				renewed++
+			} else if errors.Is(err, jwtutil.ErrTokenRevoked) {
+				rejected++
+			}
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, renewed, "refresh tokens can be used once")
+	assert.Equal(t, 15, rejected)
+}
+
+func TestRefreshTokenPairLookupDenied(t *testing.T) {
+	denied := errors.New("user removed")
+
+	opts := jwtutil.TokenPairOptions{
+		CreateTokenOptions: jwtutil.CreateTokenOptions{
+			Username:   "alice",
+			Duration:   time.Minute,
+			SigningKey: "secret",
+		},
+		RefreshDuration: time.Hour,
+	}
+
+	pair, err := jwtutil.CreateTokenPair(opts)
+	require.NoError(t, err)
+
+	_, err = jwtutil.RefreshTokenPair(context.Background(), pair.RefreshToken, jwtutil.RefreshOptions{
+		TokenPairOptions: opts,
+		Lookup: func(context.Context, jwtutil.UserInfo) (jwtutil.UserInfo, error) {
+			return jwtutil.UserInfo{}, denied
+		},
+	})
+	assert.ErrorIs(t, err, denied)
+}
+
+func TestRefreshTokenPairWithSigner(t *testing.T) {
+	signer, err := jwtutil.NewSigner("key-1", testKeys(t)["EdDSA"])
+	require.NoError(t, err)
+
+	opts := jwtutil.TokenPairOptions{
+		CreateTokenOptions: jwtutil.CreateTokenOptions{
+			Username: "alice",
+			Duration: time.Minute,
+			Signer:   signer,
+		},
+		RefreshDuration: time.Hour,
+	}
+
+	pair, err := jwtutil.CreateTokenPair(opts)
+	require.NoError(t, err)
+
+	renewed, err := jwtutil.RefreshTokenPair(context.Background(), pair.RefreshToken,
+		jwtutil.RefreshOptions{TokenPairOptions: opts})
+	require.NoError(t, err)
+
+	user, err := jwtutil.ValidateWithKeys(context.Background(),
+		jwtutil.NewKeySet(signer.JWK()), renewed.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+}
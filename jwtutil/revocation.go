@@ -0,0 +1,109 @@
+package jwtutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/cache"
+)
+
+var ErrTokenRevoked = errors.New("token is revoked")
+
+// RevocationStore keeps the revoked tokens until they would expire anyway.
+type RevocationStore interface {
+	// RevokeToken revokes the token with the specified id ("jti").
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeOnce atomically revokes the token with the specified id,
+	// reporting whether it had already been revoked (i.e. by a concurrent
+	// call); it guards the tokens that can be used only once.
+	RevokeOnce(ctx context.Context, jti string, expiresAt time.Time) (bool, error)
+	// RevokeUser revokes all the tokens of the user issued so far; ttl
+	// should be the lifetime of the longest lived token (i.e. refresh).
+	// Since "iat" has second precision, tokens issued within the same
+	// second of the revocation are revoked too.
+	RevokeUser(ctx context.Context, username string, ttl time.Duration) error
+	// IsRevoked reports whether the token, or all the user tokens issued
+	// not after issuedAt, have been revoked.
+	IsRevoked(ctx context.Context, jti, username string, issuedAt time.Time) (bool, error)
+}
+
+// CheckRevoked returns ErrTokenRevoked if the token has been revoked.
+func CheckRevoked(ctx context.Context, store RevocationStore, claims *KrateoClaims) error {
+	if store == nil {
+		return nil
+	}
+
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+
+	revoked, err := store.IsRevoked(ctx, claims.ID, claims.Username, issuedAt)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// MemoryRevocationStore is a RevocationStore local to the process.
+type MemoryRevocationStore struct {
+	mu    sync.Mutex
+	items *cache.TTLCache[string, time.Time]
+}
+
+var _ RevocationStore = (*MemoryRevocationStore)(nil)
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		items: cache.NewTTL[string, time.Time](cache.WithCleanupInterval(time.Minute)),
+	}
+}
+
+// Close stops the expired entries cleanup.
+func (s *MemoryRevocationStore) Close() {
+	s.items.Close()
+}
+
+func (s *MemoryRevocationStore) RevokeToken(_ context.Context, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return nil
+	}
+	s.items.Set("jti:"+jti, time.Now(), time.Until(expiresAt))
+	return nil
+}
+
+func (s *MemoryRevocationStore) RevokeOnce(_ context.Context, jti string, expiresAt time.Time) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items.Get("jti:" + jti); ok {
+		return true, nil
+	}
+	s.items.Set("jti:"+jti, time.Now(), time.Until(expiresAt))
+	return false, nil
+}
+
+func (s *MemoryRevocationStore) RevokeUser(_ context.Context, username string, ttl time.Duration) error {
+	s.items.Set("user:"+username, time.Now(), ttl)
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsRevoked(_ context.Context, jti, username string, issuedAt time.Time) (bool, error) {
+	if jti != "" {
+		if _, ok := s.items.Get("jti:" + jti); ok {
+			return true, nil
+		}
+	}
+
+	revokedAt, ok := s.items.Get("user:" + username)
+	return ok && !issuedAt.After(revokedAt), nil
+}
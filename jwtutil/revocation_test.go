@@ -0,0 +1,85 @@
+package jwtutil_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/jwtutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRevocationStore(t *testing.T) {
+	ctx := context.Background()
+
+	store := jwtutil.NewMemoryRevocationStore()
+	defer store.Close()
+
+	issued := time.Now().Add(-time.Second)
+
+	revoked, err := store.IsRevoked(ctx, "jti-1", "alice", issued)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	require.NoError(t, store.RevokeToken(ctx, "jti-1", time.Now().Add(time.Minute)))
+	revoked, _ = store.IsRevoked(ctx, "jti-1", "alice", issued)
+	assert.True(t, revoked)
+	revoked, _ = store.IsRevoked(ctx, "jti-2", "alice", issued)
+	assert.False(t, revoked)
+
+	require.NoError(t, store.RevokeUser(ctx, "alice", time.Minute))
+	revoked, _ = store.IsRevoked(ctx, "jti-2", "alice", issued)
+	assert.True(t, revoked)
+	revoked, _ = store.IsRevoked(ctx, "jti-3", "alice", time.Now().Add(time.Minute))
+	assert.False(t, revoked, "tokens issued after the revocation are valid")
+	revoked, _ = store.IsRevoked(ctx, "jti-2", "bob", issued)
+	assert.False(t, revoked)
+
+	// entries expire with the tokens
+	require.NoError(t, store.RevokeToken(ctx, "jti-4", time.Now().Add(-time.Second)))
+	revoked, _ = store.IsRevoked(ctx, "jti-4", "bob", issued)
+	assert.False(t, revoked)
+}
+
+func TestMemoryRevocationStoreRevokeOnce(t *testing.T) {
+	ctx := context.Background()
+
+	store := jwtutil.NewMemoryRevocationStore()
+	defer store.Close()
+
+	revoked, err := store.RevokeOnce(ctx, "jti-1", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	revoked, err = store.RevokeOnce(ctx, "jti-1", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, _ = store.IsRevoked(ctx, "jti-1", "alice", time.Now())
+	assert.True(t, revoked)
+}
+
+func TestCheckRevoked(t *testing.T) {
+	ctx := context.Background()
+
+	store := jwtutil.NewMemoryRevocationStore()
+	defer store.Close()
+
+	token, err := jwtutil.CreateToken(jwtutil.CreateTokenOptions{
+		Username:   "alice",
+		Duration:   time.Minute,
+		SigningKey: "secret",
+	})
+	require.NoError(t, err)
+
+	claims, err := jwtutil.ValidateClaims("secret", token)
+	require.NoError(t, err)
+	assert.NotEmpty(t, claims.ID)
+
+	assert.NoError(t, jwtutil.CheckRevoked(ctx, nil, claims))
+	assert.NoError(t, jwtutil.CheckRevoked(ctx, store, claims))
+
+	require.NoError(t, store.RevokeUser(ctx, "alice", time.Hour))
+	assert.ErrorIs(t, jwtutil.CheckRevoked(ctx, store, claims), jwtutil.ErrTokenRevoked)
+}
@@ -1,6 +1,7 @@
 package jwtutil
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
@@ -14,25 +15,139 @@ var (
 )
 
 func Validate(signingKey, bearer string) (UserInfo, error) {
+	claims, err := ValidateClaims(signingKey, bearer)
+	if err != nil {
+		return UserInfo{}, err
+	}
+	return claims.UserInfo, nil
+}
+
+// ValidateClaims verifies an HS256 access token returning all its claims
+// (i.e. the "jti" used to check the revocations).
+func ValidateClaims(signingKey, bearer string) (*KrateoClaims, error) {
+	claims, err := parseClaims(signingKey, bearer)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenUse == TokenUseRefresh {
+		return nil, ErrTokenInvalid
+	}
+	return claims, nil
+}
+
+// ValidateWithKeys verifies a token signed by a Signer using the
+// public key found in the key set by its "kid" header.
+func ValidateWithKeys(ctx context.Context, keys KeyProvider, bearer string) (UserInfo, error) {
+	claims, err := ValidateClaimsWithKeys(ctx, keys, bearer)
+	if err != nil {
+		return UserInfo{}, err
+	}
+	return claims.UserInfo, nil
+}
+
+// ValidateClaimsWithKeys is like ValidateWithKeys but returns all the claims.
+func ValidateClaimsWithKeys(ctx context.Context, keys KeyProvider, bearer string) (*KrateoClaims, error) {
+	claims := &KrateoClaims{}
+	if _, err := ParseWithKeys(ctx, keys, bearer, claims); err != nil {
+		return nil, tokenError(err)
+	}
+	if claims.TokenUse == TokenUseRefresh {
+		return nil, ErrTokenInvalid
+	}
+	return claims, nil
+}
+
+func parseClaims(signingKey, bearer string) (*KrateoClaims, error) {
 	if signingKey == "" {
-		return UserInfo{}, fmt.Errorf("signing key cannot be empty")
+		return nil, fmt.Errorf("signing key cannot be empty")
 	}
 
-	tok, err := jwt.ParseWithClaims(bearer, &KrateoClaims{},
+	claims := &KrateoClaims{}
+	_, err := jwt.ParseWithClaims(bearer, claims,
 		func(token *jwt.Token) (any, error) {
 			return []byte(signingKey), nil
-		}, jwt.WithLeeway(5*time.Second))
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithLeeway(5*time.Second))
 	if err != nil {
-		if !errors.Is(err, jwt.ErrTokenExpired) {
-			return UserInfo{}, ErrTokenInvalid
-		}
-		return UserInfo{}, ErrTokenExpired
+		return nil, tokenError(err)
+	}
+	return claims, nil
+}
+
+func tokenError(err error) error {
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return ErrTokenExpired
+	}
+	return ErrTokenInvalid
+}
+
+// ParseWithKeys parses and verifies an asymmetrically signed token into
+// the claims, looking up the public key by the "kid" header; extra
+// parser options (i.e. jwt.WithAudience) can be specified.
+//
+// Tokens without a "kid" header are verified trying every key able to
+// verify their algorithm, when the provider is also a KeyLister.
+func ParseWithKeys(ctx context.Context, keys KeyProvider, bearer string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	if keys == nil {
+		return nil, fmt.Errorf("key provider cannot be nil")
 	}
 
-	claims, ok := tok.Claims.(*KrateoClaims)
+	var methods []string
+	for _, el := range verifyMethods {
+		methods = append(methods, el...)
+	}
+
+	opts = append([]jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithLeeway(5 * time.Second),
+	}, opts...)
+
+	return jwt.ParseWithClaims(bearer, claims,
+		func(token *jwt.Token) (any, error) {
+			alg := token.Method.Alg()
+
+			kid, _ := token.Header["kid"].(string)
+			if kid == "" {
+				return candidateKeys(ctx, keys, alg)
+			}
+
+			jwk, pub, err := keys.PublicKey(ctx, kid)
+			if err != nil {
+				return nil, err
+			}
+			if !jwk.CanVerify(alg) {
+				return nil, fmt.Errorf("key %q cannot verify %s tokens", kid, alg)
+			}
+			return pub, nil
+		}, opts...)
+}
+
+// candidateKeys returns the keys able to verify the alg tokens.
+func candidateKeys(ctx context.Context, keys KeyProvider, alg string) (jwt.VerificationKeySet, error) {
+	lister, ok := keys.(KeyLister)
 	if !ok {
-		return UserInfo{}, ErrTokenInvalid
+		return jwt.VerificationKeySet{}, fmt.Errorf("missing key id")
 	}
 
-	return claims.UserInfo, nil
+	all, err := lister.Keys(ctx)
+	if err != nil {
+		return jwt.VerificationKeySet{}, err
+	}
+
+	res := jwt.VerificationKeySet{}
+	for _, el := range all {
+		if !el.CanVerify(alg) {
+			continue
+		}
+		pub, err := el.PublicKey()
+		if err != nil {
+			continue
+		}
+		res.Keys = append(res.Keys, pub)
+	}
+	if len(res.Keys) == 0 {
+		return res, fmt.Errorf("no key can verify %s tokens", alg)
+	}
+	return res, nil
 }
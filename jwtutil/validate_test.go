@@ -1,11 +1,20 @@
 package jwtutil_test
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/krateoplatformops/plumbing/jwtutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetUserInfo(t *testing.T) {
@@ -74,3 +83,126 @@ func TestGetUserInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateWithKeys(t *testing.T) {
+	keys := testKeys(t)
+
+	signers := map[string]*jwtutil.Signer{}
+	ks := jwtutil.NewKeySet()
+	for alg, key := range keys {
+		signer, err := jwtutil.NewSigner("kid-"+alg, key)
+		require.NoError(t, err)
+		signers[alg] = signer
+		ks.Add(signer.JWK())
+	}
+
+	create := func(opts jwtutil.CreateTokenOptions) string {
+		opts.Username = "alice"
+		opts.Groups = []string{"admin"}
+		if opts.Duration == 0 {
+			opts.Duration = time.Minute
+		}
+		tok, err := jwtutil.CreateToken(opts)
+		require.NoError(t, err)
+		return tok
+	}
+
+	for alg, signer := range signers {
+		t.Run(alg, func(t *testing.T) {
+			user, err := jwtutil.ValidateWithKeys(context.Background(), ks,
+				create(jwtutil.CreateTokenOptions{Signer: signer}))
+			require.NoError(t, err)
+			assert.Equal(t, "alice", user.Username)
+			assert.Equal(t, []string{"admin"}, user.Groups)
+		})
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		_, err := jwtutil.ValidateWithKeys(context.Background(), ks,
+			create(jwtutil.CreateTokenOptions{Signer: signers["ES256"], Duration: -time.Minute}))
+		assert.ErrorIs(t, err, jwtutil.ErrTokenExpired)
+	})
+
+	t.Run("shared secret token", func(t *testing.T) {
+		_, err := jwtutil.ValidateWithKeys(context.Background(), ks,
+			create(jwtutil.CreateTokenOptions{SigningKey: "secret"}))
+		assert.ErrorIs(t, err, jwtutil.ErrTokenInvalid)
+	})
+
+	t.Run("key not matching kid", func(t *testing.T) {
+		other, err := jwtutil.NewSigner("kid-RS256", keys["EdDSA"])
+		require.NoError(t, err)
+
+		_, err = jwtutil.ValidateWithKeys(context.Background(), ks,
+			create(jwtutil.CreateTokenOptions{Signer: other}))
+		assert.ErrorIs(t, err, jwtutil.ErrTokenInvalid)
+	})
+
+	t.Run("unknown kid", func(t *testing.T) {
+		other, err := jwtutil.NewSigner("unknown", keys["EdDSA"])
+		require.NoError(t, err)
+
+		_, err = jwtutil.ValidateWithKeys(context.Background(), ks,
+			create(jwtutil.CreateTokenOptions{Signer: other}))
+		assert.ErrorIs(t, err, jwtutil.ErrTokenInvalid)
+	})
+}
+
+func TestParseWithKeysForeignTokens(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	rsaJWK, err := jwtutil.NewJWK("rsa", rsaKey.Public())
+	require.NoError(t, err)
+	rsaJWK.Alg = "" // as published by providers signing with several algorithms
+
+	ecJWK := jwtutil.JWK{Kty: "EC", Kid: "p384", Crv: "P-384"}
+	raw, err := p384.PublicKey.Bytes()
+	require.NoError(t, err)
+	ecJWK.X = base64.RawURLEncoding.EncodeToString(raw[1:49])
+	ecJWK.Y = base64.RawURLEncoding.EncodeToString(raw[49:])
+
+	ks := jwtutil.NewKeySet(rsaJWK, ecJWK)
+
+	sign := func(method jwt.SigningMethod, kid string, key any) string {
+		tok := jwt.NewWithClaims(method, jwt.MapClaims{
+			"sub": "alice",
+			"exp": time.Now().Add(time.Minute).Unix(),
+		})
+		if kid != "" {
+			tok.Header["kid"] = kid
+		}
+		res, err := tok.SignedString(key)
+		require.NoError(t, err)
+		return res
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"PS256", sign(jwt.SigningMethodPS256, "rsa", rsaKey), true},
+		{"RS384", sign(jwt.SigningMethodRS384, "rsa", rsaKey), true},
+		{"ES384", sign(jwt.SigningMethodES384, "p384", p384), true},
+		{"PS512 without kid", sign(jwt.SigningMethodPS512, "", rsaKey), true},
+		{"ES384 without kid", sign(jwt.SigningMethodES384, "", p384), true},
+		{"curve not matching algorithm", sign(jwt.SigningMethodES256, "p384", p256), false},
+		{"no key for algorithm", sign(jwt.SigningMethodEdDSA, "", ed25519.NewKeyFromSeed(make([]byte, 32))), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := jwtutil.ParseWithKeys(context.Background(), ks, tc.token, jwt.MapClaims{})
+			if tc.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
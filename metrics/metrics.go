@@ -0,0 +1,67 @@
+// Package metrics holds the Prometheus registry shared by the
+// server middlewares and the HTTP clients of this module.
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Registry collects all the metrics exposed by Handler,
+// including the Go runtime and process ones.
+var Registry = newRegistry()
+
+func newRegistry() *prometheus.Registry {
+	res := prometheus.NewRegistry()
+	res.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return res
+}
+
+// MustRegister adds the collectors to the Registry,
+// panicking if any of them is already registered.
+func MustRegister(cs ...prometheus.Collector) {
+	Registry.MustRegister(cs...)
+}
+
+// Gatherer gathers the Registry together with prometheus.DefaultGatherer,
+// so that the collectors registered through the prometheus package are
+// exposed too; the families found in both (i.e. the Go runtime and process
+// ones) are taken from the Registry.
+var Gatherer prometheus.Gatherer = prometheus.GathererFunc(gather)
+
+func gather() ([]*dto.MetricFamily, error) {
+	res, err := Registry.Gather()
+	more, moreErr := prometheus.DefaultGatherer.Gather()
+
+	seen := make(map[string]bool, len(res))
+	for _, mf := range res {
+		seen[mf.GetName()] = true
+	}
+	for _, mf := range more {
+		if !seen[mf.GetName()] {
+			res = append(res, mf)
+		}
+	}
+	slices.SortFunc(res, func(a, b *dto.MetricFamily) int {
+		return strings.Compare(a.GetName(), b.GetName())
+	})
+
+	return res, errors.Join(err, moreErr)
+}
+
+// Handler serves the Gatherer metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Gatherer, promhttp.HandlerOpts{
+		Registry: Registry,
+	})
+}
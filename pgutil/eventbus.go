@@ -0,0 +1,732 @@
+package pgutil
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/krateoplatformops/plumbing/eventbus"
+)
+
+const eventBusSchema = `
+CREATE TABLE IF NOT EXISTS eventbus_consumers (
+	name          TEXT PRIMARY KEY,
+	registered_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS eventbus_outbox (
+	id         BIGSERIAL PRIMARY KEY,
+	event_id   TEXT NOT NULL,
+	payload    JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS eventbus_deliveries (
+	consumer   TEXT NOT NULL,
+	event      BIGINT NOT NULL REFERENCES eventbus_outbox (id) ON DELETE CASCADE,
+	status     TEXT NOT NULL DEFAULT 'pending',
+	attempts   INT NOT NULL DEFAULT 0,
+	next_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_error TEXT NOT NULL DEFAULT '',
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	claim      TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (consumer, event)
+);
+CREATE INDEX IF NOT EXISTS eventbus_deliveries_pending_idx
+	ON eventbus_deliveries (consumer, next_at) WHERE status = 'pending';
+`
+
+const (
+	deliveryPending = "pending"
+	deliveryAcked   = "acked"
+	deliveryDead    = "dead"
+)
+
+var (
+	_ eventbus.Bus              = (*EventBus)(nil)
+	_ eventbus.OptionSubscriber = (*EventBus)(nil)
+	_ eventbus.Closer           = (*EventBus)(nil)
+)
+
+// localBus is the in memory bus, returned by eventbus.New,
+// running the handlers.
+type localBus interface {
+	eventbus.Bus
+	eventbus.OptionSubscriber
+	eventbus.Closer
+}
+
+type EventBusOptions struct {
+	// Consumer names the group of replicas sharing the deliveries:
+	// every event is handled once by each consumer (required).
+	Consumer string
+	// Channel is the LISTEN/NOTIFY channel used to wake
+	// the consumers up (default: "eventbus").
+	Channel string
+	// MaxAttempts is how many times an event is handled before
+	// being dead-lettered (default: 10).
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry,
+	// doubled at each attempt (default: 1s).
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries (default: 5m).
+	MaxBackoff time.Duration
+	// Lease is how long the handlers can take before the event is
+	// handed to another replica, i.e. when this one crashed (default: 1m);
+	// the lease of the claimed events is renewed before each one is handled.
+	Lease time.Duration
+	// PollInterval is how often the outbox is checked besides
+	// the notifications, i.e. for the retries (default: 5s).
+	PollInterval time.Duration
+	// BatchSize is how many events are claimed at once (default: 100).
+	BatchSize int
+	// Retention is how long the handled events are kept (default: 24h).
+	Retention time.Duration
+	// DeadLetterHook, when set, is called for every dead-lettered event.
+	DeadLetterHook func(DeadLetter)
+	// BusOptions configure the in memory bus running the handlers.
+	BusOptions []eventbus.Option
+	// Log is the logger used to report the delivery failures.
+	Log *slog.Logger
+}
+
+// DeadLetter is an event whose handlers kept failing.
+type DeadLetter struct {
+	ID        int64
+	EventID   eventbus.EventID
+	Payload   json.RawMessage
+	Attempts  int
+	LastError string
+	FailedAt  time.Time
+}
+
+// RawEvent is an event whose type has not been registered
+// (see RegisterEvent), holding its JSON payload.
+type RawEvent struct {
+	ID      eventbus.EventID
+	Payload json.RawMessage
+}
+
+func (e RawEvent) EventID() eventbus.EventID {
+	return e.ID
+}
+
+// EventBus is an eventbus.Bus storing the published events in the
+// "eventbus_outbox" table, so that they survive restarts; events are
+// delivered at least once, so the handlers must be idempotent.
+//
+// Each event is delivered to all the consumers registered when it was
+// published; the replicas of a consumer share its deliveries and run
+// the handlers subscribed locally. When any handler fails, the event
+// is handled again, with an exponential backoff, until MaxAttempts
+// is reached and it is dead-lettered (see DeadLetters).
+//
+// Events are stored as JSON and decoded, on the consumer side, to the
+// types registered with RegisterEvent or else to RawEvent.
+type EventBus struct {
+	pool  *pgxpool.Pool
+	opts  EventBusOptions
+	local localBus
+
+	mu       sync.RWMutex
+	decoders map[eventbus.EventID]func([]byte) (eventbus.Event, error)
+
+	wake    chan struct{}
+	stop    context.CancelFunc // stops claiming new events
+	kill    context.CancelFunc // cancels the running handlers
+	done    chan struct{}
+	closed  atomic.Bool
+	cleaned time.Time
+}
+
+// NewEventBus creates the event bus tables if missing, registers
+// the consumer and starts delivering the events to it.
+func NewEventBus(ctx context.Context, pool *pgxpool.Pool, opts EventBusOptions) (*EventBus, error) {
+	if len(opts.Consumer) == 0 {
+		return nil, fmt.Errorf("eventbus consumer is required")
+	}
+	if len(opts.Channel) == 0 {
+		opts.Channel = "eventbus"
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 10
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Minute
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = time.Minute
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 5 * time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = 24 * time.Hour
+	}
+	if opts.Log == nil {
+		opts.Log = slog.Default()
+	}
+
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		// replicas starting together would race creating the tables
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('eventbus_schema'))`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, eventBusSchema); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `INSERT INTO eventbus_consumers (name) VALUES ($1) ON CONFLICT DO NOTHING`,
+			opts.Consumer)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create eventbus tables: %w", err)
+	}
+
+	workCtx, kill := context.WithCancel(context.Background())
+	stopCtx, stop := context.WithCancel(workCtx)
+
+	b := &EventBus{
+		pool:     pool,
+		opts:     opts,
+		local:    eventbus.New(opts.BusOptions...).(localBus),
+		decoders: map[eventbus.EventID]func([]byte) (eventbus.Event, error){},
+		wake:     make(chan struct{}, 1),
+		stop:     stop,
+		kill:     kill,
+		done:     make(chan struct{}),
+	}
+
+	var wg sync.WaitGroup
+	wg.Go(func() { b.listen(stopCtx) })
+	wg.Go(func() { b.run(stopCtx, workCtx) })
+	go func() {
+		wg.Wait()
+		close(b.done)
+	}()
+
+	return b, nil
+}
+
+// RegisterEvent decodes the events with the id of the zero value of T
+// to T; T is usually a struct whose EventID returns a constant.
+func RegisterEvent[T eventbus.Event](b *EventBus) {
+	var zero T
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.decoders[zero.EventID()] = func(data []byte) (eventbus.Event, error) {
+		var ev T
+		err := json.Unmarshal(data, &ev)
+		return ev, err
+	}
+}
+
+func (b *EventBus) Subscribe(eventID eventbus.EventID, cb eventbus.EventHandler) eventbus.Subscription {
+	return b.local.Subscribe(eventID, cb)
+}
+
+func (b *EventBus) SubscribeWithOptions(eventID eventbus.EventID, cb eventbus.EventHandler, opts ...eventbus.SubscribeOption) eventbus.Subscription {
+	return b.local.SubscribeWithOptions(eventID, cb, opts...)
+}
+
+func (b *EventBus) Unsubscribe(sub eventbus.Subscription) {
+	b.local.Unsubscribe(sub)
+}
+
+// PublishSync stores the event, returning once it has been committed;
+// Pending counts the consumers it will be delivered to.
+func (b *EventBus) PublishSync(ctx context.Context, event eventbus.Event) eventbus.PublishResult {
+	if b.closed.Load() {
+		return eventbus.PublishResult{Err: eventbus.ErrClosed}
+	}
+
+	n, err := b.publish(ctx, b.pool, event)
+	if err != nil {
+		return eventbus.PublishResult{Err: err}
+	}
+	return eventbus.PublishResult{Pending: n}
+}
+
+func (b *EventBus) PublishAsync(ctx context.Context, event eventbus.Event) <-chan eventbus.PublishResult {
+	resultCh := make(chan eventbus.PublishResult, 1)
+	go func() {
+		defer close(resultCh)
+		resultCh <- b.PublishSync(ctx, event)
+	}()
+	return resultCh
+}
+
+// PublishTx stores the event within the transaction, so that it is
+// published only if the transaction commits (transactional outbox).
+func (b *EventBus) PublishTx(ctx context.Context, tx pgx.Tx, event eventbus.Event) error {
+	_, err := b.publish(ctx, tx, event)
+	return err
+}
+
+// publish stores the event and one delivery for each consumer,
+// notifying them once committed.
+func (b *EventBus) publish(ctx context.Context, db interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}, event eventbus.Event) (int, error) {
+	if event == nil {
+		panic("eventbus: nil event")
+	}
+	if v, ok := event.(eventbus.Validator); ok {
+		if err := v.Validate(); err != nil {
+			return 0, fmt.Errorf("%w %q: %w", eventbus.ErrInvalidEvent, event.EventID(), err)
+		}
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("unable to encode event %q: %w", event.EventID(), err)
+	}
+
+	var n int
+	err = db.QueryRow(ctx, `
+WITH ev AS (
+	INSERT INTO eventbus_outbox (event_id, payload) VALUES ($1, $2) RETURNING id
+), dl AS (
+	INSERT INTO eventbus_deliveries (consumer, event)
+	SELECT c.name, ev.id FROM eventbus_consumers c, ev
+	RETURNING 1
+), notified AS (
+	SELECT pg_notify($3, '')
+)
+SELECT (SELECT count(*) FROM dl) FROM notified`,
+		string(event.EventID()), payload, b.opts.Channel).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("unable to publish event %q: %w", event.EventID(), err)
+	}
+	return n, nil
+}
+
+// DeadLetters returns the dead-lettered events of the consumer,
+// oldest first.
+func (b *EventBus) DeadLetters(ctx context.Context, limit int) ([]DeadLetter, error) {
+	rows, err := b.pool.Query(ctx, `
+SELECT d.event, o.event_id, o.payload, d.attempts, d.last_error, d.updated_at
+FROM eventbus_deliveries d JOIN eventbus_outbox o ON o.id = d.event
+WHERE d.consumer = $1 AND d.status = $2
+ORDER BY d.event
+LIMIT $3`,
+		b.opts.Consumer, deliveryDead, limit)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list dead letters: %w", err)
+	}
+
+	res, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (DeadLetter, error) {
+		var dl DeadLetter
+		err := row.Scan(&dl.ID, &dl.EventID, &dl.Payload, &dl.Attempts, &dl.LastError, &dl.FailedAt)
+		return dl, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list dead letters: %w", err)
+	}
+	return res, nil
+}
+
+// Requeue delivers a dead-lettered event again,
+// resetting its attempts.
+func (b *EventBus) Requeue(ctx context.Context, id int64) error {
+	tag, err := b.pool.Exec(ctx, `
+UPDATE eventbus_deliveries
+SET status = $3, attempts = 0, next_at = now(), last_error = '', updated_at = now()
+WHERE consumer = $1 AND event = $2 AND status = $4`,
+		b.opts.Consumer, id, deliveryPending, deliveryDead)
+	if err != nil {
+		return fmt.Errorf("unable to requeue event %d: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("dead letter %d not found", id)
+	}
+
+	b.notify()
+	return nil
+}
+
+// Unregister removes the consumer together with its pending deliveries,
+// i.e. when a service is decommissioned; the bus is closed as well.
+func (b *EventBus) Unregister(ctx context.Context) error {
+	if err := b.Close(ctx); err != nil {
+		return err
+	}
+
+	err := pgx.BeginFunc(ctx, b.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM eventbus_consumers WHERE name = $1`, b.opts.Consumer); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `DELETE FROM eventbus_deliveries WHERE consumer = $1`, b.opts.Consumer)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to unregister consumer %q: %w", b.opts.Consumer, err)
+	}
+	return nil
+}
+
+// Close stops claiming new events and waits for the handlers running;
+// when the context is done first, they are canceled and the events
+// are delivered again once their lease expires.
+func (b *EventBus) Close(ctx context.Context) error {
+	if !b.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	b.stop()
+
+	var err error
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		b.kill()
+		err = fmt.Errorf("eventbus: close: %w", ctx.Err())
+	}
+
+	return errors.Join(err, b.local.Close(ctx))
+}
+
+func (b *EventBus) notify() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// listen wakes the dispatcher up on notifications, reconnecting on
+// failures; meanwhile the events are still found by polling.
+func (b *EventBus) listen(ctx context.Context) {
+	backoff := b.opts.InitialBackoff
+	for {
+		err := b.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		b.opts.Log.Warn("eventbus listener failed",
+			slog.String("channel", b.opts.Channel),
+			slog.Duration("retryIn", backoff),
+			slog.Any("err", err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, b.opts.MaxBackoff)
+	}
+}
+
+func (b *EventBus) listenOnce(ctx context.Context) error {
+	pc, err := b.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// a listening connection must not go back to the pool
+	conn := pc.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{b.opts.Channel}.Sanitize()); err != nil {
+		return err
+	}
+	// events published while not listening
+	b.notify()
+
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return err
+		}
+		b.notify()
+	}
+}
+
+// run delivers the events until stopCtx is done; workCtx
+// is used for the handlers and the queries.
+func (b *EventBus) run(stopCtx, workCtx context.Context) {
+	ticker := time.NewTicker(b.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for stopCtx.Err() == nil {
+			n, err := b.dispatch(stopCtx, workCtx)
+			if err != nil {
+				if stopCtx.Err() == nil {
+					b.opts.Log.Error("unable to claim events", slog.Any("err", err))
+				}
+				break
+			}
+			if n < b.opts.BatchSize {
+				break
+			}
+		}
+		b.cleanup(stopCtx)
+
+		select {
+		case <-stopCtx.Done():
+			return
+		case <-b.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+type claimedEvent struct {
+	id       int64
+	attempts int
+	eventID  eventbus.EventID
+	payload  []byte
+	// claim is the token of the dispatch that claimed the event: once the
+	// lease expires and another replica claims the event, the updates of
+	// this one no longer match.
+	claim string
+}
+
+// dispatch claims a batch of events and handles them in order,
+// returning how many have been claimed.
+//
+// The lease of the events still waiting is renewed before handling
+// each one, so that the batch is not claimed again by other replicas
+// while the handlers run; once the lease of an event has been lost
+// the rest of the batch is left to them, keeping the events in order.
+func (b *EventBus) dispatch(stopCtx, workCtx context.Context) (int, error) {
+	claim := rand.Text()
+	rows, err := b.pool.Query(workCtx, `
+WITH claimed AS (
+	SELECT event FROM eventbus_deliveries
+	WHERE consumer = $1 AND status = $2 AND next_at <= now()
+	ORDER BY event
+	LIMIT $3
+	FOR UPDATE SKIP LOCKED
+)
+UPDATE eventbus_deliveries d
+SET attempts = d.attempts + 1,
+	next_at = now() + $4::bigint * interval '1 millisecond',
+	claim = $5,
+	updated_at = now()
+FROM claimed, eventbus_outbox o
+WHERE d.consumer = $1 AND d.event = claimed.event AND o.id = d.event
+RETURNING d.event, d.attempts, o.event_id, o.payload`,
+		b.opts.Consumer, deliveryPending, b.opts.BatchSize, b.opts.Lease.Milliseconds(), claim)
+	if err != nil {
+		return 0, err
+	}
+
+	batch, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (claimedEvent, error) {
+		ev := claimedEvent{claim: claim}
+		err := row.Scan(&ev.id, &ev.attempts, &ev.eventID, &ev.payload)
+		return ev, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	slices.SortFunc(batch, func(a, b claimedEvent) int {
+		return cmp.Compare(a.id, b.id)
+	})
+
+	for idx, ev := range batch {
+		if stopCtx.Err() != nil {
+			b.release(workCtx, batch[idx:])
+			break
+		}
+
+		held, err := b.renew(workCtx, ev)
+		if err != nil || !held {
+			b.opts.Log.Warn("event lease lost, leaving the batch",
+				slog.Int64("id", ev.id),
+				slog.Int("remaining", len(batch)-idx),
+				slog.Any("err", err))
+			break
+		}
+		b.deliver(workCtx, ev)
+	}
+
+	return len(batch), nil
+}
+
+// renew extends the lease of the events of the claim waiting to be
+// handled, reporting whether ev is still among them.
+func (b *EventBus) renew(ctx context.Context, ev claimedEvent) (bool, error) {
+	rows, err := b.pool.Query(ctx, `
+UPDATE eventbus_deliveries
+SET next_at = now() + $4::bigint * interval '1 millisecond', updated_at = now()
+WHERE consumer = $1 AND claim = $2 AND status = $3 AND event >= $5
+RETURNING event`,
+		b.opts.Consumer, ev.claim, deliveryPending, b.opts.Lease.Milliseconds(), ev.id)
+	if err != nil {
+		return false, err
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(ids, ev.id), nil
+}
+
+// deliver runs the local handlers, then acks the event
+// or schedules its retry.
+func (b *EventBus) deliver(ctx context.Context, ev claimedEvent) {
+	err := b.handle(ctx, ev)
+	if err == nil {
+		tag, err := b.pool.Exec(ctx, `
+UPDATE eventbus_deliveries SET status = $3, last_error = '', updated_at = now()
+WHERE consumer = $1 AND event = $2 AND claim = $4 AND status = $5`,
+			b.opts.Consumer, ev.id, deliveryAcked, ev.claim, deliveryPending)
+		switch {
+		case err != nil:
+			b.opts.Log.Error("unable to ack event", slog.Int64("id", ev.id), slog.Any("err", err))
+		case tag.RowsAffected() == 0:
+			b.opts.Log.Warn("event lease lost before the ack", slog.Int64("id", ev.id))
+		}
+		return
+	}
+
+	status, delay := deliveryPending, b.backoff(ev.attempts)
+	if ev.attempts >= b.opts.MaxAttempts {
+		status, delay = deliveryDead, 0
+	}
+
+	tag, dbErr := b.pool.Exec(ctx, `
+UPDATE eventbus_deliveries
+SET status = $3, next_at = now() + $4::bigint * interval '1 millisecond', last_error = $5, updated_at = now()
+WHERE consumer = $1 AND event = $2 AND claim = $6 AND status = $7`,
+		b.opts.Consumer, ev.id, status, delay.Milliseconds(), err.Error(), ev.claim, deliveryPending)
+	switch {
+	case dbErr != nil:
+		b.opts.Log.Error("unable to record event failure", slog.Int64("id", ev.id), slog.Any("err", dbErr))
+	case tag.RowsAffected() == 0:
+		// the replica holding the event now records its own outcome
+		b.opts.Log.Warn("event lease lost before recording the failure",
+			slog.Int64("id", ev.id), slog.Any("err", err))
+		return
+	}
+
+	if status == deliveryDead {
+		b.opts.Log.Error("event dead-lettered",
+			slog.Int64("id", ev.id),
+			slog.String("event_id", string(ev.eventID)),
+			slog.Int("attempts", ev.attempts),
+			slog.Any("err", err))
+
+		if b.opts.DeadLetterHook != nil {
+			b.opts.DeadLetterHook(DeadLetter{
+				ID:        ev.id,
+				EventID:   ev.eventID,
+				Payload:   ev.payload,
+				Attempts:  ev.attempts,
+				LastError: err.Error(),
+				FailedAt:  time.Now(),
+			})
+		}
+		return
+	}
+
+	b.opts.Log.Warn("event handling failed",
+		slog.Int64("id", ev.id),
+		slog.String("event_id", string(ev.eventID)),
+		slog.Int("attempt", ev.attempts),
+		slog.Duration("retryIn", delay),
+		slog.Any("err", err))
+}
+
+func (b *EventBus) handle(ctx context.Context, ev claimedEvent) error {
+	event, err := b.decode(ev.eventID, ev.payload)
+	if err != nil {
+		return err
+	}
+
+	// past the lease the event may be delivered to another replica
+	ctx, cancel := context.WithTimeout(ctx, b.opts.Lease)
+	defer cancel()
+
+	res := b.local.PublishSync(ctx, event)
+	switch {
+	case res.Err != nil:
+		return res.Err
+	case len(res.Errors) > 0:
+		return errors.Join(res.Errors...)
+	case res.Dropped > 0:
+		return fmt.Errorf("event dropped by %d subscriptions", res.Dropped)
+	}
+	return nil
+}
+
+func (b *EventBus) decode(eventID eventbus.EventID, payload []byte) (eventbus.Event, error) {
+	b.mu.RLock()
+	fn, ok := b.decoders[eventID]
+	b.mu.RUnlock()
+
+	if !ok {
+		return RawEvent{ID: eventID, Payload: payload}, nil
+	}
+
+	event, err := fn(payload)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode event %q: %w", eventID, err)
+	}
+	return event, nil
+}
+
+// backoff returns the delay before the retry following the attempt.
+func (b *EventBus) backoff(attempt int) time.Duration {
+	delay := b.opts.InitialBackoff
+	for i := 1; i < attempt && delay < b.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, b.opts.MaxBackoff)
+}
+
+// release hands back the claimed events not handled yet.
+func (b *EventBus) release(ctx context.Context, batch []claimedEvent) {
+	ids := make([]int64, 0, len(batch))
+	for _, ev := range batch {
+		ids = append(ids, ev.id)
+	}
+
+	_, err := b.pool.Exec(ctx, `
+UPDATE eventbus_deliveries SET attempts = attempts - 1, next_at = now(), updated_at = now()
+WHERE consumer = $1 AND event = ANY($2) AND claim = $3 AND status = $4`,
+		b.opts.Consumer, ids, batch[0].claim, deliveryPending)
+	if err != nil {
+		b.opts.Log.Error("unable to release events", slog.Any("err", err))
+	}
+}
+
+// cleanup deletes, at most once a minute, the acked deliveries and
+// the events no longer referenced past the retention.
+func (b *EventBus) cleanup(ctx context.Context) {
+	if time.Since(b.cleaned) < time.Minute {
+		return
+	}
+	b.cleaned = time.Now()
+
+	ret := b.opts.Retention.Milliseconds()
+	_, err := b.pool.Exec(ctx, `
+DELETE FROM eventbus_deliveries
+WHERE consumer = $1 AND status = $2 AND updated_at < now() - $3::bigint * interval '1 millisecond'`,
+		b.opts.Consumer, deliveryAcked, ret)
+	if err == nil {
+		_, err = b.pool.Exec(ctx, `
+DELETE FROM eventbus_outbox o
+WHERE o.created_at < now() - $1::bigint * interval '1 millisecond'
+  AND NOT EXISTS (SELECT 1 FROM eventbus_deliveries d WHERE d.event = o.id)`,
+			ret)
+	}
+	if err != nil && ctx.Err() == nil {
+		b.opts.Log.Warn("unable to clean up the eventbus tables", slog.Any("err", err))
+	}
+}
@@ -0,0 +1,390 @@
+package pgutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/krateoplatformops/plumbing/eventbus"
+)
+
+type testEvent struct {
+	Name string `json:"name"`
+}
+
+func (testEvent) EventID() eventbus.EventID {
+	return "pgutil.test.event"
+}
+
+// The EventBus tests but TestEventBusBackoff need a Postgres database
+// and are skipped unless PGUTIL_TEST_DATABASE_URL is set (see testPool).
+
+func TestEventBusBackoff(t *testing.T) {
+	b := &EventBus{opts: EventBusOptions{
+		InitialBackoff: time.Second,
+		MaxBackoff:     10 * time.Second,
+	}}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := b.backoff(i + 1); got != w {
+			t.Fatalf("attempt %d: expected %v, got %v", i+1, w, got)
+		}
+	}
+}
+
+func TestEventBusDecode(t *testing.T) {
+	b := &EventBus{decoders: map[eventbus.EventID]func([]byte) (eventbus.Event, error){}}
+
+	got, err := b.decode(testEvent{}.EventID(), []byte(`{"name":"alpha"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, ok := got.(RawEvent)
+	if !ok || raw.EventID() != (testEvent{}).EventID() || string(raw.Payload) != `{"name":"alpha"}` {
+		t.Fatalf("expected a RawEvent, got %#v", got)
+	}
+
+	RegisterEvent[testEvent](b)
+
+	got, err = b.decode(testEvent{}.EventID(), []byte(`{"name":"alpha"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != (testEvent{Name: "alpha"}) {
+		t.Fatalf("expected the registered type, got %#v", got)
+	}
+
+	if _, err := b.decode(testEvent{}.EventID(), []byte(`{`)); err == nil {
+		t.Fatal("expected a decoding error")
+	}
+}
+
+// testConsumer returns a consumer name unique to the test run.
+func testConsumer(t *testing.T, name string) string {
+	return fmt.Sprintf("%s-%s-%d", t.Name(), name, time.Now().UnixNano())
+}
+
+// testEventBus starts an EventBus on the pool with a consumer of its own,
+// unless opts.Consumer is set, unregistering it at the end of the test.
+func testEventBus(t *testing.T, pool *pgxpool.Pool, consumer string, opts EventBusOptions) *EventBus {
+	t.Helper()
+
+	if opts.Consumer == "" {
+		opts.Consumer = testConsumer(t, consumer)
+	}
+	if opts.PollInterval == 0 {
+		opts.PollInterval = 100 * time.Millisecond
+	}
+
+	b, err := NewEventBus(context.Background(), pool, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterEvent[testEvent](b)
+
+	t.Cleanup(func() {
+		if err := b.Unregister(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+	return b
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestEventBusDelivery(t *testing.T) {
+	pool := testPool(t)
+	first := testEventBus(t, pool, "first", EventBusOptions{})
+	second := testEventBus(t, pool, "second", EventBusOptions{})
+
+	var mu sync.Mutex
+	got := map[string][]string{}
+	for name, b := range map[string]*EventBus{"first": first, "second": second} {
+		eventbus.SubscribeTyped(b, testEvent{}.EventID(), func(ctx context.Context, ev testEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got[name] = append(got[name], ev.Name)
+			return nil
+		})
+	}
+
+	for _, name := range []string{"alpha", "beta"} {
+		res := first.PublishSync(context.Background(), testEvent{Name: name})
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		if res.Pending < 2 {
+			t.Fatalf("expected at least 2 pending deliveries, got %d", res.Pending)
+		}
+	}
+
+	waitFor(t, "deliveries", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got["first"]) == 2 && len(got["second"]) == 2
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	for name, events := range got {
+		if events[0] != "alpha" || events[1] != "beta" {
+			t.Fatalf("%s: unexpected events %v", name, events)
+		}
+	}
+}
+
+func TestEventBusRetryAndDeadLetter(t *testing.T) {
+	const backoff = 200 * time.Millisecond
+
+	dead := make(chan DeadLetter, 1)
+	b := testEventBus(t, testPool(t), "consumer", EventBusOptions{
+		MaxAttempts:    3,
+		InitialBackoff: backoff,
+		PollInterval:   20 * time.Millisecond,
+		DeadLetterHook: func(dl DeadLetter) { dead <- dl },
+	})
+
+	var (
+		calls atomic.Int32
+		mu    sync.Mutex
+		times []time.Time
+	)
+	b.Subscribe(testEvent{}.EventID(), func(ctx context.Context, event eventbus.Event) error {
+		calls.Add(1)
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		return errors.New("boom")
+	})
+
+	if res := b.PublishSync(context.Background(), testEvent{Name: "alpha"}); res.Err != nil {
+		t.Fatal(res.Err)
+	}
+
+	var dl DeadLetter
+	select {
+	case dl = <-dead:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the dead letter")
+	}
+	if dl.Attempts != 3 || dl.LastError != "boom" || calls.Load() != 3 {
+		t.Fatalf("unexpected dead letter %+v after %d calls", dl, calls.Load())
+	}
+
+	// the retries are delayed by the exponential backoff
+	mu.Lock()
+	for idx, want := range []time.Duration{backoff, 2 * backoff} {
+		if got := times[idx+1].Sub(times[idx]); got < want {
+			t.Errorf("retry %d after %v, expected at least %v", idx+1, got, want)
+		}
+	}
+	mu.Unlock()
+
+	list, err := b.DeadLetters(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != dl.ID || list[0].Attempts != 3 || list[0].LastError != "boom" {
+		t.Fatalf("unexpected dead letters %+v", list)
+	}
+	var payload testEvent
+	if err := json.Unmarshal(list[0].Payload, &payload); err != nil || payload.Name != "alpha" {
+		t.Fatalf("unexpected dead letter payload %s", list[0].Payload)
+	}
+
+	if err := b.Requeue(context.Background(), dl.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the requeued event", func() bool { return calls.Load() > 3 })
+
+	// dead letters are not delivered again
+	time.Sleep(4 * backoff)
+	if n := calls.Load(); n > 6 {
+		t.Fatalf("expected at most 6 calls, got %d", n)
+	}
+
+	if err := b.Requeue(context.Background(), -1); err == nil {
+		t.Fatal("expected an error requeuing an unknown event")
+	}
+}
+
+func TestEventBusRedeliversAfterLease(t *testing.T) {
+	pool := testPool(t)
+	consumer := testConsumer(t, "consumer")
+	opts := EventBusOptions{Consumer: consumer, Lease: 300 * time.Millisecond}
+
+	// the first replica "crashes" while handling the event
+	started := make(chan struct{})
+	crashed := testEventBus(t, pool, "", opts)
+	crashed.Subscribe(testEvent{}.EventID(), func(ctx context.Context, event eventbus.Event) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if res := crashed.PublishSync(context.Background(), testEvent{Name: "alpha"}); res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the first delivery")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := crashed.Close(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the handlers to be canceled, got %v", err)
+	}
+
+	// once the lease expires, another replica gets it
+	got := make(chan testEvent, 1)
+	replica := testEventBus(t, pool, "", opts)
+	eventbus.SubscribeTyped(replica, testEvent{}.EventID(), func(ctx context.Context, ev testEvent) error {
+		got <- ev
+		return nil
+	})
+
+	select {
+	case ev := <-got:
+		if ev.Name != "alpha" {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the redelivery")
+	}
+}
+
+func TestEventBusBatchKeepsLease(t *testing.T) {
+	const lease = 300 * time.Millisecond
+
+	pool := testPool(t)
+	opts := EventBusOptions{Consumer: testConsumer(t, "consumer"), Lease: lease}
+
+	var mu sync.Mutex
+	var got []string
+	handler := func(ctx context.Context, ev testEvent) error {
+		// the batch takes longer than a lease, each event less
+		time.Sleep(lease * 2 / 3)
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, ev.Name)
+		return nil
+	}
+
+	// events published before the replicas start are claimed in one batch
+	first := testEventBus(t, pool, "", opts)
+	if err := first.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"alpha", "beta", "gamma", "delta"}
+	ctx := context.Background()
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		for _, name := range want {
+			if err := first.PublishTx(ctx, tx, testEvent{Name: name}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		replica := testEventBus(t, pool, "", opts)
+		eventbus.SubscribeTyped(replica, testEvent{}.EventID(), handler)
+	}
+
+	waitFor(t, "the deliveries", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) >= len(want)
+	})
+	time.Sleep(2 * lease)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected each event once and in order, got %v", got)
+	}
+}
+
+func TestEventBusPublishTx(t *testing.T) {
+	pool := testPool(t)
+	b := testEventBus(t, pool, "consumer", EventBusOptions{})
+
+	var mu sync.Mutex
+	var got []string
+	eventbus.SubscribeTyped(b, testEvent{}.EventID(), func(ctx context.Context, ev testEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, ev.Name)
+		return nil
+	})
+
+	ctx := context.Background()
+
+	errRollback := errors.New("rollback")
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if err := b.PublishTx(ctx, tx, testEvent{Name: "discarded"}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatal(err)
+	}
+
+	err = pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		return b.PublishTx(ctx, tx, testEvent{Name: "committed"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the committed event", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) > 0
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || got[0] != "committed" {
+		t.Fatalf("unexpected events %v", got)
+	}
+}
+
+func TestEventBusClose(t *testing.T) {
+	b := testEventBus(t, testPool(t), "consumer", EventBusOptions{})
+
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	res := b.PublishSync(context.Background(), testEvent{Name: "alpha"})
+	if !errors.Is(res.Err, eventbus.ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", res.Err)
+	}
+}
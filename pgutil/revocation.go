@@ -0,0 +1,94 @@
+package pgutil
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const revocationsSchema = `
+CREATE TABLE IF NOT EXISTS jwt_revocations (
+	key        TEXT PRIMARY KEY,
+	revoked_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS jwt_revocations_expires_at_idx ON jwt_revocations (expires_at);
+`
+
+// RevocationStore keeps the revoked tokens in the "jwt_revocations"
+// table, sharing them across all the service replicas.
+// It implements jwtutil.RevocationStore.
+type RevocationStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewRevocationStore creates the revocations table if missing.
+func NewRevocationStore(ctx context.Context, pool *pgxpool.Pool) (*RevocationStore, error) {
+	if _, err := pool.Exec(ctx, revocationsSchema); err != nil {
+		return nil, fmt.Errorf("unable to create revocations table: %w", err)
+	}
+	return &RevocationStore{pool: pool}, nil
+}
+
+func (s *RevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return nil
+	}
+	return s.revoke(ctx, "jti:"+jti, expiresAt)
+}
+
+// RevokeOnce relies on the primary key to detect the concurrent
+// revocations: only one of them inserts the row.
+func (s *RevocationStore) RevokeOnce(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+
+	tag, err := s.pool.Exec(ctx, `
+INSERT INTO jwt_revocations (key, revoked_at, expires_at)
+VALUES ($1, now(), $2)
+ON CONFLICT (key) DO NOTHING`,
+		"jti:"+jti, expiresAt)
+	if err != nil {
+		return false, fmt.Errorf("unable to revoke %q: %w", "jti:"+jti, err)
+	}
+	return tag.RowsAffected() == 0, nil
+}
+
+func (s *RevocationStore) RevokeUser(ctx context.Context, username string, ttl time.Duration) error {
+	return s.revoke(ctx, "user:"+username, time.Now().Add(ttl))
+}
+
+func (s *RevocationStore) revoke(ctx context.Context, key string, expiresAt time.Time) error {
+	_, err := s.pool.Exec(ctx, `
+INSERT INTO jwt_revocations (key, revoked_at, expires_at)
+VALUES ($1, now(), $2)
+ON CONFLICT (key) DO UPDATE
+SET revoked_at = EXCLUDED.revoked_at,
+	expires_at = GREATEST(jwt_revocations.expires_at, EXCLUDED.expires_at)`,
+		key, expiresAt)
+	if err != nil {
+		return fmt.Errorf("unable to revoke %q: %w", key, err)
+	}
+
+	// Opportunistic cleanup, so that no background job is needed.
+	_, err = s.pool.Exec(ctx, `DELETE FROM jwt_revocations WHERE expires_at < now()`)
+	return err
+}
+
+func (s *RevocationStore) IsRevoked(ctx context.Context, jti, username string, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	err := s.pool.QueryRow(ctx, `
+SELECT EXISTS (
+	SELECT 1 FROM jwt_revocations
+	WHERE expires_at > now()
+	  AND ((key = $1 AND $1 <> 'jti:') OR (key = $2 AND revoked_at >= $3))
+)`,
+		"jti:"+jti, "user:"+username, issuedAt).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("unable to check revocations: %w", err)
+	}
+	return revoked, nil
+}
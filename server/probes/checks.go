@@ -0,0 +1,297 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CheckFunc reports an error when the checked dependency is not healthy.
+type CheckFunc func(context.Context) error
+
+type ChecksOptions struct {
+	// Timeout bounds every check run (default: 1s).
+	Timeout time.Duration
+	// Latch makes the checks pass forever once they all passed,
+	// as expected by startup probes.
+	Latch bool
+}
+
+// Checks is a registry of named health checks run concurrently,
+// served in the style of the kube-apiserver /readyz endpoint.
+type Checks struct {
+	opts   ChecksOptions
+	passed atomic.Bool
+
+	mu       sync.RWMutex
+	checks   []*check
+	included []*Checks
+}
+
+type check struct {
+	name     string
+	fn       CheckFunc
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu        sync.Mutex
+	running   chan struct{}
+	lastErr   error
+	lastCheck time.Time
+}
+
+type CheckOption func(*check)
+
+// WithCheckTimeout overrides the registry timeout for the check.
+func WithCheckTimeout(timeout time.Duration) CheckOption {
+	return func(c *check) {
+		if timeout > 0 {
+			c.timeout = timeout
+		}
+	}
+}
+
+// WithCheckCache reuses the check result for the specified window,
+// sparing the checked dependency from the probes traffic; the probes
+// arriving while the check runs share its result.
+func WithCheckCache(ttl time.Duration) CheckOption {
+	return func(c *check) {
+		c.cacheTTL = ttl
+	}
+}
+
+// CheckResult is the outcome of a single check.
+type CheckResult struct {
+	Name     string
+	Err      error
+	Excluded bool
+}
+
+func NewChecks(opts ChecksOptions) *Checks {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultReadyzTimeout
+	}
+	return &Checks{opts: opts}
+}
+
+// Add registers a check; a check with the same name is replaced.
+func (c *Checks) Add(name string, fn CheckFunc, opts ...CheckOption) {
+	el := &check{name: name, fn: fn, timeout: c.opts.Timeout}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(el)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx := slices.IndexFunc(c.checks, func(x *check) bool { return x.name == name })
+	if idx >= 0 {
+		c.checks[idx] = el
+		return
+	}
+	c.checks = append(c.checks, el)
+}
+
+// AddPinger registers a Pinger as a check.
+func (c *Checks) AddPinger(name string, pinger Pinger, opts ...CheckOption) {
+	c.Add(name, pinger.Ping, opts...)
+}
+
+// Include serves the checks of other registries too, after the own
+// ones; checks added to them later are served as well. The included
+// registries are never modified and must not include c.
+func (c *Checks) Include(others ...*Checks) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.included = append(c.included, others...)
+}
+
+// all returns the own checks followed by the included ones.
+func (c *Checks) all() []*check {
+	c.mu.RLock()
+	res := slices.Clone(c.checks)
+	included := slices.Clone(c.included)
+	c.mu.RUnlock()
+
+	for _, el := range included {
+		if el != nil {
+			res = append(res, el.all()...)
+		}
+	}
+	return res
+}
+
+// Run executes concurrently all the checks but the excluded ones,
+// returning the results in registration order.
+func (c *Checks) Run(ctx context.Context, exclude ...string) []CheckResult {
+	checks := c.all()
+
+	res := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, el := range checks {
+		res[i].Name = el.name
+		if slices.Contains(exclude, el.name) {
+			res[i].Excluded = true
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res[i].Err = el.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	return res
+}
+
+func (el *check) run(ctx context.Context) error {
+	if el.cacheTTL <= 0 {
+		return el.runOnce(ctx)
+	}
+
+	el.mu.Lock()
+	if time.Since(el.lastCheck) < el.cacheTTL {
+		defer el.mu.Unlock()
+		return el.lastErr
+	}
+	done := el.running
+	if done == nil {
+		done = make(chan struct{})
+		el.running = done
+		go el.refresh(context.WithoutCancel(ctx), done)
+	}
+	el.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	return el.lastErr
+}
+
+// refresh runs the check on behalf of all the probes waiting on done,
+// so that none of them can cancel it.
+func (el *check) refresh(ctx context.Context, done chan struct{}) {
+	err := el.runOnce(ctx)
+
+	el.mu.Lock()
+	el.lastErr, el.lastCheck, el.running = err, time.Now(), nil
+	el.mu.Unlock()
+	close(done)
+}
+
+func (el *check) runOnce(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, el.timeout)
+	defer cancel()
+
+	return el.safeRun(ctx)
+}
+
+func (el *check) safeRun(ctx context.Context) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("check panic: %v", rec)
+		}
+	}()
+	return el.fn(ctx)
+}
+
+// Handler serves the checks status; the name (i.e. "readyz")
+// is used in the response messages.
+//
+// The response is "ok" when all the checks pass; with the "verbose"
+// query parameter, or on failure, the status of every check is listed.
+// The "exclude" query parameter (repeatable) skips the named checks.
+// Failure reasons are logged and withheld from the response.
+func (c *Checks) Handler(name string, log *slog.Logger) http.HandlerFunc {
+	if log == nil {
+		log = slog.Default()
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		_, verbose := query["verbose"]
+		exclude := query["exclude"]
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		if c.opts.Latch && c.passed.Load() {
+			if verbose {
+				fmt.Fprintf(w, "%s check passed\n", name)
+				return
+			}
+			fmt.Fprint(w, "ok")
+			return
+		}
+
+		results := c.Run(r.Context(), exclude...)
+
+		var (
+			sb     strings.Builder
+			failed bool
+		)
+		for _, el := range results {
+			switch {
+			case el.Excluded:
+				fmt.Fprintf(&sb, "[+]%s excluded: ok\n", el.Name)
+			case el.Err != nil:
+				failed = true
+				fmt.Fprintf(&sb, "[-]%s failed: reason withheld\n", el.Name)
+				log.Error(name+": check failed",
+					slog.String("check", el.Name), slog.Any("err", el.Err))
+			default:
+				fmt.Fprintf(&sb, "[+]%s ok\n", el.Name)
+			}
+		}
+
+		unknown := slices.DeleteFunc(slices.Clone(exclude), func(x string) bool {
+			return slices.ContainsFunc(results, func(el CheckResult) bool { return el.Name == x })
+		})
+		if len(unknown) > 0 {
+			fmt.Fprintf(&sb, "warn: some health checks cannot be excluded: no matches for %s\n",
+				strings.Join(quoteAll(unknown), ","))
+		}
+
+		if failed {
+			fmt.Fprintf(&sb, "%s check failed\n", name)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, sb.String())
+			return
+		}
+
+		if c.opts.Latch && len(exclude) == 0 {
+			c.passed.Store(true)
+		}
+
+		if !verbose {
+			fmt.Fprint(w, "ok")
+			return
+		}
+		fmt.Fprintf(&sb, "%s check passed\n", name)
+		fmt.Fprint(w, sb.String())
+	}
+}
+
+func quoteAll(values []string) []string {
+	res := make([]string, len(values))
+	for i, el := range values {
+		res[i] = fmt.Sprintf("%q", el)
+	}
+	return res
+}
@@ -6,9 +6,11 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"time"
 
 	"github.com/krateoplatformops/plumbing/server/probes"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type okPinger struct{}
@@ -28,6 +30,45 @@ func ExampleRegister() {
 	// 200
 }
 
+func ExampleRegisterMetrics() {
+	requests := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "example_default_registry_total",
+		Help: "Counter registered on the default Prometheus registry.",
+	})
+	prometheus.MustRegister(requests)
+	defer prometheus.Unregister(requests)
+	requests.Inc()
+
+	mux := http.NewServeMux()
+	probes.Register(mux, slog.Default(), okPinger{}, time.Second)
+	probes.RegisterMetrics(mux)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := rr.Body.String()
+	fmt.Println(rr.Code,
+		strings.Contains(body, "go_goroutines"),
+		strings.Contains(body, "example_default_registry_total 1"))
+	// Output:
+	// 200 true true
+}
+
+func ExampleRegister_ownMetrics() {
+	mux := http.NewServeMux()
+	probes.Register(mux, slog.Default(), okPinger{}, time.Second)
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "custom")
+	})
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	fmt.Println(rr.Code, rr.Body.String())
+	// Output:
+	// 200 custom
+}
+
 func ExampleNew() {
 	hs := probes.New(slog.Default(), okPinger{}, 0)
 	hs.Start()
@@ -36,3 +77,111 @@ func ExampleNew() {
 	defer cancel()
 	_ = hs.Shutdown(ctx)
 }
+
+func ExampleChecks() {
+	checks := probes.NewChecks(probes.ChecksOptions{Timeout: 100 * time.Millisecond})
+	checks.AddPinger("postgres", okPinger{})
+	checks.Add("kube-apiserver", func(ctx context.Context) error {
+		<-ctx.Done() // never answers in time
+		return ctx.Err()
+	})
+	checks.Add("upstream", func(context.Context) error { return nil })
+
+	mux := http.NewServeMux()
+	probes.RegisterChecks(mux, slog.New(slog.DiscardHandler), checks, nil)
+
+	for _, target := range []string{
+		"/readyz?verbose",
+		"/readyz?exclude=kube-apiserver",
+		"/readyz?verbose&exclude=kube-apiserver&exclude=etcd",
+	} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		fmt.Printf("%d\n%s\n", rr.Code, strings.TrimSpace(rr.Body.String()))
+	}
+	// Output:
+	// 503
+	// [+]postgres ok
+	// [-]kube-apiserver failed: reason withheld
+	// [+]upstream ok
+	// readyz check failed
+	// 200
+	// ok
+	// 200
+	// [+]postgres ok
+	// [+]kube-apiserver excluded: ok
+	// [+]upstream ok
+	// warn: some health checks cannot be excluded: no matches for "etcd"
+	// readyz check passed
+}
+
+func ExampleWithCheckCache() {
+	calls := 0
+	checks := probes.NewChecks(probes.ChecksOptions{})
+	checks.Add("postgres", func(context.Context) error {
+		calls++
+		return nil
+	}, probes.WithCheckCache(time.Minute))
+
+	for range 3 {
+		checks.Run(context.Background())
+	}
+
+	fmt.Println(calls)
+	// Output:
+	// 1
+}
+
+func ExampleWithCheckCache_inFlight() {
+	release := make(chan struct{})
+	checks := probes.NewChecks(probes.ChecksOptions{Timeout: time.Minute})
+	checks.Add("upstream", func(ctx context.Context) error {
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, probes.WithCheckCache(time.Minute))
+
+	// the probe gives up, the check keeps running for the next ones
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	fmt.Println(checks.Run(ctx)[0].Err)
+
+	close(release)
+	fmt.Println(checks.Run(context.Background())[0].Err)
+	// Output:
+	// context deadline exceeded
+	// <nil>
+}
+
+func ExampleChecksOptions_latch() {
+	warmedUp := false
+	startup := probes.NewChecks(probes.ChecksOptions{Latch: true})
+	startup.Add("cache", func(context.Context) error {
+		if !warmedUp {
+			return fmt.Errorf("cache not warmed up yet")
+		}
+		return nil
+	})
+
+	mux := http.NewServeMux()
+	probes.RegisterChecks(mux, slog.New(slog.DiscardHandler), nil, startup)
+
+	probe := func() int {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/startupz", nil))
+		return rr.Code
+	}
+
+	fmt.Println(probe())
+	warmedUp = true
+	fmt.Println(probe())
+	warmedUp = false
+	fmt.Println(probe())
+	// Output:
+	// 503
+	// 200
+	// 200
+}
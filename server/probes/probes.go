@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"strconv"
 	"time"
+
+	"github.com/krateoplatformops/plumbing/metrics"
 )
 
 const (
@@ -55,13 +57,34 @@ func ReadyzHandler(log *slog.Logger, pinger Pinger, timeout time.Duration) http.
 	}
 }
 
-// Register mounts /livez and /readyz on the provided mux.
 func Register(mux *http.ServeMux, log *slog.Logger, pinger Pinger, timeout time.Duration) {
 	mux.HandleFunc("/livez", LivezHandler())
 	mux.HandleFunc("/readyz", ReadyzHandler(log, pinger, timeout))
 }
 
-// New builds an HTTP health server exposing /livez and /readyz.
+// RegisterMetrics mounts /metrics on the provided mux, serving the
+// metrics.Gatherer ones (see metrics.Handler).
+func RegisterMetrics(mux *http.ServeMux) {
+	mux.Handle("/metrics", metrics.Handler())
+}
+
+// RegisterChecks mounts /livez, /readyz and /startupz on the provided
+// mux, serving the readiness and startup checks registries; a nil
+// registry always passes.
+func RegisterChecks(mux *http.ServeMux, log *slog.Logger, readiness, startup *Checks) {
+	if readiness == nil {
+		readiness = NewChecks(ChecksOptions{})
+	}
+	if startup == nil {
+		startup = NewChecks(ChecksOptions{Latch: true})
+	}
+
+	mux.HandleFunc("/livez", LivezHandler())
+	mux.HandleFunc("/readyz", readiness.Handler("readyz", log))
+	mux.HandleFunc("/startupz", startup.Handler("startupz", log))
+}
+
+// New builds an HTTP health server exposing /livez, /readyz and /metrics.
 //
 // The readiness probe requires that the Pinger does not return error.
 // Liveness always returns 200.
@@ -72,6 +95,7 @@ func New(log *slog.Logger, pinger Pinger, port int) *HealthServer {
 
 	mux := http.NewServeMux()
 	Register(mux, log, pinger, defaultReadyzTimeout)
+	RegisterMetrics(mux)
 
 	srv := &http.Server{
 		Addr:         ":" + strconv.Itoa(port),
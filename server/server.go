@@ -0,0 +1,244 @@
+// Package server runs an HTTP service together with its health server,
+// taking care of the graceful shutdown on SIGINT and SIGTERM.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/env"
+	"github.com/krateoplatformops/plumbing/server/probes"
+)
+
+// ErrDraining is returned by the "shutdown" readiness check.
+var ErrDraining = errors.New("server is shutting down")
+
+type Options struct {
+	// Addr is the address of the main server (default: ":8080").
+	Addr string
+	// Handler serves the main server requests.
+	Handler http.Handler
+	// HealthAddr is the address of the server exposing /livez, /readyz,
+	// /startupz and /metrics; when empty no health server is started.
+	HealthAddr string
+	// Pinger, when set, is added as the "ping" readiness check.
+	Pinger probes.Pinger
+	// Readiness holds the checks served by /readyz, after the "ping"
+	// check and the "shutdown" check of the Runner, failing once the
+	// shutdown has begun. The registry is not modified by the Runner.
+	Readiness *probes.Checks
+	// Startup holds the checks served by /startupz.
+	Startup *probes.Checks
+	// DrainPeriod is how long /readyz fails before the main server is
+	// shut down, giving the load balancers the time to stop sending
+	// new requests (default: SERVER_DRAIN_PERIOD or 5s).
+	//
+	// A second signal cuts the drain short, as does the cancellation of
+	// the Run context when the shutdown was started by a signal.
+	DrainPeriod time.Duration
+	// ShutdownTimeout bounds the wait for the in-flight requests
+	// and the cleanup hooks (default: SERVER_SHUTDOWN_TIMEOUT or 25s).
+	ShutdownTimeout time.Duration
+	// Log is the logger used to report the server lifecycle.
+	Log *slog.Logger
+}
+
+// Runner runs the main and the health servers until the context is done
+// or a termination signal is received, then shuts them down gracefully:
+//
+//  1. /readyz starts failing;
+//  2. after DrainPeriod the main server stops accepting connections
+//     and waits for the in-flight requests;
+//  3. the cleanup hooks run, in reverse order of registration;
+//  4. the health server is stopped.
+type Runner struct {
+	opts      Options
+	readiness *probes.Checks
+	draining  atomic.Bool
+
+	mu    sync.Mutex
+	hooks []cleanupHook
+}
+
+type cleanupHook struct {
+	name string
+	fn   func(context.Context) error
+}
+
+func New(opts Options) *Runner {
+	if len(opts.Addr) == 0 {
+		opts.Addr = ":8080"
+	}
+	if opts.DrainPeriod <= 0 {
+		opts.DrainPeriod = env.Duration("SERVER_DRAIN_PERIOD", 5*time.Second)
+	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = env.Duration("SERVER_SHUTDOWN_TIMEOUT", 25*time.Second)
+	}
+	if opts.Log == nil {
+		opts.Log = slog.Default()
+	}
+
+	r := &Runner{
+		opts:      opts,
+		readiness: probes.NewChecks(probes.ChecksOptions{}),
+	}
+	if opts.Pinger != nil {
+		r.readiness.AddPinger("ping", opts.Pinger)
+	}
+	r.readiness.Add("shutdown", func(context.Context) error {
+		if r.draining.Load() {
+			return ErrDraining
+		}
+		return nil
+	})
+	r.readiness.Include(opts.Readiness)
+	return r
+}
+
+// Run is a shortcut for New(opts).Run(ctx).
+func Run(ctx context.Context, opts Options) error {
+	return New(opts).Run(ctx)
+}
+
+// OnShutdown registers a cleanup hook (i.e. to close caches, event buses
+// or database pools) run once the main server has been shut down.
+func (r *Runner) OnShutdown(name string, fn func(context.Context) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, cleanupHook{name: name, fn: fn})
+}
+
+// Run serves until ctx is done or SIGINT/SIGTERM is received, then shuts
+// down gracefully; it returns nil unless a server or a hook failed.
+func (r *Runner) Run(ctx context.Context) error {
+	if r.opts.Handler == nil {
+		return fmt.Errorf("server: handler is required")
+	}
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	log := r.opts.Log
+
+	main := &http.Server{
+		Handler:           r.opts.Handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+	mainLis, err := net.Listen("tcp", r.opts.Addr)
+	if err != nil {
+		return fmt.Errorf("server: unable to listen on %s: %w", r.opts.Addr, err)
+	}
+
+	var (
+		health    *http.Server
+		healthLis net.Listener
+	)
+	if len(r.opts.HealthAddr) > 0 {
+		mux := http.NewServeMux()
+		probes.RegisterChecks(mux, log, r.readiness, r.opts.Startup)
+		probes.RegisterMetrics(mux)
+
+		health = &http.Server{
+			Handler:      mux,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 25 * time.Second,
+			IdleTimeout:  15 * time.Second,
+		}
+		healthLis, err = net.Listen("tcp", r.opts.HealthAddr)
+		if err != nil {
+			mainLis.Close()
+			return fmt.Errorf("server: unable to listen on %s: %w", r.opts.HealthAddr, err)
+		}
+	}
+
+	serveErr := make(chan error, 2)
+	serve := func(srv *http.Server, lis net.Listener, name string) {
+		log.Info("server started", slog.String("name", name), slog.String("addr", lis.Addr().String()))
+		if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- fmt.Errorf("server: %s: %w", name, err)
+		}
+	}
+
+	go serve(main, mainLis, "main")
+	if health != nil {
+		go serve(health, healthLis, "health")
+	}
+
+	var (
+		errs []error
+		// interrupt cuts the drain short; when the shutdown is started
+		// by the context it stays nil, so only a signal interrupts.
+		interrupt <-chan struct{}
+	)
+	select {
+	case <-ctx.Done():
+		log.Info("shutdown requested")
+	case sig := <-sigs:
+		log.Info("shutdown requested", slog.String("signal", sig.String()))
+		interrupt = ctx.Done()
+	case err := <-serveErr:
+		log.Error("server failed, shutting down", slog.Any("err", err))
+		errs = append(errs, err)
+	}
+
+	r.draining.Store(true)
+	if len(errs) == 0 {
+		log.Info("draining", slog.Duration("period", r.opts.DrainPeriod))
+		r.drain(sigs, interrupt)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), r.opts.ShutdownTimeout)
+	defer cancel()
+
+	if err := main.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("server: main shutdown: %w", err))
+	}
+
+	r.mu.Lock()
+	hooks := append([]cleanupHook(nil), r.hooks...)
+	r.mu.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].fn(shutdownCtx); err != nil {
+			log.Error("cleanup hook failed", slog.String("name", hooks[i].name), slog.Any("err", err))
+			errs = append(errs, fmt.Errorf("server: cleanup %s: %w", hooks[i].name, err))
+		}
+	}
+
+	if health != nil {
+		if err := health.Shutdown(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("server: health shutdown: %w", err))
+		}
+	}
+
+	log.Info("server stopped")
+	return errors.Join(errs...)
+}
+
+// drain waits for DrainPeriod, or until a signal is received
+// or interrupt is closed.
+func (r *Runner) drain(sigs <-chan os.Signal, interrupt <-chan struct{}) {
+	timer := time.NewTimer(r.opts.DrainPeriod)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case sig := <-sigs:
+		r.opts.Log.Warn("drain interrupted", slog.String("signal", sig.String()))
+	case <-interrupt:
+		r.opts.Log.Warn("drain interrupted", slog.String("reason", "context done"))
+	}
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/server/probes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+func waitFor(t *testing.T, url string) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("server at %s not started", url)
+}
+
+func TestRunGracefulShutdown(t *testing.T) {
+	mainAddr, healthAddr := freeAddr(t), freeAddr(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fast"))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("slow"))
+	})
+
+	r := New(Options{
+		Addr:            mainAddr,
+		Handler:         mux,
+		HealthAddr:      healthAddr,
+		DrainPeriod:     200 * time.Millisecond,
+		ShutdownTimeout: 2 * time.Second,
+		Log:             slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	hook := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return nil
+		}
+	}
+	r.OnShutdown("pgpool", hook("pgpool"))
+	r.OnShutdown("cache", hook("cache"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+
+	waitFor(t, "http://"+mainAddr+"/fast")
+	waitFor(t, "http://"+healthAddr+"/livez")
+
+	resp, err := http.Get("http://" + healthAddr + "/readyz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + mainAddr + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slow <- string(body)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	// while draining readiness fails but requests are still served
+	resp, err = http.Get("http://" + healthAddr + "/readyz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	resp, err = http.Get("http://" + mainAddr + "/fast")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	assert.Equal(t, "slow", <-slow)
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []string{"cache", "pgpool"}, order)
+
+	_, err = http.Get("http://" + mainAddr + "/fast")
+	assert.Error(t, err)
+}
+
+func TestRunKeepsCallerReadiness(t *testing.T) {
+	healthAddr := freeAddr(t)
+
+	readiness := probes.NewChecks(probes.ChecksOptions{})
+	readiness.Add("db", func(context.Context) error { return nil })
+
+	r := New(Options{
+		Addr:        freeAddr(t),
+		Handler:     http.NotFoundHandler(),
+		HealthAddr:  healthAddr,
+		Readiness:   readiness,
+		DrainPeriod: 200 * time.Millisecond,
+		Log:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+
+	names := func(res []probes.CheckResult) []string {
+		var out []string
+		for _, el := range res {
+			out = append(out, el.Name)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"db"}, names(readiness.Run(context.Background())))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+
+	waitFor(t, "http://"+healthAddr+"/livez")
+
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	resp, err := http.Get("http://" + healthAddr + "/readyz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"db"}, names(readiness.Run(context.Background())))
+}
+
+func TestRunDrainInterrupted(t *testing.T) {
+	tests := []struct {
+		name      string
+		interrupt func(cancel context.CancelFunc)
+	}{
+		{
+			name: "second signal",
+			interrupt: func(context.CancelFunc) {
+				syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+			},
+		},
+		{
+			name: "context done",
+			interrupt: func(cancel context.CancelFunc) {
+				cancel()
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mainAddr := freeAddr(t)
+
+			r := New(Options{
+				Addr:        mainAddr,
+				Handler:     http.NotFoundHandler(),
+				DrainPeriod: time.Minute,
+				Log:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			done := make(chan error, 1)
+			go func() { done <- r.Run(ctx) }()
+
+			waitFor(t, "http://"+mainAddr+"/")
+
+			require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+			time.Sleep(50 * time.Millisecond)
+			tc.interrupt(cancel)
+
+			select {
+			case err := <-done:
+				assert.NoError(t, err)
+			case <-time.After(2 * time.Second):
+				t.Fatal("drain not interrupted")
+			}
+		})
+	}
+}
+
+func TestRunHookError(t *testing.T) {
+	r := New(Options{
+		Addr:        freeAddr(t),
+		Handler:     http.NotFoundHandler(),
+		DrainPeriod: time.Millisecond,
+		Log:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+
+	boom := errors.New("boom")
+	r.OnShutdown("eventbus", func(context.Context) error { return boom })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := r.Run(ctx)
+	assert.ErrorIs(t, err, boom)
+}
+
+func TestRunListenError(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	err = Run(context.Background(), Options{
+		Addr:    lis.Addr().String(),
+		Handler: http.NotFoundHandler(),
+	})
+	assert.Error(t, err)
+}
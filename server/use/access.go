@@ -1,30 +1,267 @@
 package use
 
 import (
+	"bufio"
+	"fmt"
 	"log/slog"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"net/netip"
+	"strings"
 	"time"
+
+	xcontext "github.com/krateoplatformops/plumbing/context"
+	"github.com/krateoplatformops/plumbing/jwtutil"
 )
 
-func Access(l *slog.Logger) func(http.Handler) http.Handler {
+type accessOptions struct {
+	trusted []netip.Prefix
+	levels  [6]slog.Level
+	samples [6]float64
+}
+
+type AccessOption func(*accessOptions)
+
+// WithTrustedProxies sets the proxies allowed to report the client
+// address through the X-Forwarded-For header (see ParseTrustedProxies).
+func WithTrustedProxies(prefixes ...netip.Prefix) AccessOption {
+	return func(opts *accessOptions) {
+		opts.trusted = append(opts.trusted, prefixes...)
+	}
+}
+
+// WithStatusLevel sets the log level of the responses in the specified
+// status class (i.e. 5 for 5xx responses); the default is slog.LevelInfo.
+func WithStatusLevel(class int, level slog.Level) AccessOption {
+	return func(opts *accessOptions) {
+		if class >= 1 && class <= 5 {
+			opts.levels[class] = level
+		}
+	}
+}
+
+// WithStatusSampling logs only the specified fraction (0 to 1) of the
+// responses in the status class (i.e. 2 for 2xx responses);
+// by default every response is logged.
+func WithStatusSampling(class int, rate float64) AccessOption {
+	return func(opts *accessOptions) {
+		if class >= 1 && class <= 5 {
+			opts.samples[class] = min(max(rate, 0), 1)
+		}
+	}
+}
+
+// Access logs every request once served, including the response status,
+// the bytes written, the trace id and the user.
+//
+// The client address is the remote address of the connection; the
+// X-Forwarded-For header is honoured only when the request comes from
+// one of the trusted proxies (see WithTrustedProxies).
+func Access(l *slog.Logger, opts ...AccessOption) func(http.Handler) http.Handler {
+	cfg := accessOptions{}
+	for i := range cfg.samples {
+		cfg.samples[i] = 1
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 
-			ip := r.RemoteAddr
-			if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-				ip = forwarded
-			}
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
 
-			next.ServeHTTP(w, r)
+			class := min(max(sw.Status()/100, 1), 5)
+			if rate := cfg.samples[class]; rate < 1 && rand.Float64() >= rate {
+				return
+			}
 
-			l.Info("http request",
-				slog.String("ip", ip),
+			attrs := []slog.Attr{
+				slog.String("ip", ClientIP(r, cfg.trusted)),
 				slog.String("method", r.Method),
 				slog.String("url", r.URL.String()),
 				slog.String("user_agent", r.UserAgent()),
+				slog.Int("status", sw.Status()),
+				slog.Int64("size", sw.Size()),
 				slog.String("latency", time.Since(start).String()),
-			)
+			}
+			if traceId := accessTraceId(r); len(traceId) > 0 {
+				attrs = append(attrs, slog.String("traceId", traceId))
+			}
+			if user := accessUser(r); len(user) > 0 {
+				attrs = append(attrs, slog.String("user", user))
+			}
+
+			l.LogAttrs(r.Context(), cfg.levels[class], "http request", attrs...)
 		})
 	}
 }
+
+// ParseTrustedProxies parses a list of CIDRs or single addresses.
+func ParseTrustedProxies(values ...string) ([]netip.Prefix, error) {
+	res := make([]netip.Prefix, 0, len(values))
+	for _, el := range values {
+		el = strings.TrimSpace(el)
+		if len(el) == 0 {
+			continue
+		}
+
+		if !strings.Contains(el, "/") {
+			addr, err := netip.ParseAddr(el)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", el, err)
+			}
+			res = append(res, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		pfx, err := netip.ParsePrefix(el)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", el, err)
+		}
+		res = append(res, pfx.Masked())
+	}
+	return res, nil
+}
+
+// ClientIP returns the address of the client that sent the request.
+//
+// When the connection comes from a trusted proxy, the X-Forwarded-For
+// entries are walked right to left and the first untrusted one is
+// returned; forged entries prepended by the client are thus ignored.
+func ClientIP(r *http.Request, trusted []netip.Prefix) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+
+	if !isTrustedProxy(remote, trusted) {
+		return remote
+	}
+
+	hops := []string{}
+	for _, el := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(el, ",") {
+			if hop = strings.TrimSpace(hop); len(hop) > 0 {
+				hops = append(hops, hop)
+			}
+		}
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !isTrustedProxy(hops[i], trusted) {
+			return hops[i]
+		}
+	}
+	if len(hops) > 0 {
+		return hops[0]
+	}
+
+	return remote
+}
+
+func isTrustedProxy(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, pfx := range trusted {
+		if pfx.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// accessTraceId looks for the trace id in the request context
+// and then in the header set by the TraceId middleware.
+func accessTraceId(r *http.Request) string {
+	if traceId := xcontext.TraceId(r.Context(), false); len(traceId) > 0 {
+		return traceId
+	}
+	return r.Header.Get(xcontext.LabelKrateoTraceId)
+}
+
+// accessUser looks for the user in the request context (see UserConfig)
+// and then in the unverified bearer token.
+func accessUser(r *http.Request) string {
+	if ui, err := xcontext.UserInfo(r.Context()); err == nil {
+		return ui.Username
+	}
+
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return ""
+	}
+
+	ui, err := jwtutil.ExtractUserInfo(parts[1])
+	if err != nil {
+		return ""
+	}
+	return ui.Username
+}
+
+// statusWriter records the response status code and size,
+// preserving the http.Flusher and http.Hijacker support
+// of the underlying writer (i.e. for server-sent events).
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Status returns the response status code (200 if never set).
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Size returns the number of body bytes written.
+func (w *statusWriter) Size() int64 {
+	return w.size
+}
+
+func (w *statusWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
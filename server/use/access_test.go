@@ -1,12 +1,21 @@
 package use
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"os"
 	"testing"
 	"time"
+
+	xcontext "github.com/krateoplatformops/plumbing/context"
+	"github.com/krateoplatformops/plumbing/jwtutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAccessLog(t *testing.T) {
@@ -69,3 +78,122 @@ func TestAccessLog(t *testing.T) {
 		t.Errorf("expected 200 OK for SSE, got %d", resp2.StatusCode)
 	}
 }
+
+func TestAccessLogFields(t *testing.T) {
+	buf := bytes.Buffer{}
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	handler := NewChain(Access(log), TraceId()).
+		ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("created"))
+		})
+
+	token, err := jwtutil.CreateToken(jwtutil.CreateTokenOptions{
+		Username:   "cyberjoker",
+		Groups:     []string{"devs"},
+		Duration:   time.Minute,
+		SigningKey: "secret",
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/items", nil)
+	req.Header.Set(xcontext.LabelKrateoTraceId, "abc123")
+	req.Header.Set("Authorization", "Bearer "+token)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var rec map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+
+	assert.Equal(t, "INFO", rec["level"])
+	assert.Equal(t, float64(http.StatusCreated), rec["status"])
+	assert.Equal(t, float64(len("created")), rec["size"])
+	assert.Equal(t, "abc123", rec["traceId"])
+	assert.Equal(t, "cyberjoker", rec["user"])
+	assert.Equal(t, "192.0.2.1", rec["ip"])
+}
+
+func TestAccessLogLevelsAndSampling(t *testing.T) {
+	buf := bytes.Buffer{}
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	handler := Access(log,
+		WithStatusLevel(5, slog.LevelError),
+		WithStatusSampling(2, 0),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+	assert.Zero(t, buf.Len(), "2xx responses should be sampled out")
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	var rec map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+	assert.Equal(t, "ERROR", rec["level"])
+	assert.Equal(t, float64(http.StatusInternalServerError), rec["status"])
+}
+
+func TestAccessLogPreservesHijacker(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	handler := Access(log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		rw.Flush()
+	}))
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "hijacked", string(body))
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8", "192.168.1.1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		trusted   []netip.Prefix
+		want      string
+	}{
+		{"no proxies", "203.0.113.7:1234", "198.51.100.1", nil, "203.0.113.7"},
+		{"untrusted remote", "203.0.113.7:1234", "198.51.100.1", trusted, "203.0.113.7"},
+		{"trusted remote", "10.1.2.3:1234", "198.51.100.1", trusted, "198.51.100.1"},
+		{"forged entries", "10.1.2.3:1234", "1.1.1.1, 198.51.100.1, 192.168.1.1", trusted, "198.51.100.1"},
+		{"all trusted", "10.1.2.3:1234", "10.9.9.9", trusted, "10.9.9.9"},
+		{"no header", "10.1.2.3:1234", "", trusted, "10.1.2.3"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remote
+			if len(tc.forwarded) > 0 {
+				req.Header.Set("X-Forwarded-For", tc.forwarded)
+			}
+			assert.Equal(t, tc.want, ClientIP(req, tc.trusted))
+		})
+	}
+
+	_, err = ParseTrustedProxies("not-a-cidr")
+	assert.Error(t, err)
+}
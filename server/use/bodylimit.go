@@ -0,0 +1,42 @@
+package use
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/krateoplatformops/plumbing/http/response"
+)
+
+// MaxBodySize limits the request body to the specified number of bytes.
+//
+// Only requests declaring a larger Content-Length are rejected here,
+// with a RequestEntityTooLarge Status. Bodies of unknown length (i.e.
+// chunked transfer encoding) are passed through: reading them past the
+// limit fails with an *http.MaxBytesError, and it is up to the handlers
+// to check the read error with IsBodyTooLarge and to reply with
+// response.RequestEntityTooLarge, otherwise the client won't get a 413.
+func MaxBodySize(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(wri http.ResponseWriter, req *http.Request) {
+			if req.ContentLength > limit {
+				response.RequestEntityTooLarge(wri,
+					fmt.Errorf("request body too large: limit is %d bytes", limit))
+				return
+			}
+
+			req.Body = http.MaxBytesReader(wri, req.Body, limit)
+			next.ServeHTTP(wri, req)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// IsBodyTooLarge reports whether err has been raised reading
+// a request body bigger than the MaxBodySize limit; handlers
+// should reply with response.RequestEntityTooLarge.
+func IsBodyTooLarge(err error) bool {
+	var mbe *http.MaxBytesError
+	return errors.As(err, &mbe)
+}
@@ -0,0 +1,58 @@
+package use
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/krateoplatformops/plumbing/http/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	route := MaxBodySize(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			if IsBodyTooLarge(err) {
+				response.RequestEntityTooLarge(w, err)
+				return
+			}
+			response.BadRequest(w, err)
+			return
+		}
+		w.Write(data)
+	}))
+
+	t.Run("within limit", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		route.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("small")))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "small", rec.Body.String())
+	})
+
+	t.Run("declared length over limit", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		route.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("definitely too large")))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+		var status response.Status
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		assert.Equal(t, response.StatusReasonRequestEntityTooLarge, status.Reason)
+	})
+
+	t.Run("unknown length over limit", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader("definitely too large")))
+		req.ContentLength = -1
+
+		rec := httptest.NewRecorder()
+		route.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}
@@ -42,10 +42,16 @@ func NewChain(constructors ...func(http.Handler) http.Handler) Chain {
 // For proper middleware, this should cause no problems.
 //
 // Then() treats nil as http.DefaultServeMux.
+//
+// When there is any middleware, the handler is wrapped by RecordRoute,
+// so that TraceId and Metrics see the route matched by a mux.
 func (c Chain) Then(h http.Handler) http.Handler {
 	if h == nil {
 		h = http.DefaultServeMux
 	}
+	if len(c.constructors) > 0 {
+		h = RecordRoute(h)
+	}
 
 	for i := range c.constructors {
 		h = c.constructors[len(c.constructors)-1-i](h)
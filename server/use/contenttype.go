@@ -0,0 +1,58 @@
+package use
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/krateoplatformops/plumbing/http/response"
+)
+
+// AllowContentTypes rejects with an UnsupportedMediaType Status the
+// requests carrying a body whose Content-Type is not in the list.
+//
+// Entries are matched ignoring parameters (i.e. charset) and may use
+// a wildcard subtype (i.e. "text/*"). Requests without body pass through.
+func AllowContentTypes(types ...string) func(http.Handler) http.Handler {
+	allowed := make([]string, 0, len(types))
+	for _, el := range types {
+		allowed = append(allowed, strings.ToLower(strings.TrimSpace(el)))
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(wri http.ResponseWriter, req *http.Request) {
+			if req.ContentLength == 0 && len(req.TransferEncoding) == 0 {
+				next.ServeHTTP(wri, req)
+				return
+			}
+
+			ct := req.Header.Get("Content-Type")
+			mediaType, _, err := mime.ParseMediaType(ct)
+			if err != nil || !contentTypeAllowed(mediaType, allowed) {
+				response.UnsupportedMediaType(wri,
+					fmt.Errorf("unsupported content type %q, expected one of: %s",
+						ct, strings.Join(allowed, ", ")))
+				return
+			}
+
+			next.ServeHTTP(wri, req)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func contentTypeAllowed(mediaType string, allowed []string) bool {
+	for _, el := range allowed {
+		if el == mediaType {
+			return true
+		}
+
+		prefix, ok := strings.CutSuffix(el, "/*")
+		if ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
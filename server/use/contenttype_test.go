@@ -0,0 +1,54 @@
+package use
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/krateoplatformops/plumbing/http/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowContentTypes(t *testing.T) {
+	route := AllowContentTypes("application/json", "text/*")(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		want        int
+	}{
+		{"json", `{}`, "application/json", http.StatusNoContent},
+		{"json with charset", `{}`, "Application/JSON; charset=utf-8", http.StatusNoContent},
+		{"wildcard", "hello", "text/plain", http.StatusNoContent},
+		{"no body", "", "", http.StatusNoContent},
+		{"not allowed", "<a/>", "application/xml", http.StatusUnsupportedMediaType},
+		{"missing", `{}`, "", http.StatusUnsupportedMediaType},
+		{"malformed", `{}`, "application/", http.StatusUnsupportedMediaType},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			if len(tc.contentType) > 0 {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			rec := httptest.NewRecorder()
+			route.ServeHTTP(rec, req)
+			assert.Equal(t, tc.want, rec.Code)
+
+			if tc.want == http.StatusUnsupportedMediaType {
+				var status response.Status
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+				assert.Equal(t, response.StatusReasonUnsupportedMediaType, status.Reason)
+			}
+		})
+	}
+}
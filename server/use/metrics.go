@@ -0,0 +1,69 @@
+package use
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unmatchedRoute labels the requests not served through a ServeMux pattern,
+// so that raw paths never end up in the metric labels.
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_server_requests_total",
+		Help: "Total number of HTTP requests served.",
+	}, []string{"route", "method", "code"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_server_request_duration_seconds",
+		Help:    "Duration of the HTTP requests.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	httpRequestsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_server_requests_in_flight",
+		Help: "Number of HTTP requests currently being served.",
+	}, []string{"method"})
+)
+
+func init() {
+	metrics.MustRegister(httpRequestsTotal, httpRequestDuration, httpRequestsInFlight)
+}
+
+// Metrics records the request count, latency and in-flight requests.
+//
+// Requests are labelled by the http.ServeMux route pattern (i.e. "GET /items/{id}"),
+// which is available both when the middleware wraps the single route
+// handlers and when it wraps the mux, at any position in a Chain
+// (see RecordRoute).
+func Metrics() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			inFlight := httpRequestsInFlight.WithLabelValues(r.Method)
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			sw := &statusWriter{ResponseWriter: w}
+			r, holder := withRoute(r)
+			next.ServeHTTP(sw, r)
+			setRoute(r)
+
+			route := holder.pattern
+			if len(route) == 0 {
+				route = unmatchedRoute
+			}
+			code := strconv.Itoa(sw.Status())
+
+			httpRequestsTotal.WithLabelValues(route, r.Method, code).Inc()
+			httpRequestDuration.WithLabelValues(route, r.Method, code).
+				Observe(time.Since(start).Seconds())
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package use
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/krateoplatformops/plumbing/metrics"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code 200, got %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics-test/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	route := NewChain(Metrics()).Then(mux)
+
+	for _, path := range []string{"/metrics-test/1", "/metrics-test/2", "/metrics-test/missing", "/nowhere"} {
+		route.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	out := scrape(t)
+
+	for _, want := range []string{
+		`http_server_requests_total{code="200",method="GET",route="GET /metrics-test/{id}"} 2`,
+		`http_server_requests_total{code="404",method="GET",route="GET /metrics-test/{id}"} 1`,
+		`http_server_requests_total{code="404",method="GET",route="unmatched"}`,
+		`http_server_request_duration_seconds_count{code="200",method="GET",route="GET /metrics-test/{id}"} 2`,
+		`http_server_requests_in_flight{method="GET"} 0`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected metric %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestMetricsWithTraceId(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics-chain/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	for _, chain := range []Chain{
+		NewChain(Metrics(), TraceId(), Logger(slog.New(slog.NewTextHandler(io.Discard, nil)))),
+		NewChain(TraceId(), Metrics(), Logger(slog.New(slog.NewTextHandler(io.Discard, nil)))),
+	} {
+		chain.Then(mux).ServeHTTP(httptest.NewRecorder(),
+			httptest.NewRequest(http.MethodGet, "/metrics-chain/1", nil))
+	}
+
+	want := `http_server_requests_total{code="200",method="GET",route="GET /metrics-chain/{id}"} 2`
+	if out := scrape(t); !strings.Contains(out, want) {
+		t.Errorf("expected metric %q in:\n%s", want, out)
+	}
+}
@@ -0,0 +1,306 @@
+package use
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	xcontext "github.com/krateoplatformops/plumbing/context"
+	"github.com/krateoplatformops/plumbing/endpoints"
+	"github.com/krateoplatformops/plumbing/http/response"
+	"github.com/krateoplatformops/plumbing/jwtutil"
+)
+
+// oidcDiscoveryRetryInterval is the minimum time between two
+// discoveries after a failed one.
+const oidcDiscoveryRetryInterval = time.Minute
+
+type oidcOptions struct {
+	usernameClaim string
+	groupsClaim   string
+	client        *http.Client
+	source        endpoints.Source
+}
+
+type OIDCOption func(*oidcOptions)
+
+// WithUsernameClaim sets the claim holding the username (default: "sub").
+// Nested claims are addressed with dots (i.e. "user.name"); when set to
+// "email", the "email_verified" claim must not be false.
+func WithUsernameClaim(claim string) OIDCOption {
+	return func(opts *oidcOptions) {
+		opts.usernameClaim = claim
+	}
+}
+
+// WithGroupsClaim sets the claim holding the groups, either a list or
+// a single string (default: "groups"); nested claims are addressed
+// with dots (i.e. "realm_access.roles").
+func WithGroupsClaim(claim string) OIDCOption {
+	return func(opts *oidcOptions) {
+		opts.groupsClaim = claim
+	}
+}
+
+// WithDiscoveryClient sets the client used to fetch
+// the discovery document and the issuer keys.
+func WithDiscoveryClient(cli *http.Client) OIDCOption {
+	return func(opts *oidcOptions) {
+		opts.client = cli
+	}
+}
+
+// WithOIDCEndpointSource sets the backend used to look up the user
+// endpoints (see WithEndpointSource, also about the RBAC needed by
+// the default one).
+func WithOIDCEndpointSource(src endpoints.Source) OIDCOption {
+	return func(opts *oidcOptions) {
+		opts.source = src
+	}
+}
+
+// OIDC validates the ID tokens issued by an external OpenID Connect
+// provider for the specified client id, populating the request context
+// like UserConfig does.
+//
+// The issuer keys are found through its discovery document, fetched
+// on the first request; the token signature, issuer, audience and
+// expiration are verified. Tokens without a "kid" header are verified
+// trying every issuer key able to verify their algorithm.
+func OIDC(issuerURL, clientID, authnNS string, opts ...OIDCOption) func(http.Handler) http.Handler {
+	cfg := oidcOptions{
+		usernameClaim: "sub",
+		groupsClaim:   "groups",
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	if cfg.source == nil {
+		cfg.source = &inClusterSource{namespace: authnNS}
+	}
+
+	verifier := &oidcVerifier{
+		issuer:   strings.TrimSuffix(issuerURL, "/"),
+		clientID: clientID,
+		opts:     cfg,
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(wri http.ResponseWriter, req *http.Request) {
+			authHeader := req.Header.Get("Authorization")
+			if authHeader == "" {
+				response.Unauthorized(wri, fmt.Errorf("missing authorization header"))
+				return
+			}
+
+			parts := strings.SplitN(authHeader, " ", 2)
+			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+				response.Unauthorized(wri, fmt.Errorf("invalid authorization header format"))
+				return
+			}
+
+			keys, err := verifier.keySet(req.Context())
+			if err != nil {
+				response.ServiceUnavailable(wri, err)
+				return
+			}
+
+			userInfo, err := verifier.verify(req.Context(), keys, parts[1])
+			if err != nil {
+				response.Unauthorized(wri, err)
+				return
+			}
+
+			ep, ok := userEndpoint(wri, cfg.source, userInfo.Username)
+			if !ok {
+				return
+			}
+
+			ctx := xcontext.BuildContext(req.Context(),
+				xcontext.WithAccessToken(parts[1]),
+				xcontext.WithUserInfo(userInfo),
+				xcontext.WithUserConfig(ep),
+			)
+
+			next.ServeHTTP(wri, req.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+type oidcVerifier struct {
+	issuer   string
+	clientID string
+	opts     oidcOptions
+
+	mu   sync.Mutex
+	keys *jwtutil.RemoteKeySet
+	// tokenIssuer is the issuer as advertised by the discovery
+	// document, which the "iss" claim must match exactly.
+	tokenIssuer string
+	lastErr     error
+	attemptAt   time.Time
+	// discovering is closed when the discovery in progress completes.
+	discovering chan struct{}
+}
+
+// keySet runs the issuer discovery once; a failed discovery is retried
+// at most once every oidcDiscoveryRetryInterval, failing the requests
+// in between with its error.
+//
+// Like the JWKS fetches, the discovery runs in the background bounded
+// by the client timeout rather than by the caller context: callers only
+// wait for it as long as their own context allows.
+func (v *oidcVerifier) keySet(ctx context.Context) (*jwtutil.RemoteKeySet, error) {
+	v.mu.Lock()
+	if v.keys != nil {
+		defer v.mu.Unlock()
+		return v.keys, nil
+	}
+	done := v.discovering
+	if done == nil {
+		if v.lastErr != nil && time.Since(v.attemptAt) < oidcDiscoveryRetryInterval {
+			defer v.mu.Unlock()
+			return nil, v.lastErr
+		}
+		v.attemptAt = time.Now()
+		done = make(chan struct{})
+		v.discovering = done
+		go v.discover(context.WithoutCancel(ctx), done)
+	}
+	v.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.keys == nil {
+		return nil, v.lastErr
+	}
+	return v.keys, nil
+}
+
+// discover sets the key set and the token issuer, or the error,
+// and closes done.
+func (v *oidcVerifier) discover(ctx context.Context, done chan struct{}) {
+	timeout := v.opts.client.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	issuer, jwksURI, err := v.fetchDiscovery(ctx)
+
+	v.mu.Lock()
+	if err == nil {
+		v.tokenIssuer = issuer
+		v.keys = jwtutil.NewRemoteKeySet(jwksURI, jwtutil.RemoteKeySetOptions{
+			Client: v.opts.client,
+		})
+	}
+	v.lastErr = err
+	v.discovering = nil
+	v.mu.Unlock()
+
+	close(done)
+}
+
+func (v *oidcVerifier) fetchDiscovery(ctx context.Context) (issuer, jwksURI string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		v.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := v.opts.client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("oidc discovery failed: unexpected status code %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JwksURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&doc); err != nil {
+		return "", "", fmt.Errorf("oidc discovery failed: %w", err)
+	}
+
+	if strings.TrimSuffix(doc.Issuer, "/") != v.issuer {
+		return "", "", fmt.Errorf("oidc discovery failed: issuer %q does not match %q", doc.Issuer, v.issuer)
+	}
+	if doc.JwksURI == "" {
+		return "", "", fmt.Errorf("oidc discovery failed: missing jwks_uri")
+	}
+
+	return doc.Issuer, doc.JwksURI, nil
+}
+
+func (v *oidcVerifier) verify(ctx context.Context, keys jwtutil.KeyProvider, bearer string) (jwtutil.UserInfo, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwtutil.ParseWithKeys(ctx, keys, bearer, claims,
+		jwt.WithAudience(v.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(v.tokenIssuer),
+	)
+	if err != nil {
+		return jwtutil.UserInfo{}, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	username, _ := claimValue(claims, v.opts.usernameClaim).(string)
+	if username == "" {
+		return jwtutil.UserInfo{}, fmt.Errorf("invalid id token: missing %q claim", v.opts.usernameClaim)
+	}
+	if v.opts.usernameClaim == "email" {
+		if verified, ok := claims["email_verified"].(bool); ok && !verified {
+			return jwtutil.UserInfo{}, fmt.Errorf("invalid id token: email not verified")
+		}
+	}
+
+	groups := []string{}
+	switch val := claimValue(claims, v.opts.groupsClaim).(type) {
+	case string:
+		groups = append(groups, val)
+	case []any:
+		for _, el := range val {
+			if g, ok := el.(string); ok {
+				groups = append(groups, g)
+			}
+		}
+	}
+
+	return jwtutil.UserInfo{Username: username, Groups: groups}, nil
+}
+
+// claimValue looks up a claim by its dotted path.
+func claimValue(claims map[string]any, path string) any {
+	var cur any = map[string]any(claims)
+	for _, key := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[key]
+	}
+	return cur
+}
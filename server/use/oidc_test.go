@@ -0,0 +1,296 @@
+package use
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	xcontext "github.com/krateoplatformops/plumbing/context"
+	"github.com/krateoplatformops/plumbing/endpoints"
+	"github.com/krateoplatformops/plumbing/jwtutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIssuer is an in-process OpenID Connect provider.
+type fakeIssuer struct {
+	*httptest.Server
+	key *ecdsa.PrivateKey
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	jwk, err := jwtutil.NewJWK("idp-key", key.Public())
+	require.NoError(t, err)
+	keys := jwtutil.NewKeySet(jwk)
+
+	iss := &fakeIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":   iss.URL,
+			"jwks_uri": iss.URL + "/keys",
+		})
+	})
+	mux.Handle("GET /keys", keys.Handler())
+
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+
+	return iss
+}
+
+func (iss *fakeIssuer) idToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	base := jwt.MapClaims{
+		"iss": iss.URL,
+		"aud": "krateo",
+		"sub": "CgNib2I",
+		"exp": time.Now().Add(time.Minute).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	for k, v := range claims {
+		if v == nil {
+			delete(base, k)
+			continue
+		}
+		base[k] = v
+	}
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodES256, base)
+	tok.Header["kid"] = "idp-key"
+	res, err := tok.SignedString(iss.key)
+	require.NoError(t, err)
+	return res
+}
+
+func TestOIDC(t *testing.T) {
+	iss := newFakeIssuer(t)
+
+	store := endpoints.NewFileStore(t.TempDir())
+	err := store.Put(context.Background(), endpoints.ClientConfigName("bob@example.org"),
+		endpoints.Endpoint{ServerURL: "https://example.org", Token: "XYZ"})
+	require.NoError(t, err)
+
+	var (
+		gotUser jwtutil.UserInfo
+		gotEp   endpoints.Endpoint
+	)
+	route := NewChain(OIDC(iss.URL, "krateo", "demo-system",
+		WithUsernameClaim("email"),
+		WithGroupsClaim("realm_access.roles"),
+		WithOIDCEndpointSource(store),
+	)).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, _ = xcontext.UserInfo(r.Context())
+		gotEp, _ = xcontext.UserConfig(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	valid := jwt.MapClaims{
+		"email":          "bob@example.org",
+		"email_verified": true,
+		"realm_access":   map[string]any{"roles": []string{"devs", "testers"}},
+	}
+	with := func(extra jwt.MapClaims) jwt.MapClaims {
+		res := jwt.MapClaims{}
+		for k, v := range valid {
+			res[k] = v
+		}
+		for k, v := range extra {
+			res[k] = v
+		}
+		return res
+	}
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   int
+	}{
+		{"valid", valid, http.StatusOK},
+		{"wrong audience", with(jwt.MapClaims{"aud": "other"}), http.StatusUnauthorized},
+		{"wrong issuer", with(jwt.MapClaims{"iss": "https://evil.example.org"}), http.StatusUnauthorized},
+		{"expired", with(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}), http.StatusUnauthorized},
+		{"missing expiration", with(jwt.MapClaims{"exp": nil}), http.StatusUnauthorized},
+		{"unverified email", with(jwt.MapClaims{"email_verified": false}), http.StatusUnauthorized},
+		{"missing username", with(jwt.MapClaims{"email": nil}), http.StatusUnauthorized},
+		{"unknown user", with(jwt.MapClaims{"email": "eve@example.org"}), http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+iss.idToken(t, tc.claims))
+			rec := httptest.NewRecorder()
+
+			route.ServeHTTP(rec, req)
+			assert.Equal(t, tc.want, rec.Code, rec.Body.String())
+		})
+	}
+
+	assert.Equal(t, "bob@example.org", gotUser.Username)
+	assert.Equal(t, []string{"devs", "testers"}, gotUser.Groups)
+	assert.Equal(t, "XYZ", gotEp.Token)
+}
+
+func TestOIDCForeignKey(t *testing.T) {
+	iss := newFakeIssuer(t)
+	rogue := newFakeIssuer(t)
+
+	route := OIDC(iss.URL, "krateo", "demo-system",
+		WithOIDCEndpointSource(endpoints.NewFileStore(t.TempDir())),
+	)(http.NotFoundHandler())
+
+	// same kid and issuer, but signed with another key
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+rogue.idToken(t, jwt.MapClaims{"iss": iss.URL}))
+	rec := httptest.NewRecorder()
+
+	route.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestOIDCWithoutKeyID(t *testing.T) {
+	iss := newFakeIssuer(t)
+
+	store := endpoints.NewFileStore(t.TempDir())
+	err := store.Put(context.Background(), endpoints.ClientConfigName("CgNib2I"),
+		endpoints.Endpoint{ServerURL: "https://example.org"})
+	require.NoError(t, err)
+
+	route := OIDC(iss.URL, "krateo", "demo-system",
+		WithOIDCEndpointSource(store),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	sign := func(key *ecdsa.PrivateKey) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+			"iss": iss.URL,
+			"aud": "krateo",
+			"sub": "CgNib2I",
+			"exp": time.Now().Add(time.Minute).Unix(),
+		})
+		res, err := tok.SignedString(key)
+		require.NoError(t, err)
+		return res
+	}
+
+	rogue, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		key  *ecdsa.PrivateKey
+		want int
+	}{
+		"issuer key": {iss.key, http.StatusOK},
+		"other key":  {rogue, http.StatusUnauthorized},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+sign(tc.key))
+			rec := httptest.NewRecorder()
+
+			route.ServeHTTP(rec, req)
+			assert.Equal(t, tc.want, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestOIDCDiscoveryDetached(t *testing.T) {
+	iss := newFakeIssuer(t)
+
+	var discoveries atomic.Int32
+	release := make(chan struct{})
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/openid-configuration" {
+			discoveries.Add(1)
+		}
+		<-release
+		iss.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	store := endpoints.NewFileStore(t.TempDir())
+	err := store.Put(context.Background(), endpoints.ClientConfigName("CgNib2I"),
+		endpoints.Endpoint{ServerURL: "https://example.org"})
+	require.NoError(t, err)
+
+	route := OIDC(iss.URL, "krateo", "demo-system",
+		WithOIDCEndpointSource(store),
+		WithDiscoveryClient(&http.Client{
+			Transport: &http.Transport{
+				Proxy: func(*http.Request) (*url.URL, error) { return url.Parse(proxy.URL) },
+			},
+		}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	bearer := "Bearer " + iss.idToken(t, nil)
+
+	// the first caller gives up while the discovery is in progress
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan int, 1)
+	go func() {
+		req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		req.Header.Set("Authorization", bearer)
+		rec := httptest.NewRecorder()
+		route.ServeHTTP(rec, req)
+		canceled <- rec.Code
+	}()
+
+	waiting := make(chan int, 1)
+	go func() {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", bearer)
+		rec := httptest.NewRecorder()
+		route.ServeHTTP(rec, req)
+		waiting <- rec.Code
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	assert.Equal(t, http.StatusServiceUnavailable, <-canceled)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-waiting)
+	assert.Equal(t, int32(1), discoveries.Load())
+}
+
+func TestOIDCDiscoveryFailure(t *testing.T) {
+	var discoveries atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		discoveries.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	route := OIDC(ts.URL, "krateo", "demo-system")(http.NotFoundHandler())
+
+	// the failed discovery is not retried right away
+	for range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer abc.def.ghi")
+		rec := httptest.NewRecorder()
+
+		route.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	}
+	assert.Equal(t, int32(1), discoveries.Load())
+}
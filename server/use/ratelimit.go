@@ -0,0 +1,237 @@
+package use
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/cache"
+	xcontext "github.com/krateoplatformops/plumbing/context"
+	"github.com/krateoplatformops/plumbing/http/response"
+	"github.com/krateoplatformops/plumbing/jwtutil"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitQuota is the token bucket applied to each client.
+type RateLimitQuota struct {
+	// QPS is the sustained number of requests per second.
+	QPS float64
+	// Burst is the maximum number of requests allowed at once.
+	Burst int
+}
+
+type rateLimitOptions struct {
+	groups     map[string]RateLimitQuota
+	trusted    []netip.Prefix
+	maxEntries int
+	signingKey string
+	now        func() time.Time
+}
+
+type RateLimitOption func(*rateLimitOptions)
+
+// WithGroupQuota overrides the quota of the users belonging to the
+// specified group; users in several groups get the most generous one.
+func WithGroupQuota(group string, quota RateLimitQuota) RateLimitOption {
+	return func(opts *rateLimitOptions) {
+		opts.groups[group] = quota
+	}
+}
+
+// WithRateLimitTrustedProxies sets the proxies allowed to report
+// the address of anonymous clients (see ClientIP).
+func WithRateLimitTrustedProxies(prefixes ...netip.Prefix) RateLimitOption {
+	return func(opts *rateLimitOptions) {
+		opts.trusted = append(opts.trusted, prefixes...)
+	}
+}
+
+// WithRateLimitMaxEntries caps the number of tracked users and, separately,
+// of tracked client IPs (default: 10000 each); the least recently seen
+// ones are dropped first.
+func WithRateLimitMaxEntries(n int) RateLimitOption {
+	return func(opts *rateLimitOptions) {
+		if n > 0 {
+			opts.maxEntries = n
+		}
+	}
+}
+
+// WithRateLimitSigningKey sets the key used to verify the Authorization
+// token when RateLimit runs before UserConfig; without it, the requests
+// not authenticated by UserConfig are limited by client IP.
+func WithRateLimitSigningKey(signingKey string) RateLimitOption {
+	return func(opts *rateLimitOptions) {
+		opts.signingKey = signingKey
+	}
+}
+
+// RateLimit enforces a token bucket per authenticated user, or per
+// client IP for anonymous requests, replying with a 429 Status when
+// the quota is exhausted.
+//
+// Every response carries the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers; 429 responses carry Retry-After too.
+//
+// Only verified users are trusted: the user is taken from the request
+// context when RateLimit follows UserConfig, otherwise from the
+// Authorization token if it is valid for the WithRateLimitSigningKey
+// key. All the other requests are limited by client IP, with the
+// default quota. Since only that HMAC key is checked, tokens issued by
+// the jwtutil signers or validated through a JWKS are not recognized
+// before UserConfig or OIDC have run: place RateLimit after them to
+// limit those users by name.
+//
+// The buckets of the users and of the client IPs are kept apart, so
+// that requests from many addresses can't evict the users' buckets.
+func RateLimit(quota RateLimitQuota, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	cfg := rateLimitOptions{
+		groups:     map[string]RateLimitQuota{},
+		maxEntries: 10000,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+
+	rl := &rateLimiter{
+		opts:  cfg,
+		quota: quota,
+		users: cache.NewTTL[string, *rate.Limiter](
+			cache.WithCleanupInterval(0),
+			cache.WithMaxEntries(cfg.maxEntries),
+		),
+		ips: cache.NewTTL[string, *rate.Limiter](
+			cache.WithCleanupInterval(0),
+			cache.WithMaxEntries(cfg.maxEntries),
+		),
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(wri http.ResponseWriter, req *http.Request) {
+			items, key, q := rl.identify(req)
+			if !rl.allow(wri.Header(), items, key, q) {
+				response.TooManyRequests(wri,
+					fmt.Errorf("rate limit exceeded, retry in %s seconds",
+						wri.Header().Get("Retry-After")))
+				return
+			}
+
+			next.ServeHTTP(wri, req)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+type rateLimiter struct {
+	opts  rateLimitOptions
+	quota RateLimitQuota
+
+	mu    sync.Mutex
+	users *cache.TTLCache[string, *rate.Limiter]
+	ips   *cache.TTLCache[string, *rate.Limiter]
+}
+
+// identify returns the buckets cache, the bucket key
+// and the quota of the request client.
+func (rl *rateLimiter) identify(req *http.Request) (*cache.TTLCache[string, *rate.Limiter], string, RateLimitQuota) {
+	ui, err := xcontext.UserInfo(req.Context())
+	if err != nil {
+		ui = rl.verifiedUser(req)
+	}
+
+	if len(ui.Username) == 0 {
+		return rl.ips, ClientIP(req, rl.opts.trusted), rl.quota
+	}
+
+	q, found := RateLimitQuota{}, false
+	for _, g := range ui.Groups {
+		gq, ok := rl.opts.groups[g]
+		if ok && (!found || gq.QPS > q.QPS || (gq.QPS == q.QPS && gq.Burst > q.Burst)) {
+			q, found = gq, true
+		}
+	}
+	if !found {
+		q = rl.quota
+	}
+
+	return rl.users, ui.Username, q
+}
+
+// verifiedUser returns the user of the Authorization token, or
+// an empty UserInfo if the token can't be verified.
+func (rl *rateLimiter) verifiedUser(req *http.Request) jwtutil.UserInfo {
+	if len(rl.opts.signingKey) == 0 {
+		return jwtutil.UserInfo{}
+	}
+
+	parts := strings.SplitN(req.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return jwtutil.UserInfo{}
+	}
+
+	claims, err := jwtutil.ValidateClaims(rl.opts.signingKey, parts[1])
+	if err != nil {
+		return jwtutil.UserInfo{}
+	}
+	return claims.UserInfo
+}
+
+// allow takes a token from the client bucket, setting the
+// rate limit headers; it returns false if none is available.
+func (rl *rateLimiter) allow(h http.Header, items *cache.TTLCache[string, *rate.Limiter], key string, q RateLimitQuota) bool {
+	now := rl.opts.now()
+	limit, burst := rate.Limit(q.QPS), max(q.Burst, 1)
+
+	rl.mu.Lock()
+	lim, ok := items.Get(key)
+	if !ok {
+		lim = rate.NewLimiter(limit, burst)
+	} else if lim.Limit() != limit || lim.Burst() != burst {
+		// group membership (or configuration) changed
+		lim.SetLimitAt(now, limit)
+		lim.SetBurstAt(now, burst)
+	}
+	// Once refilled an idle bucket is the same as a new one,
+	// so it can be dropped as soon as it would be full again.
+	items.Set(key, lim, max(time.Minute, secondsToDuration(float64(burst)/q.QPS)))
+
+	allowed := lim.AllowN(now, 1)
+	tokens := lim.TokensAt(now)
+	rl.mu.Unlock()
+
+	h.Set("RateLimit-Limit", strconv.Itoa(burst))
+	h.Set("RateLimit-Remaining", strconv.Itoa(max(int(math.Floor(tokens)), 0)))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds((float64(burst)-tokens)/q.QPS)))
+
+	if !allowed {
+		h.Set("Retry-After", strconv.Itoa(max(ceilSeconds((1-tokens)/q.QPS), 1)))
+	}
+
+	return allowed
+}
+
+func ceilSeconds(secs float64) int {
+	if secs <= 0 || math.IsNaN(secs) {
+		return 0
+	}
+	if math.IsInf(secs, 1) {
+		return math.MaxInt32
+	}
+	return int(math.Ceil(secs))
+}
+
+func secondsToDuration(secs float64) time.Duration {
+	if math.IsNaN(secs) || math.IsInf(secs, 0) || secs > float64(math.MaxInt64/int64(time.Second)) {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(secs * float64(time.Second))
+}
@@ -0,0 +1,166 @@
+package use
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/http/response"
+	"github.com/krateoplatformops/plumbing/jwtutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	route := RateLimit(RateLimitQuota{QPS: 1, Burst: 2},
+		WithGroupQuota("admins", RateLimitQuota{QPS: 10, Burst: 5}),
+		WithRateLimitSigningKey("secret"),
+		func(opts *rateLimitOptions) { opts.now = clock },
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	call := func(remote, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote
+		if len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		route.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("per client ip", func(t *testing.T) {
+		rec := call("203.0.113.1:1000", "")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Reset"))
+
+		assert.Equal(t, http.StatusNoContent, call("203.0.113.1:1001", "").Code)
+
+		rec = call("203.0.113.1:1002", "")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+		var status response.Status
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		assert.Equal(t, response.StatusReasonTooManyRequests, status.Reason)
+
+		// other clients have their own bucket
+		assert.Equal(t, http.StatusNoContent, call("203.0.113.2:1000", "").Code)
+
+		// the bucket refills over time
+		now = now.Add(time.Second)
+		assert.Equal(t, http.StatusNoContent, call("203.0.113.1:1003", "").Code)
+	})
+
+	t.Run("per user with group quota", func(t *testing.T) {
+		token := func(user string, groups ...string) string {
+			return testRateLimitToken(t, "secret", user, groups...)
+		}
+
+		joe := token("joe", "devs")
+		for range 2 {
+			assert.Equal(t, http.StatusNoContent, call("203.0.113.9:1000", joe).Code)
+		}
+		assert.Equal(t, http.StatusTooManyRequests, call("203.0.113.10:1000", joe).Code)
+
+		admin := token("alice", "devs", "admins")
+		for range 5 {
+			rec := call("203.0.113.9:1000", admin)
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+			assert.Equal(t, "5", rec.Header().Get("RateLimit-Limit"))
+		}
+		assert.Equal(t, http.StatusTooManyRequests, call("203.0.113.9:1000", admin).Code)
+	})
+
+	t.Run("forged token", func(t *testing.T) {
+		forged := testRateLimitToken(t, "not-the-secret", "mallory", "admins")
+
+		// limited by client IP with the default quota
+		for range 2 {
+			rec := call("203.0.113.20:1000", forged)
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+			assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+		}
+		assert.Equal(t, http.StatusTooManyRequests, call("203.0.113.20:1000", forged).Code)
+
+		// other clients aren't affected
+		assert.Equal(t, http.StatusNoContent, call("203.0.113.21:1000", forged).Code)
+	})
+}
+
+func TestRateLimitWithoutSigningKey(t *testing.T) {
+	route := RateLimit(RateLimitQuota{QPS: 1, Burst: 1},
+		WithGroupQuota("admins", RateLimitQuota{QPS: 10, Burst: 5}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	token := testRateLimitToken(t, "secret", "alice", "admins")
+	for i, want := range []int{http.StatusNoContent, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.30:1000"
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		route.ServeHTTP(rec, req)
+		assert.Equal(t, want, rec.Code, "request %d", i)
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimitIPsDoNotEvictUsers(t *testing.T) {
+	now := time.Now()
+
+	route := RateLimit(RateLimitQuota{QPS: 0.001, Burst: 1},
+		WithRateLimitSigningKey("secret"),
+		WithRateLimitMaxEntries(10),
+		func(opts *rateLimitOptions) { opts.now = func() time.Time { return now } },
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	call := func(remote, token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote
+		if len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		route.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	token := testRateLimitToken(t, "secret", "cyberjoker")
+	assert.Equal(t, http.StatusNoContent, call("203.0.113.1:1000", token))
+	assert.Equal(t, http.StatusTooManyRequests, call("203.0.113.1:1000", token))
+
+	// An anonymous client spread across many addresses.
+	for i := range 50 {
+		call(fmt.Sprintf("198.51.100.%d:1000", i), "")
+	}
+
+	assert.Equal(t, http.StatusTooManyRequests, call("203.0.113.1:1000", token))
+}
+
+func testRateLimitToken(t *testing.T, signingKey, user string, groups ...string) string {
+	t.Helper()
+
+	res, err := jwtutil.CreateToken(jwtutil.CreateTokenOptions{
+		Username:   user,
+		Groups:     groups,
+		Duration:   time.Minute,
+		SigningKey: signingKey,
+	})
+	require.NoError(t, err)
+	return res
+}
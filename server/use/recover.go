@@ -0,0 +1,94 @@
+package use
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	xcontext "github.com/krateoplatformops/plumbing/context"
+	"github.com/krateoplatformops/plumbing/http/response"
+)
+
+// RecoveredPanic describes a panic raised by a handler.
+type RecoveredPanic struct {
+	Request *http.Request
+	Panic   any
+	Stack   []byte
+}
+
+type PanicHook func(RecoveredPanic)
+
+type recoverOptions struct {
+	hook PanicHook
+}
+
+type RecoverOption func(*recoverOptions)
+
+// WithPanicHook sets a function called for every recovered panic,
+// i.e. to publish it on an event bus or to report it elsewhere.
+func WithPanicHook(hook PanicHook) RecoverOption {
+	return func(opts *recoverOptions) {
+		opts.hook = hook
+	}
+}
+
+// Recover catches the panics raised by the next handlers, logging them
+// with their trace id and stack trace through the request logger (see
+// Logger) and replying with a generic InternalError Status: the panic
+// value is never sent to the client, it is only logged and passed to the
+// PanicHook.
+//
+// When the response has already been started, only the log is written.
+// Panics with http.ErrAbortHandler are propagated to the server as is.
+func Recover(opts ...RecoverOption) func(http.Handler) http.Handler {
+	cfg := recoverOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(wri http.ResponseWriter, req *http.Request) {
+			sw := &statusWriter{ResponseWriter: wri}
+
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(rec)
+				}
+
+				stack := debug.Stack()
+
+				log := xcontext.Logger(req.Context())
+				log.Error("handler panic",
+					slog.String("traceId", xcontext.TraceId(req.Context(), false)),
+					slog.String("method", req.Method),
+					slog.String("url", req.URL.String()),
+					slog.Any("panic", rec),
+					slog.String("stack", string(stack)),
+				)
+
+				if cfg.hook != nil {
+					cfg.hook(RecoveredPanic{
+						Request: req,
+						Panic:   rec,
+						Stack:   stack,
+					})
+				}
+
+				if sw.status == 0 {
+					response.InternalError(sw, errors.New(http.StatusText(http.StatusInternalServerError)))
+				}
+			}()
+
+			next.ServeHTTP(sw, req)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,110 @@
+package use
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	xcontext "github.com/krateoplatformops/plumbing/context"
+	"github.com/krateoplatformops/plumbing/http/response"
+	"github.com/krateoplatformops/plumbing/jwtutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecover(t *testing.T) {
+	buf := bytes.Buffer{}
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	token, err := jwtutil.CreateToken(jwtutil.CreateTokenOptions{
+		Username:   "cyberjoker",
+		Duration:   time.Minute,
+		SigningKey: "secret",
+	})
+	require.NoError(t, err)
+
+	var got RecoveredPanic
+	route := NewChain(
+		TraceId(),
+		Logger(log),
+		Recover(WithPanicHook(func(p RecoveredPanic) { got = p })),
+	).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("something went wrong")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/boom", nil)
+	req.Header.Set(xcontext.LabelKrateoTraceId, "abc123")
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	route.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	var status response.Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, http.StatusInternalServerError, status.Code)
+	assert.NotContains(t, status.Message, "something went wrong")
+
+	assert.Equal(t, "something went wrong", got.Panic)
+	assert.NotEmpty(t, got.Stack)
+	assert.Equal(t, "/boom", got.Request.URL.Path)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "handler panic", entry["msg"])
+	assert.Equal(t, "abc123", entry["traceId"])
+	assert.Contains(t, entry["stack"], "recover_test.go")
+}
+
+func TestRecoverUnauthenticated(t *testing.T) {
+	buf := bytes.Buffer{}
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	route := NewChain(
+		TraceId(),
+		Logger(log),
+		Recover(),
+	).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("something went wrong")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/boom", nil)
+	req.Header.Set(xcontext.LabelKrateoTraceId, "abc123")
+	rec := httptest.NewRecorder()
+	route.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "handler panic", entry["msg"])
+	assert.Equal(t, "abc123", entry["traceId"])
+	assert.NotContains(t, entry, "user")
+}
+
+func TestRecoverAfterWriteHeader(t *testing.T) {
+	route := Recover()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("too late")
+	}))
+
+	rec := httptest.NewRecorder()
+	route.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestRecoverAbortHandler(t *testing.T) {
+	route := Recover()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		route.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
@@ -0,0 +1,47 @@
+package use
+
+import (
+	"context"
+	"net/http"
+)
+
+type routeKey struct{}
+
+// routeHolder hands the http.ServeMux pattern matched by a request back
+// to the middlewares wrapping the mux: the mux sets the pattern only on
+// the request it receives, while most middlewares pass a copy along.
+type routeHolder struct {
+	pattern string
+}
+
+// withRoute returns the request carrying a route holder,
+// the one of an outer middleware when there is one.
+func withRoute(req *http.Request) (*http.Request, *routeHolder) {
+	if h, ok := req.Context().Value(routeKey{}).(*routeHolder); ok {
+		return req, h
+	}
+
+	h := &routeHolder{}
+	return req.WithContext(context.WithValue(req.Context(), routeKey{}, h)), h
+}
+
+// setRoute records the pattern of the request, if any,
+// in the route holder of its context.
+func setRoute(req *http.Request) {
+	if len(req.Pattern) == 0 {
+		return
+	}
+	if h, ok := req.Context().Value(routeKey{}).(*routeHolder); ok {
+		h.pattern = req.Pattern
+	}
+}
+
+// RecordRoute makes the pattern matched by the mux available to TraceId
+// and Metrics, wherever they are in the middleware stack; it must wrap
+// the mux itself. Chain.Then already applies it to the final handler.
+func RecordRoute(mux http.Handler) http.Handler {
+	return http.HandlerFunc(func(wri http.ResponseWriter, req *http.Request) {
+		mux.ServeHTTP(wri, req)
+		setRoute(req)
+	})
+}
@@ -0,0 +1,115 @@
+package use
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/http/response"
+)
+
+// Timeout runs the next handlers with a deadline, replying with
+// a Timeout Status (504) when it expires.
+//
+// It follows the http.TimeoutHandler semantics: the response is buffered
+// until the handler returns, and writes after the deadline fail with
+// http.ErrHandlerTimeout. Streaming handlers (i.e. server-sent events)
+// must not be mounted behind this middleware.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(wri http.ResponseWriter, req *http.Request) {
+			ctx, cancel := context.WithTimeout(req.Context(), d)
+			defer cancel()
+			req = req.WithContext(ctx)
+
+			tw := &timeoutWriter{
+				w:    wri,
+				h:    make(http.Header),
+				code: http.StatusOK,
+			}
+
+			done := make(chan struct{})
+			panicChan := make(chan any, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicChan <- p
+					}
+				}()
+				next.ServeHTTP(tw, req)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicChan:
+				panic(p)
+
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+
+				dst := wri.Header()
+				for k, vv := range tw.h {
+					dst[k] = vv
+				}
+				wri.WriteHeader(tw.code)
+				wri.Write(tw.buf.Bytes())
+
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+
+				tw.timedOut = true
+				if ctx.Err() == context.DeadlineExceeded {
+					response.GatewayTimeout(wri,
+						fmt.Errorf("request timed out after %s", d))
+				}
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// timeoutWriter buffers the response until the handler returns.
+type timeoutWriter struct {
+	w    http.ResponseWriter
+	h    http.Header
+	buf  bytes.Buffer
+	code int
+
+	mu          sync.Mutex
+	timedOut    bool
+	wroteHeader bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.h }
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	tw.wroteHeader = true
+	tw.code = code
+}
@@ -0,0 +1,65 @@
+package use
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/http/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeout(t *testing.T) {
+	writeErr := make(chan error, 1)
+
+	route := Timeout(50 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			time.Sleep(10 * time.Millisecond)
+			_, err := w.Write([]byte("too late"))
+			writeErr <- err
+			return
+		}
+
+		w.Header().Set("X-Custom", "fast")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	}))
+
+	t.Run("fast handler", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		route.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "fast", rec.Header().Get("X-Custom"))
+		assert.Equal(t, "done", rec.Body.String())
+	})
+
+	t.Run("slow handler", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		route.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+		assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+
+		var status response.Status
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		assert.Equal(t, response.StatusReasonTimeout, status.Reason)
+
+		assert.ErrorIs(t, <-writeErr, http.ErrHandlerTimeout)
+	})
+}
+
+func TestTimeoutPropagatesPanic(t *testing.T) {
+	route := NewChain(Recover(), Timeout(time.Second)).
+		ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+
+	rec := httptest.NewRecorder()
+	route.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
@@ -5,23 +5,68 @@ import (
 
 	xcontext "github.com/krateoplatformops/plumbing/context"
 	"github.com/krateoplatformops/plumbing/shortid"
+	"github.com/krateoplatformops/plumbing/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// TraceId propagates the Krateo trace id and the W3C trace context,
+// starting an OpenTelemetry server span for every request.
+//
+// The two ids are kept in sync: a request carrying only a traceparent
+// gets its trace-id as Krateo trace id, otherwise the W3C trace-id is
+// derived from the Krateo trace id (see tracing.ContextWithKrateoTraceId).
+//
+// The span is named after the http.ServeMux pattern of the request,
+// or after the method alone when no pattern matched; the pattern is
+// found at any position in a Chain (see RecordRoute).
 func TraceId() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(wri http.ResponseWriter, req *http.Request) {
+			ctx := tracing.Propagator().Extract(req.Context(),
+				propagation.HeaderCarrier(req.Header))
+			parent := trace.SpanContextFromContext(ctx)
 
 			traceId := req.Header.Get(xcontext.LabelKrateoTraceId)
 			if len(traceId) == 0 {
-				traceId = shortid.MustGenerate()
+				if parent.IsValid() {
+					traceId = parent.TraceID().String()
+				} else {
+					traceId = shortid.MustGenerate()
+				}
 			}
 			req.Header.Set(xcontext.LabelKrateoTraceId, traceId)
 
-			ctx := xcontext.BuildContext(req.Context(),
+			ctx = tracing.ContextWithKrateoTraceId(ctx, traceId)
+			ctx, span := tracing.Tracer().Start(ctx, tracing.SpanName(req.Method, req.Pattern),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", req.Method),
+					attribute.String("url.path", req.URL.Path),
+					attribute.String("krateo.trace_id", traceId),
+				))
+			defer span.End()
+
+			ctx = xcontext.BuildContext(ctx,
 				xcontext.WithTraceId(traceId),
 			)
 
-			next.ServeHTTP(wri, req.WithContext(ctx))
+			sw := &statusWriter{ResponseWriter: wri}
+			out, route := withRoute(req.WithContext(ctx))
+			next.ServeHTTP(sw, out)
+			setRoute(out)
+
+			if len(route.pattern) > 0 {
+				span.SetName(tracing.SpanName(req.Method, route.pattern))
+				span.SetAttributes(attribute.String("http.route", route.pattern))
+			}
+
+			span.SetAttributes(attribute.Int("http.response.status_code", sw.Status()))
+			if sw.Status() >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(sw.Status()))
+			}
 		}
 
 		return http.HandlerFunc(fn)
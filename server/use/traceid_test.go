@@ -2,12 +2,26 @@ package use
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	xcontext "github.com/krateoplatformops/plumbing/context"
+	"github.com/krateoplatformops/plumbing/endpoints"
+	"github.com/krateoplatformops/plumbing/jwtutil"
+	"github.com/krateoplatformops/plumbing/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestTraceIdMiddleware(t *testing.T) {
@@ -36,3 +50,181 @@ func TestTraceIdMiddleware(t *testing.T) {
 	// Check the log output.
 	fmt.Println(buf.String())
 }
+
+// inMemoryTracer installs a TracerProvider recording
+// the spans in memory until the end of the test.
+func inMemoryTracer(t *testing.T) *tracetest.InMemoryExporter {
+	exp := tracetest.NewInMemoryExporter()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return exp
+}
+
+func TestTraceIdW3C(t *testing.T) {
+	exp := inMemoryTracer(t)
+
+	tests := []struct {
+		name        string
+		traceparent string
+		krateoId    string
+		wantTraceId string
+		wantKrateo  string
+	}{
+		{
+			name:        "traceparent only",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantTraceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantKrateo:  "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:        "krateo trace id only",
+			krateoId:    "p0Vq1DSxR",
+			wantTraceId: tracing.TraceIDFromString("p0Vq1DSxR").String(),
+			wantKrateo:  "p0Vq1DSxR",
+		},
+		{
+			name:        "both",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			krateoId:    "p0Vq1DSxR",
+			wantTraceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantKrateo:  "p0Vq1DSxR",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			exp.Reset()
+
+			var (
+				gotSpan   trace.SpanContext
+				gotKrateo string
+			)
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /hello/{name}", func(w http.ResponseWriter, r *http.Request) {
+				gotSpan = trace.SpanContextFromContext(r.Context())
+				gotKrateo = xcontext.TraceId(r.Context(), false)
+				w.WriteHeader(http.StatusTeapot)
+			})
+			route := NewChain(TraceId()).Then(mux)
+
+			req := httptest.NewRequest(http.MethodGet, "/hello/world", nil)
+			if len(tc.traceparent) > 0 {
+				req.Header.Set("traceparent", tc.traceparent)
+			}
+			if len(tc.krateoId) > 0 {
+				req.Header.Set(xcontext.LabelKrateoTraceId, tc.krateoId)
+			}
+			route.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tc.wantTraceId, gotSpan.TraceID().String())
+			assert.Equal(t, tc.wantKrateo, gotKrateo)
+
+			spans := exp.GetSpans()
+			require.Len(t, spans, 1)
+			assert.Equal(t, "GET /hello/{name}", spans[0].Name)
+			assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind)
+			assert.Equal(t, gotSpan.SpanID(), spans[0].SpanContext.SpanID())
+			assert.Contains(t, spans[0].Attributes, attribute.Int("http.response.status_code", http.StatusTeapot))
+			if len(tc.traceparent) > 0 {
+				assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent.SpanID().String())
+			}
+		})
+	}
+}
+
+func TestTraceIdUnmatchedRoute(t *testing.T) {
+	exp := inMemoryTracer(t)
+
+	route := NewChain(TraceId()).Then(http.NewServeMux())
+	route.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/random/8f2c", nil))
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET", spans[0].Name)
+}
+
+func TestTraceIdRespectsUnsampledParent(t *testing.T) {
+	exp := inMemoryTracer(t)
+
+	var got trace.SpanContext
+	route := NewChain(TraceId()).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = trace.SpanContextFromContext(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	route.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", got.TraceID().String())
+	assert.False(t, got.IsSampled())
+	assert.Empty(t, exp.GetSpans())
+}
+
+func TestTraceIdKeepsFlusherAndHijacker(t *testing.T) {
+	route := NewChain(TraceId()).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: hello\n\n"))
+		w.(http.Flusher).Flush()
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack failed: %v", err)
+			return
+		}
+		conn.Close()
+	})
+
+	srv := httptest.NewServer(route)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+	}
+
+	rec := httptest.NewRecorder()
+	NewChain(TraceId()).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+	}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, rec.Flushed)
+}
+
+func TestTraceIdRouteThroughMiddlewares(t *testing.T) {
+	const signingKey = "abbracadabbra"
+
+	exp := inMemoryTracer(t)
+
+	store := endpoints.NewFileStore(t.TempDir())
+	err := store.Put(context.Background(), endpoints.ClientConfigName("cyberjoker"),
+		endpoints.Endpoint{ServerURL: "https://example.org"})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	route := NewChain(
+		TraceId(),
+		Logger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		UserConfig(signingKey, "demo-system", WithEndpointSource(store)),
+	).Then(mux)
+
+	bearer, err := jwtutil.CreateToken(jwtutil.CreateTokenOptions{
+		Username:   "cyberjoker",
+		Duration:   time.Minute,
+		SigningKey: signingKey,
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/items/route-test", nil)
+	req.Header.Set("Authorization", "Bearer "+bearer)
+	rec := httptest.NewRecorder()
+	route.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET /items/{id}", spans[0].Name)
+	assert.Contains(t, spans[0].Attributes, attribute.String("http.route", "GET /items/{id}"))
+}
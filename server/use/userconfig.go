@@ -6,17 +6,58 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 
 	xcontext "github.com/krateoplatformops/plumbing/context"
 	"github.com/krateoplatformops/plumbing/endpoints"
 	"github.com/krateoplatformops/plumbing/http/response"
 	"github.com/krateoplatformops/plumbing/jwtutil"
-	"github.com/krateoplatformops/plumbing/kubeutil"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
 
-func UserConfig(signingKey, authnNS string) func(http.Handler) http.Handler {
+type userConfigOptions struct {
+	source  endpoints.Source
+	revoked jwtutil.RevocationStore
+}
+
+type UserConfigOption func(*userConfigOptions)
+
+// WithEndpointSource sets the backend used to look up the user endpoints.
+// When not specified, the "-clientconfig" Secrets of the authn namespace
+// are served by an endpoints.InformerStore, created on the first request
+// using the in cluster configuration and shared by all the middlewares
+// of the process using the same namespace.
+//
+// The informer only watches the Secrets labeled by endpoints.SecretStore
+// and needs the "list" and "watch" verbs on Secrets in the authn
+// namespace; when they are forbidden the Secrets are read one by one,
+// only needing "get". The informers are stopped by StopInClusterSources.
+func WithEndpointSource(src endpoints.Source) UserConfigOption {
+	return func(opts *userConfigOptions) {
+		opts.source = src
+	}
+}
+
+// WithRevocationStore rejects the tokens revoked before their expiration,
+// i.e. when the user has been logged out or removed from a group.
+func WithRevocationStore(store jwtutil.RevocationStore) UserConfigOption {
+	return func(opts *userConfigOptions) {
+		opts.revoked = store
+	}
+}
+
+func UserConfig(signingKey, authnNS string, opts ...UserConfigOption) func(http.Handler) http.Handler {
+	cfg := userConfigOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	if cfg.source == nil {
+		cfg.source = &inClusterSource{namespace: authnNS}
+	}
+
 	return func(next http.Handler) http.Handler {
 		fn := func(wri http.ResponseWriter, req *http.Request) {
 			authHeader := req.Header.Get("Authorization")
@@ -31,7 +72,7 @@ func UserConfig(signingKey, authnNS string) func(http.Handler) http.Handler {
 				return
 			}
 
-			userInfo, err := jwtutil.Validate(signingKey, parts[1])
+			claims, err := jwtutil.ValidateClaims(signingKey, parts[1])
 			if err != nil {
 				if errors.Is(err, jwtutil.ErrTokenExpired) {
 					response.Unauthorized(wri, err)
@@ -40,22 +81,19 @@ func UserConfig(signingKey, authnNS string) func(http.Handler) http.Handler {
 				}
 				return
 			}
+			userInfo := claims.UserInfo
 
-			sarc, err := rest.InClusterConfig()
-			if err != nil {
-				response.InternalError(wri, fmt.Errorf("unable to create in cluster config: %w", err))
+			if err := jwtutil.CheckRevoked(req.Context(), cfg.revoked, claims); err != nil {
+				if errors.Is(err, jwtutil.ErrTokenRevoked) {
+					response.Unauthorized(wri, err)
+				} else {
+					response.InternalError(wri, err)
+				}
 				return
 			}
 
-			ep, err := endpoints.FromSecret(context.Background(), sarc,
-				fmt.Sprintf("%s-clientconfig",
-					kubeutil.MakeDNS1123Compatible(userInfo.Username)), authnNS)
-			if err != nil {
-				if apierrors.IsNotFound(err) {
-					response.Unauthorized(wri, err)
-					return
-				}
-				response.InternalError(wri, err)
+			ep, ok := userEndpoint(wri, cfg.source, userInfo.Username)
+			if !ok {
 				return
 			}
 
@@ -71,3 +109,90 @@ func UserConfig(signingKey, authnNS string) func(http.Handler) http.Handler {
 		return http.HandlerFunc(fn)
 	}
 }
+
+// userEndpoint looks up the user endpoint, replying with
+// an error Status when it cannot be found.
+func userEndpoint(wri http.ResponseWriter, src endpoints.Source, username string) (endpoints.Endpoint, bool) {
+	ep, err := src.Get(context.Background(), endpoints.ClientConfigName(username))
+	if err != nil {
+		if endpoints.IsNotFound(err) {
+			response.Unauthorized(wri, err)
+			return endpoints.Endpoint{}, false
+		}
+		response.InternalError(wri, err)
+		return endpoints.Endpoint{}, false
+	}
+
+	return ep, true
+}
+
+// inClusterSource serves the endpoints from the InformerStore shared
+// by the process for the namespace (see inClusterStore).
+type inClusterSource struct {
+	namespace string
+}
+
+func (s *inClusterSource) Get(ctx context.Context, name string) (endpoints.Endpoint, error) {
+	store, err := inClusterStore(s.namespace)
+	if err != nil {
+		return endpoints.Endpoint{}, err
+	}
+	return store.Get(ctx, name)
+}
+
+// runningStore is an InformerStore with the function stopping it.
+type runningStore struct {
+	*endpoints.InformerStore
+	stop context.CancelFunc
+}
+
+var inClusterStores = struct {
+	mu    sync.Mutex
+	items map[string]runningStore
+}{items: map[string]runningStore{}}
+
+// StopInClusterSources stops the informers started by the UserConfig
+// and OIDC middlewares using the default Source; the signature fits
+// server.Runner.OnShutdown. Requests served afterwards start them again.
+func StopInClusterSources(context.Context) error {
+	inClusterStores.mu.Lock()
+	defer inClusterStores.mu.Unlock()
+
+	for ns, el := range inClusterStores.items {
+		el.stop()
+		delete(inClusterStores.items, ns)
+	}
+	return nil
+}
+
+// inClusterStore lazily starts one InformerStore per namespace, living
+// until StopInClusterSources is called; when the in cluster configuration
+// is not available the error is returned and the creation retried on the
+// next request.
+func inClusterStore(namespace string) (*endpoints.InformerStore, error) {
+	inClusterStores.mu.Lock()
+	defer inClusterStores.mu.Unlock()
+
+	if el, ok := inClusterStores.items[namespace]; ok {
+		return el.InformerStore, nil
+	}
+
+	rc, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create in cluster config: %w", err)
+	}
+	cli, err := kubernetes.NewForConfig(rc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create kubernetes client: %w", err)
+	}
+
+	store := endpoints.NewInformerStore(endpoints.InformerStoreOptions{
+		Client:    cli,
+		Namespace: namespace,
+	})
+	ctx, stop := context.WithCancel(context.Background())
+	store.Start(ctx)
+
+	inClusterStores.items[namespace] = runningStore{InformerStore: store, stop: stop}
+	return store, nil
+}
@@ -0,0 +1,217 @@
+package use
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	xcontext "github.com/krateoplatformops/plumbing/context"
+	"github.com/krateoplatformops/plumbing/endpoints"
+	"github.com/krateoplatformops/plumbing/jwtutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestUserConfigWithEndpointSource(t *testing.T) {
+	const signingKey = "abbracadabbra"
+
+	store := endpoints.NewFileStore(t.TempDir())
+	err := store.Put(context.Background(), endpoints.ClientConfigName("cyberjoker"),
+		endpoints.Endpoint{ServerURL: "https://example.org", Token: "XYZ"})
+	require.NoError(t, err)
+
+	var got endpoints.Endpoint
+	route := NewChain(UserConfig(signingKey, "demo-system", WithEndpointSource(store))).
+		ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, _ = xcontext.UserConfig(r.Context())
+			w.WriteHeader(http.StatusOK)
+		})
+
+	tests := []struct {
+		username string
+		want     int
+	}{
+		{"cyberjoker", http.StatusOK},
+		{"stranger", http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.username, func(t *testing.T) {
+			bearer, err := jwtutil.CreateToken(jwtutil.CreateTokenOptions{
+				Username:   tc.username,
+				Groups:     []string{"devs"},
+				Duration:   time.Minute,
+				SigningKey: signingKey,
+			})
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", bearer))
+			rec := httptest.NewRecorder()
+
+			route.ServeHTTP(rec, req)
+			assert.Equal(t, tc.want, rec.Code)
+		})
+	}
+
+	assert.Equal(t, "XYZ", got.Token)
+}
+
+func TestUserConfigWithRevocationStore(t *testing.T) {
+	const signingKey = "abbracadabbra"
+
+	store := endpoints.NewFileStore(t.TempDir())
+	for _, user := range []string{"cyberjoker", "stranger"} {
+		err := store.Put(context.Background(), endpoints.ClientConfigName(user),
+			endpoints.Endpoint{ServerURL: "https://example.org", Token: "XYZ"})
+		require.NoError(t, err)
+	}
+
+	revocations := jwtutil.NewMemoryRevocationStore()
+	defer revocations.Close()
+
+	route := NewChain(UserConfig(signingKey, "demo-system",
+		WithEndpointSource(store),
+		WithRevocationStore(revocations),
+	)).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	newToken := func(username string) string {
+		bearer, err := jwtutil.CreateToken(jwtutil.CreateTokenOptions{
+			Username:   username,
+			Duration:   time.Minute,
+			SigningKey: signingKey,
+		})
+		require.NoError(t, err)
+		return bearer
+	}
+
+	call := func(bearer string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		rec := httptest.NewRecorder()
+		route.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	joker, stranger := newToken("cyberjoker"), newToken("stranger")
+	assert.Equal(t, http.StatusOK, call(joker))
+	assert.Equal(t, http.StatusOK, call(stranger))
+
+	claims, err := jwtutil.ValidateClaims(signingKey, joker)
+	require.NoError(t, err)
+	require.NoError(t, revocations.RevokeToken(context.Background(), claims.ID, claims.ExpiresAt.Time))
+	assert.Equal(t, http.StatusUnauthorized, call(joker))
+
+	require.NoError(t, revocations.RevokeUser(context.Background(), "stranger", time.Hour))
+	assert.Equal(t, http.StatusUnauthorized, call(stranger))
+
+	// refresh tokens are not access tokens
+	pair, err := jwtutil.CreateTokenPair(jwtutil.TokenPairOptions{
+		CreateTokenOptions: jwtutil.CreateTokenOptions{
+			Username:   "cyberjoker",
+			Duration:   time.Minute,
+			SigningKey: signingKey,
+		},
+		RefreshDuration: time.Hour,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, call(pair.AccessToken))
+	assert.Equal(t, http.StatusUnauthorized, call(pair.RefreshToken))
+}
+
+func TestUserConfigWithInformerStore(t *testing.T) {
+	const signingKey = "abbracadabbra"
+
+	cli := fake.NewClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      endpoints.ClientConfigName("cyberjoker"),
+			Namespace: "demo-system",
+			Labels:    map[string]string{endpoints.ClientConfigLabel: "true"},
+		},
+		Data: map[string][]byte{
+			"server-url": []byte("https://example.org"),
+			"token":      []byte("XYZ"),
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := endpoints.NewInformerStore(endpoints.InformerStoreOptions{
+		Client:    cli,
+		Namespace: "demo-system",
+	})
+	store.Start(ctx)
+	require.NoError(t, store.WaitForSync(ctx))
+	cli.ClearActions()
+
+	route := NewChain(UserConfig(signingKey, "demo-system", WithEndpointSource(store))).
+		ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+
+	call := func(username string) int {
+		bearer, err := jwtutil.CreateToken(jwtutil.CreateTokenOptions{
+			Username:   username,
+			Duration:   time.Minute,
+			SigningKey: signingKey,
+		})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		rec := httptest.NewRecorder()
+		route.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for range 10 {
+		assert.Equal(t, http.StatusOK, call("cyberjoker"))
+	}
+	assert.Empty(t, cli.Actions(), "repeated requests should not call the API server")
+
+	for range 10 {
+		assert.Equal(t, http.StatusUnauthorized, call("stranger"))
+	}
+	assert.Len(t, cli.Actions(), 1, "unknown users should be negatively cached")
+}
+
+func TestInClusterStoreIsShared(t *testing.T) {
+	store := endpoints.NewInformerStore(endpoints.InformerStoreOptions{
+		Client:    fake.NewClientset(),
+		Namespace: "shared-system",
+	})
+
+	ctx, stop := context.WithCancel(context.Background())
+	store.Start(ctx)
+
+	inClusterStores.mu.Lock()
+	inClusterStores.items["shared-system"] = runningStore{InformerStore: store, stop: stop}
+	inClusterStores.mu.Unlock()
+	t.Cleanup(func() {
+		inClusterStores.mu.Lock()
+		delete(inClusterStores.items, "shared-system")
+		inClusterStores.mu.Unlock()
+	})
+
+	for range 2 {
+		got, err := inClusterStore("shared-system")
+		require.NoError(t, err)
+		assert.Same(t, store, got)
+	}
+
+	require.NoError(t, StopInClusterSources(context.Background()))
+	assert.Error(t, ctx.Err(), "the informer should be stopped")
+
+	inClusterStores.mu.Lock()
+	assert.Empty(t, inClusterStores.items)
+	inClusterStores.mu.Unlock()
+}
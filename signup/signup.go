@@ -30,6 +30,34 @@ type Options struct {
 	Namespace    string
 	Username     string
 	UserGroups   []string
+	// Sink receives the generated endpoint.
+	// Defaults to the Secrets of Namespace.
+	Sink endpoints.Sink
+	// Issuer signs the client certificate.
+	// Defaults to a CertificateSigningRequest issuer using RestConfig.
+	//
+	// RestConfig is not needed when Issuer, Sink and CAData are all set.
+	Issuer CertificateIssuer
+}
+
+// CertificateRequest describes the client certificate to issue.
+type CertificateRequest struct {
+	UserID   string
+	Username string
+	Groups   []string
+	Duration time.Duration
+}
+
+// CertificateIssuer issues client certificates, returning the
+// base64 encoded PEM certificate and private key.
+type CertificateIssuer interface {
+	Issue(ctx context.Context, req CertificateRequest) (cert, key string, err error)
+}
+
+// NewCSRIssuer returns an issuer signing the client certificates
+// through a Kubernetes CertificateSigningRequest.
+func NewCSRIssuer(rc *rest.Config) CertificateIssuer {
+	return &csrIssuer{rc: rc}
 }
 
 func Do(ctx context.Context, opts Options) (endpoints.Endpoint, error) {
@@ -40,6 +68,8 @@ func Do(ctx context.Context, opts Options) (endpoints.Endpoint, error) {
 		certDuration: opts.CertDuration,
 		restconfig:   opts.RestConfig,
 		namespace:    opts.Namespace,
+		sink:         opts.Sink,
+		issuer:       opts.Issuer,
 	}
 
 	return handler.doIt(ctx, opts.Username, opts.UserGroups)
@@ -52,6 +82,8 @@ type signupHandler struct {
 	certDuration time.Duration
 	restconfig   *rest.Config
 	namespace    string
+	sink         endpoints.Sink
+	issuer       CertificateIssuer
 }
 
 func (g *signupHandler) doIt(ctx context.Context, user string, groups []string) (endpoints.Endpoint, error) {
@@ -63,17 +95,22 @@ func (g *signupHandler) doIt(ctx context.Context, user string, groups []string)
 		g.caData = caCrt
 	}
 
-	ep, err := g.generateEndpoint(user, groups)
+	ep, err := g.generateEndpoint(ctx, user, groups)
 	if err != nil {
 		return endpoints.Endpoint{}, err
 	}
 	ep.Username = user
 
-	err = endpoints.Store(ctx, g.restconfig, g.namespace, ep)
+	sink := g.sink
+	if sink == nil {
+		sink = endpoints.NewSecretStore(g.restconfig, g.namespace)
+	}
+
+	err = sink.Put(ctx, endpoints.ClientConfigName(user), ep)
 	return ep, err
 }
 
-func (g *signupHandler) generateEndpoint(user string, groups []string) (ep endpoints.Endpoint, err error) {
+func (g *signupHandler) generateEndpoint(ctx context.Context, user string, groups []string) (ep endpoints.Endpoint, err error) {
 	if len(g.serverURL) == 0 {
 		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
 		if len(host) == 0 || len(port) == 0 {
@@ -82,16 +119,16 @@ func (g *signupHandler) generateEndpoint(user string, groups []string) (ep endpo
 		g.serverURL = "https://" + net.JoinHostPort(host, port)
 	}
 
-	cli, err := kubernetes.NewForConfig(g.restconfig)
-	if err != nil {
-		return ep, err
+	issuer := g.issuer
+	if issuer == nil {
+		issuer = NewCSRIssuer(g.restconfig)
 	}
 
-	cert, key, err := generateClientCertAndKey(cli, generateClientCertAndKeyOpts{
-		userID:   mkID(fmt.Sprintf("%s@%s", user, strings.Join(groups, ","))),
-		username: user,
-		groups:   groups,
-		duration: g.certDuration,
+	cert, key, err := issuer.Issue(ctx, CertificateRequest{
+		UserID:   mkID(fmt.Sprintf("%s@%s", user, strings.Join(groups, ","))),
+		Username: user,
+		Groups:   groups,
+		Duration: g.certDuration,
 	})
 	if err != nil {
 		return ep, err
@@ -105,29 +142,31 @@ func (g *signupHandler) generateEndpoint(user string, groups []string) (ep endpo
 	return
 }
 
-type generateClientCertAndKeyOpts struct {
-	duration time.Duration
-	userID   string
-	username string
-	groups   []string
+type csrIssuer struct {
+	rc *rest.Config
 }
 
-func generateClientCertAndKey(client kubernetes.Interface, o generateClientCertAndKeyOpts) (string, string, error) {
+func (i *csrIssuer) Issue(_ context.Context, o CertificateRequest) (string, string, error) {
+	client, err := kubernetes.NewForConfig(i.rc)
+	if err != nil {
+		return "", "", err
+	}
+
 	key, err := certs.NewPrivateKey()
 	if err != nil {
 		return "", "", err
 	}
 
 	req, err := certs.NewCertificateRequest(certs.CertificateRequestOptions{
-		Key: key, Username: o.username, Groups: o.groups,
+		Key: key, Username: o.Username, Groups: o.Groups,
 	})
 	if err != nil {
 		return "", "", err
 	}
 
 	csr := certs.NewCertificateSigningRequest(certs.CertificateSigningRequestOptions{
-		CSR: req, Duration: o.duration,
-		UserID: o.userID, Username: o.username,
+		CSR: req, Duration: o.Duration,
+		UserID: o.UserID, Username: o.Username,
 	})
 
 	err = certs.CreateCertificateSigningRequests(client, csr)
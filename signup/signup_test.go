@@ -0,0 +1,49 @@
+package signup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/krateoplatformops/plumbing/endpoints"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeIssuer struct {
+	got CertificateRequest
+}
+
+func (f *fakeIssuer) Issue(_ context.Context, req CertificateRequest) (string, string, error) {
+	f.got = req
+	return "CERT", "KEY", nil
+}
+
+func TestDoWithoutCluster(t *testing.T) {
+	issuer := &fakeIssuer{}
+	store := endpoints.NewFileStore(t.TempDir())
+
+	ep, err := Do(context.Background(), Options{
+		CAData:     "CA",
+		ServerURL:  "https://example.org",
+		Username:   "cyberjoker",
+		UserGroups: []string{"devs"},
+		Sink:       store,
+		Issuer:     issuer,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "cyberjoker", issuer.got.Username)
+	assert.Equal(t, []string{"devs"}, issuer.got.Groups)
+	assert.NotEmpty(t, issuer.got.UserID)
+
+	got, err := store.Get(context.Background(), endpoints.ClientConfigName("cyberjoker"))
+	require.NoError(t, err)
+	assert.Equal(t, ep, got)
+	assert.Equal(t, endpoints.Endpoint{
+		ServerURL:                "https://example.org",
+		CertificateAuthorityData: "CA",
+		ClientCertificateData:    "CERT",
+		ClientKeyData:            "KEY",
+		Username:                 "cyberjoker",
+	}, got)
+}
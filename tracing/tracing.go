@@ -0,0 +1,115 @@
+// Package tracing connects the Krateo trace id to OpenTelemetry.
+//
+// Spans are created through the OpenTelemetry API, so they join the
+// trace of any instrumented caller and are recorded by the
+// TracerProvider installed by the service (see Setup), while the
+// W3C Trace Context (https://www.w3.org/TR/trace-context/) is
+// propagated through the configured TextMapPropagator.
+package tracing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of the spans created by this module.
+const ScopeName = "github.com/krateoplatformops/plumbing"
+
+// Tracer returns the tracer of this module from the global TracerProvider.
+func Tracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(ScopeName)
+}
+
+// Propagator returns the global TextMapPropagator or,
+// when none has been set, the W3C Trace Context one.
+func Propagator() propagation.TextMapPropagator {
+	if p := otel.GetTextMapPropagator(); len(p.Fields()) > 0 {
+		return p
+	}
+	return propagation.TraceContext{}
+}
+
+// Setup installs, as OpenTelemetry globals, a TracerProvider batching
+// the spans to the specified exporters (i.e. otlptracehttp.New or, in
+// tests, tracetest.NewInMemoryExporter) and the W3C Trace Context and
+// Baggage propagators.
+//
+// Call Shutdown on the returned provider to flush the pending spans.
+func Setup(exporters ...sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	opts := make([]sdktrace.TracerProviderOption, 0, len(exporters))
+	for _, exp := range exporters {
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	}
+
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	return tp
+}
+
+// ContextWithKrateoTraceId returns a context whose remote parent belongs
+// to the trace derived from the Krateo trace id (see TraceIDFromString),
+// so that services receiving only the Krateo trace id share the trace.
+//
+// A context that already carries a valid span context is returned as is.
+// The Krateo trace id carries no sampling decision: the derived parent
+// is marked as sampled and the sampler of the provider has the last word.
+func ContextWithKrateoTraceId(ctx context.Context, id string) context.Context {
+	if trace.SpanContextFromContext(ctx).IsValid() || len(id) == 0 {
+		return ctx
+	}
+
+	sum := sha256.Sum256([]byte("krateo:" + id))
+
+	var spanID trace.SpanID
+	copy(spanID[:], sum[:])
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    TraceIDFromString(id),
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+
+	return trace.ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// TraceIDFromString maps an application trace id (i.e. the Krateo trace id)
+// to a W3C trace-id: 32 hex digits are used as they are, anything else is
+// hashed, so the same id always yields the same trace.
+func TraceIDFromString(id string) trace.TraceID {
+	var res trace.TraceID
+	if len(id) == 32 && strings.ToLower(id) == id {
+		if _, err := hex.Decode(res[:], []byte(id)); err == nil && res.IsValid() {
+			return res
+		}
+		res = trace.TraceID{}
+	}
+
+	sum := sha256.Sum256([]byte(id))
+	copy(res[:], sum[:])
+	return res
+}
+
+// SpanName returns the name of the server span of a request routed
+// through the http.ServeMux pattern (i.e. "GET /items/{id}"); the raw
+// path is never used, so that span names have a bounded cardinality.
+func SpanName(method, pattern string) string {
+	if len(pattern) == 0 {
+		return method
+	}
+	if strings.Contains(pattern, " ") {
+		return pattern
+	}
+	return method + " " + pattern
+}
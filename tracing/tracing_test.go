@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceIDFromString(t *testing.T) {
+	hexID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	assert.Equal(t, hexID, TraceIDFromString(hexID).String())
+
+	got := TraceIDFromString("p0Vq1DSxR")
+	assert.True(t, got.IsValid())
+	assert.Equal(t, got, TraceIDFromString("p0Vq1DSxR"))
+	assert.NotEqual(t, got, TraceIDFromString("p0Vq1DSxS"))
+
+	upper := TraceIDFromString("4BF92F3577B34DA6A3CE929D0E0E4736")
+	assert.NotEqual(t, hexID, upper.String())
+}
+
+func TestContextWithKrateoTraceId(t *testing.T) {
+	ctx := ContextWithKrateoTraceId(context.Background(), "p0Vq1DSxR")
+
+	sc := trace.SpanContextFromContext(ctx)
+	require.True(t, sc.IsValid())
+	assert.True(t, sc.IsRemote())
+	assert.Equal(t, TraceIDFromString("p0Vq1DSxR"), sc.TraceID())
+
+	// An existing span context wins.
+	assert.Equal(t, sc, trace.SpanContextFromContext(ContextWithKrateoTraceId(ctx, "other")))
+
+	ctx = ContextWithKrateoTraceId(context.Background(), "")
+	assert.False(t, trace.SpanContextFromContext(ctx).IsValid())
+}
+
+func TestSpanName(t *testing.T) {
+	assert.Equal(t, "GET", SpanName("GET", ""))
+	assert.Equal(t, "GET /items/{id}", SpanName("GET", "GET /items/{id}"))
+	assert.Equal(t, "POST /items", SpanName("POST", "/items"))
+	assert.Equal(t, "GET example.org/", SpanName("GET", "example.org/"))
+}
+
+func TestSetup(t *testing.T) {
+	prevTP, prevP := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevP)
+	})
+
+	exp := tracetest.NewInMemoryExporter()
+	tp := Setup(exp)
+
+	ctx, root := Tracer().Start(context.Background(), "root")
+	_, child := Tracer().Start(ctx, "child")
+	child.End()
+	root.End()
+
+	require.NoError(t, tp.ForceFlush(context.Background()))
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Equal(t, root.SpanContext().SpanID(), spans[0].Parent.SpanID())
+	assert.Equal(t, ScopeName, spans[0].InstrumentationScope.Name)
+
+	carrier := propagation.MapCarrier{}
+	Propagator().Inject(ctx, carrier)
+	assert.Contains(t, carrier["traceparent"], root.SpanContext().TraceID().String())
+
+	require.NoError(t, tp.Shutdown(context.Background()))
+}